@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/transport"
+)
+
+// KeyFunc derives the cache key of a request. An empty key means the request
+// is never served from, or stored in, the cache.
+type KeyFunc[Request any] func(ctx context.Context, request Request) string
+
+// Middleware returns an endpoint.Middleware that serves responses from store
+// while they're fresh, for ttl after they were stored. Otherwise, the wrapped
+// endpoint is called, and its response is stored if it succeeded.
+//
+// Responses served from the cache are shared by every caller, so they must be
+// treated as read-only.
+func Middleware[Request, Response any](store Store[Response], key KeyFunc[Request], ttl time.Duration, options ...Option) endpoint.Middleware[Request, Response] {
+	c := config{
+		ttl:          ttl,
+		errorHandler: transport.NewLogErrorHandler(log.NewNopLogger()),
+		now:          time.Now,
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		m := &middleware[Request, Response]{
+			config:       c,
+			store:        store,
+			key:          key,
+			next:         next,
+			revalidating: map[string]bool{},
+		}
+		return m.serve
+	}
+}
+
+type config struct {
+	ttl          time.Duration
+	stale        time.Duration
+	negativeTTL  time.Duration
+	negative     func(error) bool
+	errorHandler transport.ErrorHandler
+	now          func() time.Time
+}
+
+// Option sets an optional parameter for the cache Middleware.
+type Option func(*config)
+
+// StaleWhileRevalidate lets the Middleware serve an entry for up to d after it
+// stops being fresh. The first request for a stale entry triggers a call to
+// the wrapped endpoint in the background, which refreshes the entry. By
+// default, stale entries aren't served.
+func StaleWhileRevalidate(d time.Duration) Option {
+	return func(c *config) { c.stale = d }
+}
+
+// CacheErrors makes the Middleware cache the errors of the wrapped endpoint
+// that match one of errs, as reported by errors.Is, for ttl. By default, errors
+// aren't cached.
+func CacheErrors(ttl time.Duration, errs ...error) Option {
+	return func(c *config) {
+		c.negativeTTL = ttl
+		c.negative = func(err error) bool {
+			for _, target := range errs {
+				if errors.Is(err, target) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+}
+
+// ErrorHandler is used to handle errors returned by the Store, which are
+// otherwise treated as cache misses. By default, they're ignored.
+func ErrorHandler(errorHandler transport.ErrorHandler) Option {
+	return func(c *config) { c.errorHandler = errorHandler }
+}
+
+type middleware[Request, Response any] struct {
+	config
+	store Store[Response]
+	key   KeyFunc[Request]
+	next  endpoint.Endpoint[Request, Response]
+
+	mtx          sync.Mutex
+	revalidating map[string]bool
+}
+
+func (m *middleware[Request, Response]) serve(ctx context.Context, request Request) (Response, error) {
+	k := m.key(ctx, request)
+	if k == "" {
+		record(ctx, Bypass, Entry[Response]{})
+		return m.next(ctx, request)
+	}
+
+	entry, ok, err := m.store.Get(ctx, k)
+	if err != nil {
+		m.errorHandler.Handle(ctx, err)
+		ok = false
+	}
+
+	now := m.now()
+	switch {
+	case ok && now.Before(entry.FreshUntil):
+		record(ctx, Hit, entry)
+		return entry.Response, entry.Err
+
+	case ok && now.Before(entry.StaleUntil):
+		record(ctx, Stale, entry)
+		m.revalidate(ctx, k, request)
+		return entry.Response, entry.Err
+	}
+
+	entry, stored := m.fetch(ctx, k, request)
+	if stored {
+		record(ctx, Miss, entry)
+	} else {
+		record(ctx, Miss, Entry[Response]{})
+	}
+	return entry.Response, entry.Err
+}
+
+// fetch calls the wrapped endpoint and stores its result, if it should be
+// cached. The returned entry holds the result either way, and reports whether
+// it was stored.
+func (m *middleware[Request, Response]) fetch(ctx context.Context, key string, request Request) (Entry[Response], bool) {
+	response, err := m.next(ctx, request)
+	entry := Entry[Response]{Response: response, Err: err}
+
+	ttl := m.ttl
+	if err != nil {
+		if m.negative == nil || !m.negative(err) {
+			return entry, false
+		}
+		ttl = m.negativeTTL
+	}
+
+	now := m.now()
+	entry.ETag = `W/"` + strconv.FormatInt(now.UnixNano(), 36) + `"`
+	entry.Stored = now
+	entry.FreshUntil = now.Add(ttl)
+	entry.StaleUntil = now.Add(ttl + m.stale)
+	if setErr := m.store.Set(ctx, key, entry); setErr != nil {
+		m.errorHandler.Handle(ctx, setErr)
+	}
+	return entry, true
+}
+
+// revalidate refreshes the entry for key in the background, unless that's
+// already under way.
+func (m *middleware[Request, Response]) revalidate(ctx context.Context, key string, request Request) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.revalidating[key] {
+		return
+	}
+	m.revalidating[key] = true
+
+	go func() {
+		defer func() {
+			m.mtx.Lock()
+			delete(m.revalidating, key)
+			m.mtx.Unlock()
+		}()
+		m.fetch(context.WithoutCancel(ctx), key, request)
+	}()
+}
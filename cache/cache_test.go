@@ -0,0 +1,255 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/cache"
+	"github.com/openmesh/kit/transport"
+	kithttp "github.com/openmesh/kit/transport/http"
+)
+
+func byRequest(_ context.Context, request string) string { return request }
+
+// counter is an endpoint that returns the number of times it has been called.
+type counter struct{ calls int32 }
+
+func (c *counter) endpoint(context.Context, string) (string, error) {
+	return strconv.Itoa(int(atomic.AddInt32(&c.calls, 1))), nil
+}
+
+func TestHitAndExpiry(t *testing.T) {
+	var (
+		c   counter
+		ttl = 20 * time.Millisecond
+		e   = cache.Middleware[string, string](cache.NewLRU[string](10), byRequest, ttl)(c.endpoint)
+	)
+	for _, want := range []struct {
+		response string
+		status   cache.Status
+	}{
+		{"1", cache.Miss},
+		{"1", cache.Hit},
+		{"1", cache.Hit},
+	} {
+		ctx := cache.NewContext(context.Background())
+		if response, _ := e(ctx, "a"); response != want.response {
+			t.Fatalf("want %q, have %q", want.response, response)
+		}
+		if result, _ := cache.ResultFromContext(ctx); result.Status != want.status {
+			t.Fatalf("want %v, have %v", want.status, result.Status)
+		}
+	}
+
+	if response, _ := e(context.Background(), "b"); response != "2" {
+		t.Fatalf("other key: want %q, have %q", "2", response)
+	}
+
+	time.Sleep(2 * ttl)
+	if response, _ := e(context.Background(), "a"); response != "3" {
+		t.Fatalf("after expiry: want %q, have %q", "3", response)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var (
+		c   counter
+		ttl = 20 * time.Millisecond
+		e   = cache.Middleware[string, string](
+			cache.NewLRU[string](10), byRequest, ttl,
+			cache.StaleWhileRevalidate(time.Minute),
+		)(c.endpoint)
+	)
+	e(context.Background(), "a")
+	time.Sleep(2 * ttl)
+
+	ctx := cache.NewContext(context.Background())
+	if response, _ := e(ctx, "a"); response != "1" {
+		t.Fatalf("stale: want %q, have %q", "1", response)
+	}
+	if result, _ := cache.ResultFromContext(ctx); result.Status != cache.Stale {
+		t.Fatalf("want %v, have %v", cache.Stale, result.Status)
+	}
+
+	// The entry is refreshed in the background.
+	deadline := time.Now().Add(time.Second)
+	for {
+		ctx := cache.NewContext(context.Background())
+		response, _ := e(ctx, "a")
+		if result, _ := cache.ResultFromContext(ctx); result.Status == cache.Hit {
+			if response != "2" {
+				t.Fatalf("revalidated: want %q, have %q", "2", response)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("entry was never revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if want, have := int32(2), atomic.LoadInt32(&c.calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	var (
+		errNotFound = errors.New("not found")
+		errOther    = errors.New("other")
+		calls       int
+		e           = cache.Middleware[string, string](
+			cache.NewLRU[string](10), byRequest, time.Minute,
+			cache.CacheErrors(time.Minute, errNotFound),
+		)(func(_ context.Context, request string) (string, error) {
+			calls++
+			if request == "missing" {
+				return "", errNotFound
+			}
+			return "", errOther
+		})
+	)
+	for i := 0; i < 3; i++ {
+		if _, err := e(context.Background(), "missing"); err != errNotFound {
+			t.Fatalf("want %v, have %v", errNotFound, err)
+		}
+	}
+	if want, have := 1, calls; want != have {
+		t.Fatalf("cached error: want %d calls, have %d", want, have)
+	}
+
+	for i := 0; i < 3; i++ {
+		e(context.Background(), "broken")
+	}
+	if want, have := 4, calls; want != have {
+		t.Fatalf("uncached error: want %d calls, have %d", want, have)
+	}
+}
+
+func TestBypass(t *testing.T) {
+	var (
+		c counter
+		e = cache.Middleware[string, string](
+			cache.NewLRU[string](10),
+			func(context.Context, string) string { return "" },
+			time.Minute,
+		)(c.endpoint)
+	)
+	e(context.Background(), "a")
+	e(context.Background(), "a")
+	if want, have := int32(2), atomic.LoadInt32(&c.calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+type brokenStore struct{}
+
+func (brokenStore) Get(context.Context, string) (cache.Entry[string], bool, error) {
+	return cache.Entry[string]{}, false, errors.New("get failed")
+}
+
+func (brokenStore) Set(context.Context, string, cache.Entry[string]) error {
+	return errors.New("set failed")
+}
+
+func (brokenStore) Delete(context.Context, string) error { return nil }
+
+func TestStoreErrors(t *testing.T) {
+	var (
+		c    counter
+		errs []error
+		e    = cache.Middleware[string, string](brokenStore{}, byRequest, time.Minute,
+			cache.ErrorHandler(transport.ErrorHandlerFunc(func(_ context.Context, err error) {
+				errs = append(errs, err)
+			})),
+		)(c.endpoint)
+	)
+	if response, err := e(context.Background(), "a"); err != nil || response != "1" {
+		t.Fatalf("want %q, have %q, %v", "1", response, err)
+	}
+	if want, have := 2, len(errs); want != have {
+		t.Errorf("want %d errors handled, have %d", want, have)
+	}
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	var (
+		c       counter
+		e       = cache.Middleware[string, string](cache.NewLRU[string](10), byRequest, time.Minute)(c.endpoint)
+		handler = kithttp.NewServer(
+			e,
+			func(context.Context, *http.Request) (string, error) { return "a", nil },
+			kithttp.EncodeJSONResponse[string],
+			kithttp.ServerBefore[string, string](cache.HTTPToContext()),
+			kithttp.ServerAfter[string, string](cache.ContextToHTTP()),
+		)
+	)
+
+	var etags []string
+	for _, want := range []string{"MISS", "HIT"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if have := rec.Header().Get(cache.StatusHeader); want != have {
+			t.Errorf("want %s, have %s", want, have)
+		}
+		etags = append(etags, rec.Header().Get("ETag"))
+	}
+	if etags[0] == "" || etags[0] != etags[1] {
+		t.Errorf("want the same ETag for both responses, have %q", etags)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	var (
+		c       counter
+		e       = cache.Middleware[string, string](cache.NewLRU[string](10), byRequest, time.Minute)(c.endpoint)
+		handler = kithttp.NewServer(
+			e,
+			func(context.Context, *http.Request) (string, error) { return "a", nil },
+			kithttp.EncodeJSONResponse[string],
+			kithttp.ServerBefore[string, string](cache.HTTPToContext()),
+			kithttp.ServerAfter[string, string](cache.ContextToHTTP()),
+		)
+		codes  []int
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			codes = append(codes, rec.Code)
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes())
+		}))
+	)
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := kithttp.NewClient(
+		"GET",
+		target,
+		func(context.Context, *http.Request, string) error { return nil },
+		kithttp.DecodeResponse[string](kithttp.DefaultCodecs),
+		kithttp.ClientCache[string, string](cache.ResponseCache(cache.NewLRU[kithttp.CachedResponse](10), time.Hour)),
+	).Endpoint()
+
+	for i := 0; i < 2; i++ {
+		response, err := client(context.Background(), "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "1", response; want != have {
+			t.Errorf("want %q, have %q", want, have)
+		}
+	}
+	if want, have := "[200 304]", fmt.Sprint(codes); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
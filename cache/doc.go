@@ -0,0 +1,15 @@
+// Package cache implements response caching for endpoints.
+//
+// Middleware caches the responses of read-heavy endpoints in a Store, keyed by
+// a KeyFunc derived from the request. Entries are fresh for a TTL, and may
+// then be served stale while they're revalidated in the background. Selected
+// errors may be cached too, for a shorter time, so that a missing item isn't
+// looked up over and over.
+//
+// LRU is an in-memory Store. Implement Store to use an external cache.
+//
+// The HTTP transport can report how each request was served, and the ETag of
+// the cached response, with HTTPToContext and ContextToHTTP. ResponseCache
+// lets HTTP clients revalidate their cached responses with a Store, see
+// kithttp.ClientCache.
+package cache
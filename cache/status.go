@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	kithttp "github.com/openmesh/kit/transport/http"
+)
+
+// Status is how the cache Middleware served a request.
+type Status int
+
+const (
+	// Bypass means the request has no cache key.
+	Bypass Status = iota
+
+	// Hit means a fresh response was served from the cache.
+	Hit
+
+	// Stale means a stale response was served from the cache, and is being
+	// revalidated.
+	Stale
+
+	// Miss means the response was served by the wrapped endpoint.
+	Miss
+)
+
+// String implements fmt.Stringer.
+func (s Status) String() string {
+	switch s {
+	case Bypass:
+		return "BYPASS"
+	case Hit:
+		return "HIT"
+	case Stale:
+		return "STALE"
+	case Miss:
+		return "MISS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// StatusHeader is the HTTP header in which ContextToHTTP reports the Status.
+const StatusHeader = "X-Cache"
+
+// Result describes how the cache Middleware served a request.
+type Result struct {
+	Status Status
+
+	// ETag and Stored describe the cache entry served, or stored on a miss.
+	// They're empty if there's no such entry.
+	ETag   string
+	Stored time.Time
+}
+
+type contextKey int
+
+const recorderKey contextKey = iota
+
+type recorder struct {
+	mtx    sync.Mutex
+	result Result
+	ok     bool
+}
+
+// NewContext returns a context in which the cache Middleware records how it
+// served the request. Pass it to the endpoint, and call ResultFromContext once
+// it returns.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recorderKey, &recorder{})
+}
+
+// ResultFromContext returns the Result recorded in a context created by
+// NewContext. It returns false if nothing has been recorded.
+func ResultFromContext(ctx context.Context) (Result, bool) {
+	r, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return Result{}, false
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.result, r.ok
+}
+
+func record[V any](ctx context.Context, s Status, entry Entry[V]) {
+	r, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.result, r.ok = Result{Status: s, ETag: entry.ETag, Stored: entry.Stored}, true
+}
+
+// HTTPToContext returns a RequestFunc that prepares the request context for
+// recording, see NewContext. Use it with kithttp.ServerBefore.
+func HTTPToContext() kithttp.RequestFunc {
+	return func(ctx context.Context, _ *http.Request) context.Context {
+		return NewContext(ctx)
+	}
+}
+
+// ContextToHTTP returns a ServerResponseFunc that reports how the request was
+// served in the StatusHeader header and, if a cache entry was involved, its
+// ETag in the ETag header. Use it with kithttp.ServerAfter, together with
+// HTTPToContext.
+func ContextToHTTP() kithttp.ServerResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter) context.Context {
+		result, ok := ResultFromContext(ctx)
+		if !ok {
+			return ctx
+		}
+		w.Header().Set(StatusHeader, result.Status.String())
+		if result.ETag != "" {
+			w.Header().Set("ETag", result.ETag)
+		}
+		return ctx
+	}
+}
+
+// ResponseCache adapts store to a kithttp.ResponseCache, to cache HTTP
+// responses on the client side with the kithttp.ClientCache option. Responses
+// are kept for ttl after they're stored or revalidated. Whether they're fresh
+// is up to the client, from their Cache-Control header.
+func ResponseCache(store Store[kithttp.CachedResponse], ttl time.Duration) kithttp.ResponseCache {
+	return responseCache{store, ttl}
+}
+
+type responseCache struct {
+	store Store[kithttp.CachedResponse]
+	ttl   time.Duration
+}
+
+func (c responseCache) Get(ctx context.Context, key string) (kithttp.CachedResponse, bool, error) {
+	entry, ok, err := c.store.Get(ctx, key)
+	return entry.Response, ok, err
+}
+
+func (c responseCache) Set(ctx context.Context, key string, response kithttp.CachedResponse) error {
+	until := response.Stored.Add(c.ttl)
+	return c.store.Set(ctx, key, Entry[kithttp.CachedResponse]{
+		Response:   response,
+		ETag:       response.Header.Get("ETag"),
+		Stored:     response.Stored,
+		FreshUntil: until,
+		StaleUntil: until,
+	})
+}
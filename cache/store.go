@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response, or a cached error.
+type Entry[V any] struct {
+	Response V
+	Err      error // non-nil for negatively cached errors
+
+	// ETag identifies this version of the response.
+	ETag string
+
+	Stored     time.Time
+	FreshUntil time.Time
+
+	// StaleUntil is when the entry becomes useless, and may be evicted. It's
+	// never before FreshUntil.
+	StaleUntil time.Time
+}
+
+// Store holds cache entries. Implementations must be safe for concurrent use.
+// Errors returned by a Store are treated as cache misses.
+type Store[V any] interface {
+	Get(ctx context.Context, key string) (entry Entry[V], ok bool, err error)
+	Set(ctx context.Context, key string, entry Entry[V]) error
+	Delete(ctx context.Context, key string) error
+}
+
+// LRU is an in-memory Store holding a limited number of entries. When it's
+// full, the least recently used entry is evicted. Entries are also dropped
+// once they're past their StaleUntil time.
+type LRU[V any] struct {
+	capacity int
+	now      func() time.Time
+
+	mtx   sync.Mutex
+	ll    *list.List // of *lruItem, most recently used first
+	items map[string]*list.Element
+}
+
+type lruItem[V any] struct {
+	key   string
+	entry Entry[V]
+}
+
+// NewLRU returns an empty LRU holding up to capacity entries.
+func NewLRU[V any](capacity int) *LRU[V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU[V]{
+		capacity: capacity,
+		now:      time.Now,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+// Get implements Store.
+func (c *LRU[V]) Get(_ context.Context, key string) (Entry[V], bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return Entry[V]{}, false, nil
+	}
+	item := e.Value.(*lruItem[V])
+	if !c.now().Before(item.entry.StaleUntil) {
+		c.remove(e)
+		return Entry[V]{}, false, nil
+	}
+	c.ll.MoveToFront(e)
+	return item.entry, true, nil
+}
+
+// Set implements Store.
+func (c *LRU[V]) Set(_ context.Context, key string, entry Entry[V]) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruItem[V]).entry = entry
+		c.ll.MoveToFront(e)
+		return nil
+	}
+	c.items[key] = c.ll.PushFront(&lruItem[V]{key: key, entry: entry})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (c *LRU[V]) Delete(_ context.Context, key string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of entries in the LRU, including expired entries
+// that haven't been dropped yet.
+func (c *LRU[V]) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[V]) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruItem[V]).key)
+}
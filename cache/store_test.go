@@ -0,0 +1,67 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/cache"
+)
+
+func entry(v string, staleUntil time.Time) cache.Entry[string] {
+	return cache.Entry[string]{Response: v, FreshUntil: staleUntil, StaleUntil: staleUntil}
+}
+
+func TestLRUEviction(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		lru   = cache.NewLRU[string](2)
+		later = time.Now().Add(time.Hour)
+	)
+	lru.Set(ctx, "a", entry("a", later))
+	lru.Set(ctx, "b", entry("b", later))
+	lru.Get(ctx, "a") // b is now least recently used
+	lru.Set(ctx, "c", entry("c", later))
+
+	if _, ok, _ := lru.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if e, ok, _ := lru.Get(ctx, key); !ok || e.Response != key {
+			t.Errorf("%s: want %q, have %q (%v)", key, key, e.Response, ok)
+		}
+	}
+	if want, have := 2, lru.Len(); want != have {
+		t.Errorf("want %d entries, have %d", want, have)
+	}
+}
+
+func TestLRUDropsExpired(t *testing.T) {
+	var (
+		ctx = context.Background()
+		lru = cache.NewLRU[string](2)
+	)
+	lru.Set(ctx, "a", entry("a", time.Now().Add(-time.Second)))
+	if _, ok, _ := lru.Get(ctx, "a"); ok {
+		t.Error("want expired entry dropped")
+	}
+	if want, have := 0, lru.Len(); want != have {
+		t.Errorf("want %d entries, have %d", want, have)
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	var (
+		ctx = context.Background()
+		lru = cache.NewLRU[string](2)
+	)
+	lru.Set(ctx, "a", entry("a", time.Now().Add(time.Hour)))
+	lru.Set(ctx, "a", entry("b", time.Now().Add(time.Hour)))
+	if e, _, _ := lru.Get(ctx, "a"); e.Response != "b" {
+		t.Errorf("want overwritten entry, have %q", e.Response)
+	}
+	lru.Delete(ctx, "a")
+	if _, ok, _ := lru.Get(ctx, "a"); ok {
+		t.Error("want deleted entry gone")
+	}
+}
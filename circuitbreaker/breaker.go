@@ -0,0 +1,395 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+var (
+	// ErrOpen is returned in the request path when the Breaker is open and
+	// the request is rejected.
+	ErrOpen error = breakerError("circuit breaker is open")
+
+	// ErrTooManyProbes is returned in the request path when the Breaker is
+	// half-open and already has as many probe requests in flight as it
+	// permits.
+	ErrTooManyProbes error = breakerError("circuit breaker is half-open and probing")
+)
+
+// breakerError implements StatusCoder for the HTTP transport and GRPCStatus
+// for the gRPC transport, so that rejected requests are reported as
+// unavailable rather than as internal errors.
+type breakerError string
+
+func (e breakerError) Error() string { return string(e) }
+
+func (e breakerError) StatusCode() int { return http.StatusServiceUnavailable }
+
+func (e breakerError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, string(e))
+}
+
+// Middleware returns an endpoint.Middleware that implements the circuit
+// breaker pattern using the first-party Breaker. The result of every call is
+// classified by the Breaker's Classifier. A call that panics is recorded as a
+// Failure, and the panic is propagated.
+func Middleware[Request, Response any](b *Breaker) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (response Response, err error) {
+			record, err := b.allow()
+			if err != nil {
+				return *new(Response), err
+			}
+			// A panic is recorded too, or a half-open probe would hold
+			// its slot forever.
+			defer func() {
+				if r := recover(); r != nil {
+					record(Failure)
+					panic(r)
+				}
+			}()
+			response, err = next(ctx, request)
+			record(b.classifier(response, err))
+			return response, err
+		}
+	}
+}
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// StateClosed lets all requests through, and records their outcomes.
+	StateClosed State = iota
+
+	// StateOpen rejects all requests until the open timeout elapses.
+	StateOpen
+
+	// StateHalfOpen lets a limited number of probe requests through, and
+	// closes or reopens depending on their outcomes.
+	StateHalfOpen
+)
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome is how the result of a call counts against a Breaker.
+type Outcome int
+
+const (
+	// Success counts towards the calls in the window.
+	Success Outcome = iota
+
+	// Failure counts towards the calls, and the failures, in the window.
+	Failure
+
+	// Ignored isn't recorded at all.
+	Ignored
+)
+
+// Classifier decides how the result of a call counts against a Breaker.
+type Classifier func(response interface{}, err error) Outcome
+
+// DefaultClassifier counts errors returned by the endpoint, and business
+// errors identified through the endpoint.Failer interface, as failures.
+// Calls abandoned because the caller's context was canceled are ignored.
+func DefaultClassifier(response interface{}, err error) Outcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Ignored
+	case err != nil:
+		return Failure
+	}
+	if f, ok := response.(endpoint.Failer); ok && f.Failed() != nil {
+		return Failure
+	}
+	return Success
+}
+
+// Breaker is a circuit breaker with a sliding window of recent calls. It
+// opens when, over at least a minimum number of calls, the failure rate or
+// the slow call rate crosses its threshold. After the open timeout it lets a
+// limited number of probe calls through, and closes again if those probes
+// stay below the thresholds.
+//
+// A Breaker is safe for concurrent use, and may be shared by any number of
+// endpoints with Middleware.
+type Breaker struct {
+	window        window
+	minCalls      int
+	failureRate   float64
+	slowRate      float64
+	slowDuration  time.Duration
+	openTimeout   time.Duration
+	probes        int
+	classifier    Classifier
+	onStateChange func(from, to State)
+	now           func() time.Time
+
+	mtx         sync.Mutex
+	state       State
+	generation  uint64 // incremented on every state change
+	openedAt    time.Time
+	probing     int    // probes in flight
+	probeCounts counts // outcomes of completed probes
+	changes     []stateChange
+}
+
+type stateChange struct{ from, to State }
+
+// Option sets an optional parameter for a Breaker.
+type Option func(*Breaker)
+
+// CountWindow makes the Breaker consider the outcomes of the last size
+// calls. This is the default, with a size of 100.
+func CountWindow(size int) Option {
+	return func(b *Breaker) { b.window = newCountWindow(size) }
+}
+
+// TimeWindow makes the Breaker consider the outcomes of the calls made in
+// the last size duration. The window slides in steps of a tenth of its size.
+func TimeWindow(size time.Duration) Option {
+	return func(b *Breaker) { b.window = newTimeWindow(size, 10) }
+}
+
+// MinimumCalls sets the number of calls that must be recorded in the window
+// before the failure and slow call rates are considered. By default, it's 10.
+func MinimumCalls(n int) Option {
+	return func(b *Breaker) { b.minCalls = n }
+}
+
+// FailureRateThreshold sets the ratio of failed calls, between 0 and 1, at or
+// above which the Breaker opens. By default, it's 0.5.
+func FailureRateThreshold(ratio float64) Option {
+	return func(b *Breaker) { b.failureRate = ratio }
+}
+
+// SlowCallThreshold makes the Breaker open when the ratio of calls, between 0
+// and 1, that take at least d reaches ratio. By default, the duration of
+// calls isn't considered.
+func SlowCallThreshold(d time.Duration, ratio float64) Option {
+	return func(b *Breaker) { b.slowDuration, b.slowRate = d, ratio }
+}
+
+// OpenTimeout sets how long the Breaker stays open before it starts letting
+// probe calls through. By default, it's 60 seconds.
+func OpenTimeout(d time.Duration) Option {
+	return func(b *Breaker) { b.openTimeout = d }
+}
+
+// HalfOpenProbes sets the number of probe calls let through while the
+// Breaker is half-open. Once all of them have completed, the Breaker closes
+// or opens again based on their outcomes. By default, it's 1.
+func HalfOpenProbes(n int) Option {
+	return func(b *Breaker) { b.probes = n }
+}
+
+// WithClassifier sets the Classifier used to decide how the result of each
+// call counts against the Breaker. By default, DefaultClassifier is used.
+func WithClassifier(c Classifier) Option {
+	return func(b *Breaker) { b.classifier = c }
+}
+
+// IgnoreBusinessError if set to true will not treat a business error
+// identified through the endpoint.Failer interface as a failure. Such calls
+// count as successful, since the remote end is evidently healthy. It replaces
+// any Classifier set before it.
+func IgnoreBusinessError(ignore bool) Option {
+	return func(b *Breaker) {
+		if !ignore {
+			b.classifier = DefaultClassifier
+			return
+		}
+		b.classifier = func(response interface{}, err error) Outcome {
+			if outcome := DefaultClassifier(response, err); err != nil || outcome != Failure {
+				return outcome
+			}
+			return Success
+		}
+	}
+}
+
+// OnStateChange registers a function that's called whenever the Breaker
+// changes state. It's called synchronously, outside of the Breaker's lock.
+func OnStateChange(f func(from, to State)) Option {
+	return func(b *Breaker) { b.onStateChange = f }
+}
+
+// NewBreaker returns a closed Breaker.
+func NewBreaker(options ...Option) *Breaker {
+	b := &Breaker{
+		window:      newCountWindow(100),
+		minCalls:    10,
+		failureRate: 0.5,
+		openTimeout: 60 * time.Second,
+		probes:      1,
+		classifier:  DefaultClassifier,
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// State returns the current state of the Breaker.
+func (b *Breaker) State() State {
+	b.mtx.Lock()
+	defer b.unlock()
+	b.checkTimeout(b.now())
+	return b.state
+}
+
+// available reports whether Allow would let a call through right now.
+func (b *Breaker) available() bool {
+	b.mtx.Lock()
+	defer b.unlock()
+	b.checkTimeout(b.now())
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return b.probing+b.probeCounts.total < b.probes
+	}
+	return true
+}
+
+// Allow reports whether a call may proceed. If it may, the caller must make
+// the call and pass its result to done. Otherwise, Allow returns ErrOpen or
+// ErrTooManyProbes.
+func (b *Breaker) Allow() (done func(response interface{}, err error), err error) {
+	record, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(response interface{}, err error) {
+		record(b.classifier(response, err))
+	}, nil
+}
+
+// allow is Allow, with a done func taking the Outcome of the call.
+func (b *Breaker) allow() (record func(Outcome), err error) {
+	b.mtx.Lock()
+	defer b.unlock()
+
+	now := b.now()
+	b.checkTimeout(now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probing+b.probeCounts.total >= b.probes {
+			return nil, ErrTooManyProbes
+		}
+		b.probing++
+	}
+
+	generation := b.generation
+	return func(outcome Outcome) {
+		b.record(generation, now, outcome)
+	}, nil
+}
+
+func (b *Breaker) record(generation uint64, start time.Time, outcome Outcome) {
+	b.mtx.Lock()
+	defer b.unlock()
+
+	if generation != b.generation {
+		return // the call started in a previous state
+	}
+
+	now := b.now()
+	slow := b.slowDuration > 0 && now.Sub(start) >= b.slowDuration
+
+	switch b.state {
+	case StateClosed:
+		if outcome == Ignored {
+			return
+		}
+		b.window.record(now, outcome == Failure, slow)
+		if c := b.window.counts(now); c.total >= b.minCalls && b.tripped(c) {
+			b.setState(now, StateOpen)
+		}
+
+	case StateHalfOpen:
+		b.probing--
+		if outcome == Ignored {
+			return
+		}
+		b.probeCounts.add(outcome == Failure, slow)
+		if b.probeCounts.total < b.probes {
+			return
+		}
+		if b.tripped(b.probeCounts) {
+			b.setState(now, StateOpen)
+		} else {
+			b.setState(now, StateClosed)
+		}
+	}
+}
+
+func (b *Breaker) tripped(c counts) bool {
+	if float64(c.failures) >= b.failureRate*float64(c.total) {
+		return true
+	}
+	return b.slowDuration > 0 && float64(c.slow) >= b.slowRate*float64(c.total)
+}
+
+// checkTimeout moves an open Breaker to half-open once the open timeout has
+// elapsed. The caller must hold the lock.
+func (b *Breaker) checkTimeout(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		b.setState(now, StateHalfOpen)
+	}
+}
+
+// setState must be called with the lock held.
+func (b *Breaker) setState(now time.Time, state State) {
+	if b.state == state {
+		return
+	}
+	b.changes = append(b.changes, stateChange{b.state, state})
+	b.state = state
+	b.generation++
+	b.probing = 0
+	b.probeCounts = counts{}
+	b.window.reset()
+	if state == StateOpen {
+		b.openedAt = now
+	}
+}
+
+// unlock releases the lock and then notifies the OnStateChange function of
+// any state changes made while it was held.
+func (b *Breaker) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mtx.Unlock()
+
+	if b.onStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		b.onStateChange(c.from, c.to)
+	}
+}
@@ -0,0 +1,266 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/circuitbreaker"
+	"github.com/openmesh/kit/endpoint"
+)
+
+func TestBreaker(t *testing.T) {
+	var (
+		breaker          = circuitbreaker.Middleware[interface{}, interface{}](circuitbreaker.NewBreaker(circuitbreaker.CountWindow(10)))
+		primeWith        = 100
+		shouldPass       = func(n int) bool { return n < 5 } // half of the window
+		circuitOpenError = circuitbreaker.ErrOpen.Error()
+	)
+	testFailingEndpoint(t, breaker, primeWith, shouldPass, 0, circuitOpenError)
+}
+
+func TestBreakerTimeWindow(t *testing.T) {
+	var (
+		window  = 50 * time.Millisecond
+		b       = circuitbreaker.NewBreaker(circuitbreaker.TimeWindow(window), circuitbreaker.MinimumCalls(2))
+		e       = circuitbreaker.Middleware[interface{}, interface{}](b)(failing)
+		success = circuitbreaker.Middleware[interface{}, interface{}](b)(endpoint.Nop)
+	)
+
+	// A failure that has slid out of the window no longer counts.
+	e(context.Background(), struct{}{})
+	time.Sleep(2 * window)
+	success(context.Background(), struct{}{})
+	success(context.Background(), struct{}{})
+	e(context.Background(), struct{}{})
+	if want, have := circuitbreaker.StateClosed, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	e(context.Background(), struct{}{})
+	if want, have := circuitbreaker.StateOpen, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	var (
+		timeout = 20 * time.Millisecond
+		b       = circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.OpenTimeout(timeout),
+			circuitbreaker.HalfOpenProbes(2),
+		)
+		release = make(chan struct{})
+		blocked = circuitbreaker.Middleware[interface{}, interface{}](b)(func(context.Context, interface{}) (interface{}, error) {
+			<-release
+			return struct{}{}, nil
+		})
+	)
+	trip(b)
+	if _, err := blocked(context.Background(), struct{}{}); err != circuitbreaker.ErrOpen {
+		t.Fatalf("want %v, have %v", circuitbreaker.ErrOpen, err)
+	}
+
+	time.Sleep(2 * timeout)
+	if want, have := circuitbreaker.StateHalfOpen, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	// Two probes are let through, a third is rejected.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); blocked(context.Background(), struct{}{}) }()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := blocked(context.Background(), struct{}{}); err != circuitbreaker.ErrTooManyProbes {
+		t.Fatalf("want %v, have %v", circuitbreaker.ErrTooManyProbes, err)
+	}
+
+	// Once the probes succeed, the breaker closes.
+	close(release)
+	wg.Wait()
+	if want, have := circuitbreaker.StateClosed, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	var (
+		timeout = 20 * time.Millisecond
+		b       = circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.OpenTimeout(timeout),
+		)
+		e = circuitbreaker.Middleware[interface{}, interface{}](b)(failing)
+	)
+	trip(b)
+	time.Sleep(2 * timeout)
+	e(context.Background(), struct{}{})
+	if want, have := circuitbreaker.StateOpen, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestBreakerHalfOpenPanic(t *testing.T) {
+	var (
+		timeout = 20 * time.Millisecond
+		b       = circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.OpenTimeout(timeout),
+			circuitbreaker.HalfOpenProbes(1),
+		)
+		e = circuitbreaker.Middleware[interface{}, interface{}](b)(func(context.Context, interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	)
+	trip(b)
+	time.Sleep(2 * timeout)
+
+	func() {
+		defer func() {
+			if want, have := "boom", recover(); want != have {
+				t.Errorf("want panic %v, have %v", want, have)
+			}
+		}()
+		e(context.Background(), struct{}{})
+	}()
+
+	// The probe failed, so the breaker reopens, and lets a probe through
+	// again once the timeout elapses.
+	if want, have := circuitbreaker.StateOpen, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	time.Sleep(2 * timeout)
+	if _, err := circuitbreaker.Middleware[interface{}, interface{}](b)(endpoint.Nop)(context.Background(), struct{}{}); err != nil {
+		t.Fatalf("want probe let through, have %v", err)
+	}
+	if want, have := circuitbreaker.StateClosed, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestBreakerSlowCalls(t *testing.T) {
+	var (
+		b = circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.SlowCallThreshold(10*time.Millisecond, 1),
+		)
+		slow = circuitbreaker.Middleware[interface{}, interface{}](b)(func(context.Context, interface{}) (interface{}, error) {
+			time.Sleep(20 * time.Millisecond)
+			return struct{}{}, nil
+		})
+		fast = circuitbreaker.Middleware[interface{}, interface{}](b)(endpoint.Nop)
+	)
+	slow(context.Background(), struct{}{})
+	fast(context.Background(), struct{}{})
+	if want, have := circuitbreaker.StateClosed, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	slow(context.Background(), struct{}{})
+	slow(context.Background(), struct{}{})
+	if want, have := circuitbreaker.StateOpen, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+type businessResponse struct{ err error }
+
+func (r businessResponse) Failed() error { return r.err }
+
+func TestBreakerBusinessErrors(t *testing.T) {
+	business := func(context.Context, interface{}) (interface{}, error) {
+		return businessResponse{errors.New("no such user")}, nil
+	}
+	for _, testcase := range []struct {
+		ignore bool
+		want   circuitbreaker.State
+	}{
+		{ignore: false, want: circuitbreaker.StateOpen},
+		{ignore: true, want: circuitbreaker.StateClosed},
+	} {
+		b := circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.IgnoreBusinessError(testcase.ignore),
+		)
+		e := circuitbreaker.Middleware[interface{}, interface{}](b)(business)
+		for i := 0; i < 2; i++ {
+			e(context.Background(), struct{}{})
+		}
+		if want, have := testcase.want, b.State(); want != have {
+			t.Errorf("ignore=%v: want %v, have %v", testcase.ignore, want, have)
+		}
+	}
+}
+
+func TestBreakerIgnoresCanceled(t *testing.T) {
+	var (
+		b        = circuitbreaker.NewBreaker(circuitbreaker.CountWindow(2), circuitbreaker.MinimumCalls(2))
+		canceled = circuitbreaker.Middleware[interface{}, interface{}](b)(func(context.Context, interface{}) (interface{}, error) {
+			return nil, context.Canceled
+		})
+	)
+	for i := 0; i < 10; i++ {
+		canceled(context.Background(), struct{}{})
+	}
+	if want, have := circuitbreaker.StateClosed, b.State(); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestBreakerOnStateChange(t *testing.T) {
+	var (
+		changes []string
+		b       = circuitbreaker.NewBreaker(
+			circuitbreaker.CountWindow(2),
+			circuitbreaker.MinimumCalls(2),
+			circuitbreaker.OpenTimeout(time.Millisecond),
+			circuitbreaker.OnStateChange(func(from, to circuitbreaker.State) {
+				changes = append(changes, from.String()+"->"+to.String())
+			}),
+		)
+		e = circuitbreaker.Middleware[interface{}, interface{}](b)(endpoint.Nop)
+	)
+	trip(b)
+	time.Sleep(5 * time.Millisecond)
+	e(context.Background(), struct{}{})
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("want %v, have %v", want, changes)
+	}
+	for i := range want {
+		if want[i] != changes[i] {
+			t.Fatalf("want %v, have %v", want, changes)
+		}
+	}
+}
+
+func TestBreakerNilResponse(t *testing.T) {
+	e := circuitbreaker.Middleware[interface{}, interface{}](circuitbreaker.NewBreaker())(func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if response, err := e(context.Background(), struct{}{}); response != nil || err != nil {
+		t.Fatalf("want nil, nil, have %v, %v", response, err)
+	}
+}
+
+func failing(context.Context, interface{}) (interface{}, error) {
+	return nil, errors.New("tragedy+disaster")
+}
+
+// trip opens b by feeding it failures.
+func trip(b *circuitbreaker.Breaker) {
+	e := circuitbreaker.Middleware[interface{}, interface{}](b)(failing)
+	for b.State() == circuitbreaker.StateClosed {
+		e(context.Background(), struct{}{})
+	}
+}
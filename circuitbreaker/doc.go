@@ -5,6 +5,13 @@
 // circuit breaker.
 //
 // We provide several implementations in this package, but if you're looking
-// for guidance, Gobreaker is probably the best place to start.  It has a
-// simple and intuitive API, and is well-tested.
+// for guidance, the first-party Breaker is probably the best place to start.
+// It has no third-party dependencies or global state, and supports count- and
+// time-based windows, slow call detection, and state change hooks. The
+// adapters for sony/gobreaker, afex/hystrix-go and streadway/handy remain
+// available for existing users.
+//
+// When calling a load-balanced service, use Factory to give each instance its
+// own Breaker, so that one bad instance doesn't trip the circuit for all of
+// them. The balancers in package sd/lb skip instances whose breaker is open.
 package circuitbreaker
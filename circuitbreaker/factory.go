@@ -0,0 +1,45 @@
+package circuitbreaker
+
+import (
+	"io"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/sd"
+)
+
+// Factory wraps the endpoint of every instance produced by f in its own
+// Breaker, created by newBreaker, so that a single bad instance only trips
+// its own breaker rather than one shared by the whole service.
+//
+// The closer returned alongside each endpoint implements sd.StatusReporter.
+// That makes the state of each breaker visible through the Status method of
+// an sd.DefaultEndpointer, and makes the balancers in package sd/lb skip
+// instances whose breaker is rejecting requests.
+func Factory[Request, Response any](f sd.Factory[Request, Response], newBreaker func(instance string) *Breaker) sd.Factory[Request, Response] {
+	return func(instance string) (endpoint.Endpoint[Request, Response], io.Closer, error) {
+		e, closer, err := f(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		b := newBreaker(instance)
+		return Middleware[Request, Response](b)(e), breakerCloser{b, closer}, nil
+	}
+}
+
+// breakerCloser closes the instance's own closer, if any, and reports the
+// state of its Breaker.
+type breakerCloser struct {
+	breaker *Breaker
+	closer  io.Closer
+}
+
+func (c breakerCloser) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+func (c breakerCloser) State() string { return c.breaker.State().String() }
+
+func (c breakerCloser) Available() bool { return c.breaker.available() }
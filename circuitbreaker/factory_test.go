@@ -0,0 +1,82 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/openmesh/kit/circuitbreaker"
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/sd"
+	"github.com/openmesh/kit/sd/lb"
+)
+
+func TestFactory(t *testing.T) {
+	var (
+		calls   = map[string]int{}
+		factory = func(instance string) (endpoint.Endpoint[interface{}, interface{}], io.Closer, error) {
+			return func(context.Context, interface{}) (interface{}, error) {
+				calls[instance]++
+				if instance == "bad" {
+					return nil, errors.New("bad instance")
+				}
+				return struct{}{}, nil
+			}, nil, nil
+		}
+		newBreaker = func(string) *circuitbreaker.Breaker {
+			return circuitbreaker.NewBreaker(circuitbreaker.CountWindow(2), circuitbreaker.MinimumCalls(2))
+		}
+		endpointer = sd.NewEndpointer(
+			sd.FixedInstancer{"bad", "good"},
+			circuitbreaker.Factory(factory, newBreaker),
+			log.NewNopLogger(),
+		)
+		balancer = lb.NewRoundRobin[interface{}, interface{}](endpointer)
+	)
+	defer endpointer.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if endpoints, _ := endpointer.Endpoints(); len(endpoints) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("endpointer never received instances")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The bad instance trips its own breaker after two calls, after which
+	// only the good instance is picked.
+	for i := 0; i < 10; i++ {
+		e, err := balancer.Endpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e(context.Background(), struct{}{})
+	}
+	if want, have := 2, calls["bad"]; want != have {
+		t.Errorf("bad instance: want %d calls, have %d", want, have)
+	}
+	if want, have := 8, calls["good"]; want != have {
+		t.Errorf("good instance: want %d calls, have %d", want, have)
+	}
+
+	statuses, err := endpointer.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		want := map[string]string{"bad": "open", "good": "closed"}[status.Instance]
+		if want != status.State {
+			t.Errorf("%s: want state %q, have %q", status.Instance, want, status.State)
+		}
+		if want, have := status.Instance == "good", status.Available; want != have {
+			t.Errorf("%s: want available %v, have %v", status.Instance, want, have)
+		}
+	}
+}
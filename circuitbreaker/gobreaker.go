@@ -17,7 +17,13 @@ func Gobreaker[Request, Response any](cb *gobreaker.CircuitBreaker) endpoint.Mid
 	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
 		return func(ctx context.Context, request Request) (Response, error) {
 			res, err := cb.Execute(func() (interface{}, error) { return next(ctx, request) })
-			return res.(Response), err
+			if err != nil {
+				return *new(Response), err
+			}
+			// A nil interface{} holds no dynamic type, so a plain type
+			// assertion would panic when Response is an interface type.
+			response, _ := res.(Response)
+			return response, nil
 		}
 	}
 }
@@ -0,0 +1,126 @@
+package circuitbreaker
+
+import "time"
+
+// counts is a summary of the calls recorded in a window.
+type counts struct {
+	total    int
+	failures int
+	slow     int
+}
+
+func (c *counts) add(failure, slow bool) {
+	c.total++
+	if failure {
+		c.failures++
+	}
+	if slow {
+		c.slow++
+	}
+}
+
+// window is a sliding window of call outcomes.
+type window interface {
+	record(now time.Time, failure, slow bool)
+	counts(now time.Time) counts
+	reset()
+}
+
+// countWindow holds the outcomes of the last len(calls) calls in a ring.
+type countWindow struct {
+	calls []call
+	next  int
+	sum   counts
+}
+
+type call struct {
+	recorded bool
+	failure  bool
+	slow     bool
+}
+
+func newCountWindow(size int) *countWindow {
+	if size < 1 {
+		size = 1
+	}
+	return &countWindow{calls: make([]call, size)}
+}
+
+func (w *countWindow) record(_ time.Time, failure, slow bool) {
+	if old := w.calls[w.next]; old.recorded {
+		w.sum.total--
+		if old.failure {
+			w.sum.failures--
+		}
+		if old.slow {
+			w.sum.slow--
+		}
+	}
+	w.calls[w.next] = call{recorded: true, failure: failure, slow: slow}
+	w.sum.add(failure, slow)
+	w.next = (w.next + 1) % len(w.calls)
+}
+
+func (w *countWindow) counts(time.Time) counts { return w.sum }
+
+func (w *countWindow) reset() {
+	for i := range w.calls {
+		w.calls[i] = call{}
+	}
+	w.next, w.sum = 0, counts{}
+}
+
+// timeWindow holds the outcomes of the calls made in the last
+// len(buckets)*width, in buckets of the given width.
+type timeWindow struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	epoch int64 // index of the width-sized slice of time the bucket holds
+	counts
+}
+
+func newTimeWindow(size time.Duration, n int) *timeWindow {
+	width := size / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &timeWindow{width: width, buckets: make([]bucket, n)}
+}
+
+func (w *timeWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(w.width)
+}
+
+func (w *timeWindow) record(now time.Time, failure, slow bool) {
+	epoch := w.epoch(now)
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.add(failure, slow)
+}
+
+func (w *timeWindow) counts(now time.Time) counts {
+	var (
+		c      counts
+		oldest = w.epoch(now) - int64(len(w.buckets)) + 1
+	)
+	for _, b := range w.buckets {
+		if b.epoch < oldest {
+			continue
+		}
+		c.total += b.total
+		c.failures += b.failures
+		c.slow += b.slow
+	}
+	return c
+}
+
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -0,0 +1,114 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+// KeyFunc identifies a request. Concurrent requests with the same key are
+// considered identical and coalesced. An empty key means the request is never
+// coalesced.
+type KeyFunc[Request any] func(ctx context.Context, request Request) string
+
+// Middleware returns an endpoint.Middleware that coalesces concurrent requests
+// with the same key, as returned by key, into a single call to the wrapped
+// endpoint. Every caller receives that call's response and error, so the
+// response must be treated as read-only.
+//
+// The shared call doesn't run in the context of any single caller. It keeps
+// the values of the context of the caller that started it, but is only
+// canceled once every caller waiting for it has given up. A caller whose
+// context is canceled returns immediately with the context's error, without
+// affecting the others.
+//
+// If the wrapped endpoint panics, the panic is propagated to every caller.
+func Middleware[Request, Response any](key KeyFunc[Request]) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		g := &group[Request, Response]{next: next, calls: map[string]*call[Response]{}}
+		return func(ctx context.Context, request Request) (Response, error) {
+			k := key(ctx, request)
+			if k == "" {
+				return next(ctx, request)
+			}
+			return g.do(ctx, k, request)
+		}
+	}
+}
+
+// group tracks the calls in flight for one wrapped endpoint.
+type group[Request, Response any] struct {
+	next  endpoint.Endpoint[Request, Response]
+	mtx   sync.Mutex
+	calls map[string]*call[Response]
+}
+
+type call[Response any] struct {
+	done     chan struct{} // closed when the fields below are set
+	response Response
+	err      error
+	panicked interface{}
+
+	waiters int // guarded by the group's mutex
+	cancel  context.CancelFunc
+}
+
+func (g *group[Request, Response]) do(ctx context.Context, key string, request Request) (Response, error) {
+	g.mtx.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		c = g.start(ctx, key, request)
+	}
+	c.waiters++
+	g.mtx.Unlock()
+
+	select {
+	case <-c.done:
+		if c.panicked != nil {
+			panic(c.panicked)
+		}
+		return c.response, c.err
+
+	case <-ctx.Done():
+		g.mtx.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// Nobody is interested in the result anymore. Later callers
+			// mustn't join the canceled call.
+			c.cancel()
+			g.forget(key, c)
+		}
+		g.mtx.Unlock()
+		return *new(Response), ctx.Err()
+	}
+}
+
+// start must be called with the mutex held.
+func (g *group[Request, Response]) start(ctx context.Context, key string, request Request) *call[Response] {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c := &call[Response]{done: make(chan struct{}), cancel: cancel}
+	g.calls[key] = c
+
+	go func() {
+		defer close(c.done)
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				c.panicked = r
+			}
+			g.mtx.Lock()
+			g.forget(key, c)
+			g.mtx.Unlock()
+		}()
+		c.response, c.err = g.next(ctx, request)
+	}()
+	return c
+}
+
+// forget must be called with the mutex held.
+func (g *group[Request, Response]) forget(key string, c *call[Response]) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,191 @@
+package coalesce_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/coalesce"
+)
+
+func byRequest(_ context.Context, request string) string { return request }
+
+// slow is an endpoint that counts its calls and doesn't return until it's
+// released or its context is canceled.
+type slow struct {
+	calls   int32
+	started chan context.Context
+	release chan struct{}
+}
+
+func newSlow() *slow {
+	return &slow{started: make(chan context.Context, 10), release: make(chan struct{})}
+}
+
+func (s *slow) endpoint(ctx context.Context, request string) (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	s.started <- ctx
+	select {
+	case <-s.release:
+		return "response to " + request, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var (
+		s  = newSlow()
+		e  = coalesce.Middleware[string, string](byRequest)(s.endpoint)
+		n  = 10
+		wg sync.WaitGroup
+	)
+	responses := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := e(context.Background(), "a")
+			if err != nil {
+				t.Error(err)
+			}
+			responses <- response
+		}()
+	}
+	<-s.started
+	time.Sleep(10 * time.Millisecond) // let everyone join
+	close(s.release)
+	wg.Wait()
+	close(responses)
+
+	if want, have := int32(1), atomic.LoadInt32(&s.calls); want != have {
+		t.Errorf("want %d call, have %d", want, have)
+	}
+	for response := range responses {
+		if want, have := "response to a", response; want != have {
+			t.Errorf("want %q, have %q", want, have)
+		}
+	}
+}
+
+func TestDifferentKeys(t *testing.T) {
+	s := newSlow()
+	e := coalesce.Middleware[string, string](byRequest)(s.endpoint)
+	close(s.release)
+
+	var wg sync.WaitGroup
+	for _, request := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(request string) { defer wg.Done(); e(context.Background(), request) }(request)
+	}
+	wg.Wait()
+	if want, have := int32(3), atomic.LoadInt32(&s.calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestEmptyKeyNotCoalesced(t *testing.T) {
+	s := newSlow()
+	e := coalesce.Middleware[string, string](func(context.Context, string) string { return "" })(s.endpoint)
+	close(s.release)
+	e(context.Background(), "a")
+	e(context.Background(), "a")
+	if want, have := int32(2), atomic.LoadInt32(&s.calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestCanceledCallerDoesNotFailOthers(t *testing.T) {
+	var (
+		s           = newSlow()
+		e           = coalesce.Middleware[string, string](byRequest)(s.endpoint)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	first := make(chan error, 1)
+	go func() { _, err := e(ctx, "a"); first <- err }()
+	shared := <-s.started
+
+	second := make(chan string, 1)
+	go func() { response, _ := e(context.Background(), "a"); second <- response }()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if want, have := context.Canceled, <-first; want != have {
+		t.Fatalf("canceled caller: want %v, have %v", want, have)
+	}
+	if err := shared.Err(); err != nil {
+		t.Fatalf("shared call canceled too early: %v", err)
+	}
+
+	close(s.release)
+	if want, have := "response to a", <-second; want != have {
+		t.Errorf("other caller: want %q, have %q", want, have)
+	}
+}
+
+func TestAllCallersCanceled(t *testing.T) {
+	var (
+		s           = newSlow()
+		e           = coalesce.Middleware[string, string](byRequest)(s.endpoint)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	done := make(chan error, 1)
+	go func() { _, err := e(ctx, "a"); done <- err }()
+	shared := <-s.started
+	cancel()
+	<-done
+
+	select {
+	case <-shared.Done():
+	case <-time.After(time.Second):
+		t.Fatal("shared call wasn't canceled")
+	}
+
+	// A later caller starts a new call rather than joining the canceled one.
+	close(s.release)
+	if response, err := e(context.Background(), "a"); err != nil || response != "response to a" {
+		t.Errorf("want response, have %q, %v", response, err)
+	}
+	if want, have := int32(2), atomic.LoadInt32(&s.calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+type contextKey struct{}
+
+func TestContextValuesKept(t *testing.T) {
+	e := coalesce.Middleware[string, string](byRequest)(func(ctx context.Context, _ string) (string, error) {
+		v, _ := ctx.Value(contextKey{}).(string)
+		return v, nil
+	})
+	ctx := context.WithValue(context.Background(), contextKey{}, "value")
+	if response, _ := e(ctx, "a"); response != "value" {
+		t.Errorf("want %q, have %q", "value", response)
+	}
+}
+
+func TestPanicPropagated(t *testing.T) {
+	e := coalesce.Middleware[string, string](byRequest)(func(context.Context, string) (string, error) {
+		panic("boom")
+	})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("want panic %q, have %v", "boom", r)
+		}
+	}()
+	e(context.Background(), "a")
+	t.Error("want panic")
+}
+
+func TestErrorShared(t *testing.T) {
+	errBoom := errors.New("boom")
+	e := coalesce.Middleware[string, string](byRequest)(func(context.Context, string) (string, error) {
+		return "", errBoom
+	})
+	if _, err := e(context.Background(), "a"); err != errBoom {
+		t.Errorf("want %v, have %v", errBoom, err)
+	}
+}
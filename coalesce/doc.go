@@ -0,0 +1,7 @@
+// Package coalesce collapses concurrent identical requests into one.
+//
+// When many callers ask for the same thing at the same time, e.g. a popular
+// item after a cache expiry, Middleware forwards a single request downstream
+// and fans its response out to every caller waiting for it. This is sometimes
+// called single flight.
+package coalesce
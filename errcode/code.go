@@ -0,0 +1,164 @@
+package errcode
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Code is a canonical error code. The values are the same as those of gRPC
+// codes.Code.
+type Code int
+
+const (
+	// OK is returned on success.
+	OK Code = iota
+
+	// Canceled means the operation was canceled, typically by the caller.
+	Canceled
+
+	// Unknown means the error has no more specific code.
+	Unknown
+
+	// InvalidArgument means the caller specified an invalid argument,
+	// regardless of the state of the system.
+	InvalidArgument
+
+	// DeadlineExceeded means the deadline expired before the operation
+	// could complete.
+	DeadlineExceeded
+
+	// NotFound means some requested entity wasn't found.
+	NotFound
+
+	// AlreadyExists means an entity the caller attempted to create already
+	// exists.
+	AlreadyExists
+
+	// PermissionDenied means the caller isn't allowed to execute the
+	// operation.
+	PermissionDenied
+
+	// ResourceExhausted means some resource has been exhausted, e.g. a rate
+	// limit or a quota.
+	ResourceExhausted
+
+	// FailedPrecondition means the system isn't in a state required for the
+	// operation.
+	FailedPrecondition
+
+	// Aborted means the operation was aborted, typically due to a
+	// concurrency conflict.
+	Aborted
+
+	// OutOfRange means the operation was attempted past the valid range.
+	OutOfRange
+
+	// Unimplemented means the operation isn't implemented or supported.
+	Unimplemented
+
+	// Internal means an invariant of the system has been broken.
+	Internal
+
+	// Unavailable means the service is currently unavailable, and the
+	// operation may be retried.
+	Unavailable
+
+	// DataLoss means unrecoverable data loss or corruption.
+	DataLoss
+
+	// Unauthenticated means the request doesn't have valid credentials.
+	Unauthenticated
+)
+
+var codeNames = [...]string{
+	OK:                 "OK",
+	Canceled:           "CANCELED",
+	Unknown:            "UNKNOWN",
+	InvalidArgument:    "INVALID_ARGUMENT",
+	DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	NotFound:           "NOT_FOUND",
+	AlreadyExists:      "ALREADY_EXISTS",
+	PermissionDenied:   "PERMISSION_DENIED",
+	ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	FailedPrecondition: "FAILED_PRECONDITION",
+	Aborted:            "ABORTED",
+	OutOfRange:         "OUT_OF_RANGE",
+	Unimplemented:      "UNIMPLEMENTED",
+	Internal:           "INTERNAL",
+	Unavailable:        "UNAVAILABLE",
+	DataLoss:           "DATA_LOSS",
+	Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// String returns the name of the code, e.g. NOT_FOUND, as used on the wire.
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "CODE(" + strconv.Itoa(int(c)) + ")"
+}
+
+// ParseCode returns the Code with the given name, as returned by String.
+func ParseCode(s string) (Code, bool) {
+	for c, name := range codeNames {
+		if name == s {
+			return Code(c), true
+		}
+	}
+	return Unknown, false
+}
+
+// HTTPStatus returns the HTTP status code corresponding to c.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case OK:
+		return http.StatusOK
+	case Canceled:
+		return 499 // Client Closed Request
+	case InvalidArgument, FailedPrecondition, OutOfRange:
+		return http.StatusBadRequest
+	case DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case NotFound:
+		return http.StatusNotFound
+	case AlreadyExists, Aborted:
+		return http.StatusConflict
+	case PermissionDenied:
+		return http.StatusForbidden
+	case ResourceExhausted:
+		return http.StatusTooManyRequests
+	case Unimplemented:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// JSON-RPC error codes. They're duplicated from package transport/http/jsonrpc,
+// which depends on this package.
+const (
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcInternal       = -32603
+	jsonrpcServerBase     = -32000 // of the range for implementation-defined errors
+)
+
+// JSONRPCCode returns the JSON-RPC error code corresponding to c. Codes with
+// no equivalent in the JSON-RPC specification are mapped to the range
+// reserved for implementation-defined server errors, as -32000 minus c.
+func (c Code) JSONRPCCode() int {
+	switch c {
+	case InvalidArgument:
+		return jsonrpcInvalidParams
+	case Unimplemented:
+		return jsonrpcMethodNotFound
+	case Unknown, Internal:
+		return jsonrpcInternal
+	default:
+		return jsonrpcServerBase - int(c)
+	}
+}
@@ -0,0 +1,10 @@
+// Package errcode provides an error model shared by all transports.
+//
+// An Error has a canonical Code, a message and optional details. The codes
+// are those of gRPC, which cover the failure modes of most services. Server
+// transports map an Error to their wire representation: an HTTP status code
+// and JSON body, a gRPC status, a JSON-RPC error object, or a NATS or AMQP
+// error reply. Client transports decode it back into an *Error, so callers
+// can inspect failures the same way regardless of transport; the HTTP client
+// does so with its ClientDecodeErrors option.
+package errcode
@@ -0,0 +1,198 @@
+package errcode_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+func TestCodeNames(t *testing.T) {
+	for c := errcode.OK; c <= errcode.Unauthenticated; c++ {
+		if name := c.String(); strings.HasPrefix(name, "CODE(") {
+			t.Errorf("%d: no name, have %q", c, name)
+		}
+		parsed, ok := errcode.ParseCode(c.String())
+		if !ok || parsed != c {
+			t.Errorf("%s: want %d, have %d (%v)", c, c, parsed, ok)
+		}
+	}
+	if _, ok := errcode.ParseCode("NOPE"); ok {
+		t.Error("want unknown code name rejected")
+	}
+	if want, have := "CODE(42)", errcode.Code(42).String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestMappings(t *testing.T) {
+	for _, testcase := range []struct {
+		code    errcode.Code
+		http    int
+		jsonrpc int
+	}{
+		{errcode.InvalidArgument, http.StatusBadRequest, -32602},
+		{errcode.NotFound, http.StatusNotFound, -32005},
+		{errcode.Unimplemented, http.StatusNotImplemented, -32601},
+		{errcode.Internal, http.StatusInternalServerError, -32603},
+		{errcode.Unavailable, http.StatusServiceUnavailable, -32014},
+		{errcode.Unauthenticated, http.StatusUnauthorized, -32016},
+	} {
+		if want, have := testcase.http, testcase.code.HTTPStatus(); want != have {
+			t.Errorf("%s: HTTP: want %d, have %d", testcase.code, want, have)
+		}
+		if want, have := testcase.jsonrpc, testcase.code.JSONRPCCode(); want != have {
+			t.Errorf("%s: JSON-RPC: want %d, have %d", testcase.code, want, have)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	want := errcode.New(errcode.NotFound, "no such user").WithDetail("id", "42")
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"code":"NOT_FOUND","message":"no such user","details":{"id":"42"}}`, string(b); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	have := &errcode.Error{}
+	if err := json.Unmarshal(b, have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	if err := json.Unmarshal([]byte(`{"code":"NOPE"}`), have); err == nil {
+		t.Error("want error for unknown code")
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	want := errcode.New(errcode.InvalidArgument, "bad user").
+		WithDetail("id", "42").
+		WithDetail("at", "signup").
+		WithViolation("name", "must not be empty")
+	b, err := xml.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `<Error><code>INVALID_ARGUMENT</code><message>bad user</message>`+
+		`<details><detail key="at">signup</detail><detail key="id">42</detail></details>`+
+		`<violations><violation><field>name</field><description>must not be empty</description></violation></violations></Error>`, string(b); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	have := &errcode.Error{}
+	if err := xml.Unmarshal(b, have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	if err := xml.Unmarshal([]byte(`<Error><code>NOPE</code></Error>`), have); err == nil {
+		t.Error("want error for unknown code")
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	want := errcode.New(errcode.PermissionDenied, "go away").WithDetail("role", "guest")
+	s := status.Convert(want)
+	if want, have := codes.PermissionDenied, s.Code(); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	// Simulate the trip over the wire.
+	s = status.FromProto(s.Proto())
+	have, ok := errcode.FromGRPCStatus(s)
+	if !ok {
+		t.Fatal("want Error, have none")
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	if _, ok := errcode.FromGRPCStatus(status.New(codes.NotFound, "plain")); ok {
+		t.Error("want plain status not to be converted")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	for _, testcase := range []struct {
+		err  error
+		want errcode.Code
+	}{
+		{nil, errcode.OK},
+		{errcode.New(errcode.NotFound, ""), errcode.NotFound},
+		{fmt.Errorf("wrapped: %w", errcode.New(errcode.Aborted, "")), errcode.Aborted},
+		{status.Error(codes.Unavailable, "down"), errcode.Unavailable},
+		{context.DeadlineExceeded, errcode.DeadlineExceeded},
+		{fmt.Errorf("wrapped: %w", context.Canceled), errcode.Canceled},
+		{errors.New("mystery"), errcode.Unknown},
+	} {
+		if have := errcode.CodeOf(testcase.err); testcase.want != have {
+			t.Errorf("%v: want %s, have %s", testcase.err, testcase.want, have)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	e := errcode.New(errcode.NotFound, "gone")
+	if have := errcode.Convert(fmt.Errorf("wrapped: %w", e)); have != e {
+		t.Errorf("want the wrapped Error, have %v", have)
+	}
+	if have := errcode.Convert(errors.New("mystery")); have.Code != errcode.Unknown || have.Message != "mystery" {
+		t.Errorf("want Unknown mystery, have %+v", have)
+	}
+	if have := errcode.Convert(nil); have != nil {
+		t.Errorf("want nil, have %v", have)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	if want, have := "NOT_FOUND", errcode.New(errcode.NotFound, "").Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "user 42 not found", errcode.Errorf(errcode.NotFound, "user %d not found", 42).Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestViolationsRoundTrip(t *testing.T) {
+	want := errcode.New(errcode.InvalidArgument, "bad signup").
+		WithViolation("name", "must not be empty").
+		WithViolation("age", "must not be negative")
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := &errcode.Error{}
+	if err := json.Unmarshal(b, have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("JSON: want %+v, have %+v", want, have)
+	}
+
+	have, ok := errcode.FromGRPCStatus(status.FromProto(status.Convert(want).Proto()))
+	if !ok {
+		t.Fatal("gRPC: want Error, have none")
+	}
+	if !reflect.DeepEqual(want.Violations, have.Violations) {
+		t.Errorf("gRPC: want %+v, have %+v", want.Violations, have.Violations)
+	}
+}
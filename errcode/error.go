@@ -0,0 +1,297 @@
+package errcode
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Header is the HTTP header, NATS header or AMQP header in which servers
+// send the Code of an Error they reply with. Its presence tells clients that
+// the reply holds an encoded Error.
+const Header = "X-Error-Code"
+
+// Domain is the domain of the errdetails.ErrorInfo attached to the gRPC
+// status of an Error. Its presence tells clients that the status represents
+// an Error.
+const Domain = "github.com/openmesh/kit/errcode"
+
+// Error is an error with a canonical Code, a message, and optional details.
+// It implements the interfaces each transport uses to encode errors.
+type Error struct {
+	Code       Code
+	Message    string
+	Details    map[string]string
+	Violations []Violation
+}
+
+// Violation describes why a single field of a request is invalid. It's
+// typically carried by an Error with the InvalidArgument code.
+type Violation struct {
+	Field       string `json:"field" xml:"field"`
+	Description string `json:"description" xml:"description"`
+}
+
+// New returns an Error with the given code and message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Errorf returns an Error with the given code, and a message formatted
+// according to a format specifier.
+func Errorf(code Code, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// WithDetail sets a detail of e, and returns e.
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// WithViolation adds a field violation to e, and returns e.
+func (e *Error) WithViolation(field, description string) *Error {
+	e.Violations = append(e.Violations, Violation{Field: field, Description: description})
+	return e
+}
+
+// Error implements the error interface. It returns the message, or the name
+// of the code if there's no message.
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Code.String()
+}
+
+// StatusCode implements the StatusCoder interface of the HTTP transport.
+func (e *Error) StatusCode() int {
+	return e.Code.HTTPStatus()
+}
+
+// Headers implements the Headerer interface of the HTTP transport.
+func (e *Error) Headers() http.Header {
+	return http.Header{Header: []string{e.Code.String()}}
+}
+
+// ErrorCode implements the ErrorCoder interface of the JSON-RPC transport.
+func (e *Error) ErrorCode() int {
+	return e.Code.JSONRPCCode()
+}
+
+// GRPCStatus allows the gRPC transport to report the error with its code.
+// The details are attached as an errdetails.ErrorInfo, and the violations, if
+// any, as an errdetails.BadRequest.
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(codes.Code(e.Code), e.Message)
+	if ds, err := s.WithDetails(&errdetails.ErrorInfo{
+		Reason:   e.Code.String(),
+		Domain:   Domain,
+		Metadata: e.Details,
+	}); err == nil {
+		s = ds
+	}
+	if len(e.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		if ds, err := s.WithDetails(br); err == nil {
+			s = ds
+		}
+	}
+	return s
+}
+
+type jsonError struct {
+	Code       string            `json:"code"`
+	Message    string            `json:"message,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	Violations []Violation       `json:"violations,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. The code is encoded by name.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonError{
+		Code:       e.Code.String(),
+		Message:    e.Message,
+		Details:    e.Details,
+		Violations: e.Violations,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Error) UnmarshalJSON(b []byte) error {
+	var j jsonError
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	code, ok := ParseCode(j.Code)
+	if !ok {
+		return fmt.Errorf("errcode: unknown code %q", j.Code)
+	}
+	*e = Error{Code: code, Message: j.Message, Details: j.Details, Violations: j.Violations}
+	return nil
+}
+
+// xmlError is how an Error is encoded in XML. The lists are held by
+// pointers, since encoding/xml writes empty parent elements otherwise.
+type xmlError struct {
+	Code       string         `xml:"code"`
+	Message    string         `xml:"message,omitempty"`
+	Details    *xmlDetails    `xml:"details"`
+	Violations *xmlViolations `xml:"violations"`
+}
+
+type xmlDetails struct {
+	Detail []xmlDetail `xml:"detail"`
+}
+
+type xmlViolations struct {
+	Violation []Violation `xml:"violation"`
+}
+
+type xmlDetail struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// MarshalXML implements xml.Marshaler. The code is encoded by name, and the
+// details as detail elements with a key attribute, sorted by key.
+func (e *Error) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	x := xmlError{Code: e.Code.String(), Message: e.Message}
+	if len(e.Details) > 0 {
+		x.Details = &xmlDetails{}
+		for k, v := range e.Details {
+			x.Details.Detail = append(x.Details.Detail, xmlDetail{Key: k, Value: v})
+		}
+		sort.Slice(x.Details.Detail, func(i, j int) bool { return x.Details.Detail[i].Key < x.Details.Detail[j].Key })
+	}
+	if len(e.Violations) > 0 {
+		x.Violations = &xmlViolations{e.Violations}
+	}
+	return enc.EncodeElement(x, start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler.
+func (e *Error) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var x xmlError
+	if err := dec.DecodeElement(&x, &start); err != nil {
+		return err
+	}
+	code, ok := ParseCode(x.Code)
+	if !ok {
+		return fmt.Errorf("errcode: unknown code %q", x.Code)
+	}
+	*e = Error{Code: code, Message: x.Message}
+	if x.Details != nil {
+		for _, d := range x.Details.Detail {
+			e.WithDetail(d.Key, d.Value)
+		}
+	}
+	if x.Violations != nil {
+		e.Violations = x.Violations.Violation
+	}
+	return nil
+}
+
+// OpenAPISchema returns the JSON Schema of an encoded Error, for the OpenAPI
+// documents generated by the HTTP transport.
+func (e *Error) OpenAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code"},
+		"properties": map[string]interface{}{
+			"code":    map[string]interface{}{"type": "string", "enum": append([]string(nil), codeNames[:]...)},
+			"message": map[string]interface{}{"type": "string"},
+			"details": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"violations": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"field", "description"},
+					"properties": map[string]interface{}{
+						"field":       map[string]interface{}{"type": "string"},
+						"description": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// CodeOf returns the Code of err. That's the code of an Error in err's chain,
+// or the code of its gRPC status, or a code derived from the context errors.
+// It's OK if err is nil, and Unknown if no code can be found.
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	if s, ok := status.FromError(err); ok {
+		return Code(s.Code())
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	}
+	return Unknown
+}
+
+// Convert returns the Error in err's chain, or else a new Error with the
+// code returned by CodeOf and err's message. It returns nil if err is nil.
+func Convert(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: CodeOf(err), Message: err.Error()}
+}
+
+// FromGRPCStatus returns the Error represented by s, if s was produced by an
+// Error's GRPCStatus method.
+func FromGRPCStatus(s *status.Status) (*Error, bool) {
+	var e *Error
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			e = &Error{Code: Code(s.Code()), Message: s.Message(), Details: info.Metadata}
+			break
+		}
+	}
+	if e == nil {
+		return nil, false
+	}
+	for _, detail := range s.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				e.Violations = append(e.Violations, Violation{Field: v.Field, Description: v.Description})
+			}
+		}
+	}
+	return e, true
+}
@@ -0,0 +1,70 @@
+package fallback
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Path identifies which endpoint served a response.
+type Path int
+
+const (
+	// Primary means the response was served by the wrapped endpoint.
+	Primary Path = iota
+
+	// Fallback means the response was served by the fallback endpoint.
+	Fallback
+)
+
+// String implements fmt.Stringer.
+func (p Path) String() string {
+	switch p {
+	case Primary:
+		return "primary"
+	case Fallback:
+		return "fallback"
+	default:
+		return "unknown"
+	}
+}
+
+type contextKey int
+
+const recorderKey contextKey = iota
+
+// recorder holds the Path recorded for a request, plus one, so that the zero
+// value means nothing has been recorded.
+type recorder struct{ path atomic.Int32 }
+
+// NewContext returns a context in which the fallback Middleware records which
+// Path served the request. Pass it to the endpoint, and call PathFromContext
+// once it returns, e.g. to label metrics.
+//
+// If several fallback Middlewares are nested, Fallback is recorded if any of
+// them fell back.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recorderKey, &recorder{})
+}
+
+// PathFromContext returns the Path recorded in a context created by
+// NewContext. It returns false if no Path has been recorded.
+func PathFromContext(ctx context.Context) (Path, bool) {
+	r, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return 0, false
+	}
+	p := r.path.Load()
+	return Path(p - 1), p != 0
+}
+
+func record(ctx context.Context, p Path) {
+	r, ok := ctx.Value(recorderKey).(*recorder)
+	if !ok {
+		return
+	}
+	if p == Fallback {
+		r.path.Store(int32(Fallback) + 1)
+		return
+	}
+	r.path.CompareAndSwap(0, int32(p)+1)
+}
@@ -0,0 +1,9 @@
+// Package fallback implements graceful degradation for endpoints.
+//
+// When a dependency is unavailable, because a circuit breaker is open, a rate
+// limit is exceeded, a deadline has passed or no instances are left, it's
+// often better to serve a degraded response, e.g. from a cache or a static
+// default, than to fail the request. Middleware calls a fallback endpoint in
+// exactly those cases, and records which path served the response so that it
+// can be reported by metrics.
+package fallback
@@ -0,0 +1,117 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/sony/gobreaker"
+	"github.com/streadway/handy/breaker"
+
+	"github.com/openmesh/kit/circuitbreaker"
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/ratelimit"
+	"github.com/openmesh/kit/sd/lb"
+)
+
+// Middleware returns an endpoint.Middleware that calls fallback whenever the
+// wrapped endpoint fails with an error selected by the options. By default,
+// that's any error for which Unavailable returns true. Business errors and
+// other failures of the wrapped endpoint are returned as they are.
+//
+// If the context's deadline has passed by the time the wrapped endpoint fails,
+// the fallback is called with a context that carries the same values, but a
+// fresh deadline, set by the Timeout option, so that it may still do I/O.
+//
+// If the fallback fails too, its error is returned.
+func Middleware[Request, Response any](fallback endpoint.Endpoint[Request, Response], options ...Option) endpoint.Middleware[Request, Response] {
+	m := middleware{match: Unavailable, timeout: time.Second}
+	for _, option := range options {
+		option(&m)
+	}
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			response, err := next(ctx, request)
+			if err == nil || !m.match(err) {
+				record(ctx, Primary)
+				return response, err
+			}
+			record(ctx, Fallback)
+			if m.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), m.timeout)
+				defer cancel()
+			}
+			return fallback(ctx, request)
+		}
+	}
+}
+
+type middleware struct {
+	match   func(error) bool
+	timeout time.Duration
+}
+
+// Option sets an optional parameter for the fallback Middleware.
+type Option func(*middleware)
+
+// On makes the Middleware fall back only when the error returned by the
+// wrapped endpoint matches one of errs, as reported by errors.Is.
+func On(errs ...error) Option {
+	return func(m *middleware) {
+		m.match = func(err error) bool {
+			for _, target := range errs {
+				if errors.Is(err, target) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+}
+
+// When makes the Middleware fall back whenever f returns true for the error
+// returned by the wrapped endpoint.
+func When(f func(err error) bool) Option {
+	return func(m *middleware) { m.match = f }
+}
+
+// Timeout sets the deadline of the fallback, when it's called after the
+// deadline of the request has passed. Zero makes the fallback run with the
+// expired context. By default, it's 1 second.
+func Timeout(d time.Duration) Option {
+	return func(m *middleware) { m.timeout = d }
+}
+
+// Unavailable reports whether err indicates that the wrapped endpoint
+// couldn't serve the request at all, rather than that the request failed.
+// That's the case when err is, or wraps:
+//
+//   - a circuit breaker rejection from any of the breakers in package
+//     circuitbreaker;
+//   - ratelimit.ErrLimited;
+//   - context.DeadlineExceeded;
+//   - lb.ErrNoEndpoints.
+func Unavailable(err error) bool {
+	for _, target := range unavailable {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+var unavailable = []error{
+	circuitbreaker.ErrOpen,
+	circuitbreaker.ErrTooManyProbes,
+	gobreaker.ErrOpenState,
+	gobreaker.ErrTooManyRequests,
+	hystrix.ErrCircuitOpen,
+	hystrix.ErrMaxConcurrency,
+	hystrix.ErrTimeout,
+	breaker.ErrCircuitOpen,
+	ratelimit.ErrLimited,
+	context.DeadlineExceeded,
+	lb.ErrNoEndpoints,
+}
@@ -0,0 +1,162 @@
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/circuitbreaker"
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/fallback"
+	"github.com/openmesh/kit/ratelimit"
+	"github.com/openmesh/kit/sd"
+	"github.com/openmesh/kit/sd/lb"
+)
+
+func failWith(err error) endpoint.Endpoint[string, string] {
+	return func(context.Context, string) (string, error) { return "", err }
+}
+
+func degraded(_ context.Context, request string) (string, error) {
+	return "degraded " + request, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		primary  endpoint.Endpoint[string, string]
+		response string
+		err      error
+		path     fallback.Path
+	}{
+		{
+			name:     "success",
+			primary:  func(_ context.Context, request string) (string, error) { return "fresh " + request, nil },
+			response: "fresh hello",
+			path:     fallback.Primary,
+		},
+		{
+			name:    "other error",
+			primary: failWith(errors.New("bad request")),
+			err:     errors.New("bad request"),
+			path:    fallback.Primary,
+		},
+		{
+			name:     "breaker open",
+			primary:  failWith(circuitbreaker.ErrOpen),
+			response: "degraded hello",
+			path:     fallback.Fallback,
+		},
+		{
+			name:     "rate limited",
+			primary:  failWith(ratelimit.Error{}),
+			response: "degraded hello",
+			path:     fallback.Fallback,
+		},
+		{
+			name:     "deadline exceeded",
+			primary:  failWith(fmt.Errorf("calling upstream: %w", context.DeadlineExceeded)),
+			response: "degraded hello",
+			path:     fallback.Fallback,
+		},
+		{
+			name:     "no endpoints",
+			primary:  lb.Retry[string, string](3, time.Second, lb.NewRoundRobin[string, string](sd.FixedEndpointer[string, string]{})),
+			response: "degraded hello",
+			path:     fallback.Fallback,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			ctx := fallback.NewContext(context.Background())
+			response, err := fallback.Middleware(degraded)(testcase.primary)(ctx, "hello")
+			if want, have := testcase.response, response; want != have {
+				t.Errorf("response: want %q, have %q", want, have)
+			}
+			if want, have := fmt.Sprint(testcase.err), fmt.Sprint(err); want != have {
+				t.Errorf("error: want %s, have %s", want, have)
+			}
+			if path, ok := fallback.PathFromContext(ctx); !ok || testcase.path != path {
+				t.Errorf("path: want %v, have %v (%v)", testcase.path, path, ok)
+			}
+		})
+	}
+}
+
+func TestOn(t *testing.T) {
+	errStale := errors.New("stale")
+	mw := fallback.Middleware(degraded, fallback.On(errStale))
+
+	if response, _ := mw(failWith(errStale))(context.Background(), "hello"); response != "degraded hello" {
+		t.Errorf("want fallback, have %q", response)
+	}
+	if _, err := mw(failWith(circuitbreaker.ErrOpen))(context.Background(), "hello"); err != circuitbreaker.ErrOpen {
+		t.Errorf("want %v, have %v", circuitbreaker.ErrOpen, err)
+	}
+}
+
+func TestExpiredDeadline(t *testing.T) {
+	var (
+		primary = func(ctx context.Context, _ string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		fallbackDeadline = func(ctx context.Context, _ string) (string, error) {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			deadline, _ := ctx.Deadline()
+			return fmt.Sprint(time.Until(deadline).Round(time.Second)), nil
+		}
+	)
+	for _, testcase := range []struct {
+		options  []fallback.Option
+		response string
+		err      error
+	}{
+		{nil, "1s", nil},
+		{[]fallback.Option{fallback.Timeout(5 * time.Second)}, "5s", nil},
+		{[]fallback.Option{fallback.Timeout(0)}, "", context.DeadlineExceeded},
+	} {
+		ctx, cancel := context.WithTimeout(fallback.NewContext(context.Background()), time.Millisecond)
+		response, err := fallback.Middleware(fallbackDeadline, testcase.options...)(primary)(ctx, "hello")
+		cancel()
+		if want, have := testcase.response, response; want != have {
+			t.Errorf("response: want %q, have %q", want, have)
+		}
+		if want, have := testcase.err, err; want != have {
+			t.Errorf("error: want %v, have %v", want, have)
+		}
+		if path, ok := fallback.PathFromContext(ctx); !ok || path != fallback.Fallback {
+			t.Errorf("path: want %v, have %v (%v)", fallback.Fallback, path, ok)
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	mw := fallback.Middleware(degraded, fallback.When(func(error) bool { return true }))
+	if response, _ := mw(failWith(errors.New("anything")))(context.Background(), "hello"); response != "degraded hello" {
+		t.Errorf("want fallback, have %q", response)
+	}
+}
+
+func TestNestedPath(t *testing.T) {
+	var (
+		inner = fallback.Middleware(degraded)(failWith(circuitbreaker.ErrOpen))
+		outer = fallback.Middleware(failWith(errors.New("unreachable")))(inner)
+		ctx   = fallback.NewContext(context.Background())
+	)
+	if _, err := outer(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if path, ok := fallback.PathFromContext(ctx); !ok || path != fallback.Fallback {
+		t.Errorf("want %v, have %v (%v)", fallback.Fallback, path, ok)
+	}
+}
+
+func TestPathFromContextWithoutRecorder(t *testing.T) {
+	if _, ok := fallback.PathFromContext(context.Background()); ok {
+		t.Error("want no path")
+	}
+}
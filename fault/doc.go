@@ -0,0 +1,12 @@
+// Package fault injects faults into endpoints, to rehearse outages.
+//
+// An Injector holds a Config of faults, each applied to a percentage of the
+// requests it selects: added latency, an error returned instead of calling
+// the endpoint, or a response aborted after the endpoint has run. The Config
+// can be replaced at any time, for example from an admin endpoint, and every
+// injected fault can be counted with a metrics.Counter.
+//
+// Requests are selected by a predicate over their context. Requested selects
+// those that opted in with the InjectHeader header, see HTTPToContext and
+// GRPCToContext, so that faults only hit the traffic of a rehearsal.
+package fault
@@ -0,0 +1,158 @@
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/metrics"
+)
+
+var (
+	// ErrInjected is returned by error faults when the Config doesn't set an
+	// error of its own.
+	ErrInjected error = faultError("fault: injected error")
+
+	// ErrAborted is returned by abort faults, in place of the response of the
+	// endpoint.
+	ErrAborted error = faultError("fault: response aborted")
+)
+
+type faultError string
+
+func (e faultError) Error() string { return string(e) }
+
+// As makes e match a new *errcode.Error with the errcode.Unavailable code and
+// the same message, with errors.As, so that the error encoders of the
+// transports encode injected faults as outages.
+func (e faultError) As(target interface{}) bool {
+	if p, ok := target.(**errcode.Error); ok {
+		*p = errcode.New(errcode.Unavailable, string(e))
+		return true
+	}
+	return false
+}
+
+// Config describes the faults to inject. Percentages range from 0 to 100, and
+// each fault is decided independently for every selected request. The zero
+// value injects no faults.
+type Config struct {
+	// Delay is the latency added before the endpoint is called, to
+	// DelayPercent of the requests. The delay is cut short if the request's
+	// context is done.
+	Delay        time.Duration
+	DelayPercent float64
+
+	// Error is returned instead of calling the endpoint, for ErrorPercent of
+	// the requests. If it's nil, ErrInjected is returned.
+	Error        error
+	ErrorPercent float64
+
+	// AbortPercent of the requests call the endpoint, but have its response
+	// replaced by ErrAborted. This simulates replies lost after the work was
+	// done.
+	AbortPercent float64
+}
+
+// Injector injects the faults of its Config into the requests selected by
+// its predicate. It's safe for concurrent use, and its Config may be changed
+// while requests are in flight.
+type Injector struct {
+	config  atomic.Pointer[Config]
+	when    func(context.Context) bool
+	counter metrics.Counter
+	random  func() float64
+}
+
+// Option sets an optional parameter for an Injector.
+type Option func(*Injector)
+
+// When selects the requests that faults may be injected into. By default,
+// all requests are selected.
+func When(f func(ctx context.Context) bool) Option {
+	return func(i *Injector) { i.when = f }
+}
+
+// WithCounter counts the injected faults. The counter is called with a
+// "fault" label set to "delay", "error" or "abort".
+func WithCounter(c metrics.Counter) Option {
+	return func(i *Injector) { i.counter = c }
+}
+
+// NewInjector returns an Injector with the given Config.
+func NewInjector(config Config, options ...Option) *Injector {
+	i := &Injector{
+		when:   func(context.Context) bool { return true },
+		random: rand.Float64,
+	}
+	for _, option := range options {
+		option(i)
+	}
+	i.Set(config)
+	return i
+}
+
+// Set replaces the Config of the Injector. It applies to requests that start
+// after it returns.
+func (i *Injector) Set(config Config) {
+	i.config.Store(&config)
+}
+
+// Config returns the current Config of the Injector.
+func (i *Injector) Config() Config {
+	return *i.config.Load()
+}
+
+// Middleware returns an endpoint.Middleware that injects the faults of the
+// Injector into the wrapped endpoint.
+func Middleware[Request, Response any](i *Injector) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			if !i.when(ctx) {
+				return next(ctx, request)
+			}
+			config := i.config.Load()
+
+			if i.roll(config.DelayPercent) {
+				i.count("delay")
+				t := time.NewTimer(config.Delay)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return *new(Response), ctx.Err()
+				}
+			}
+
+			if i.roll(config.ErrorPercent) {
+				i.count("error")
+				if config.Error != nil {
+					return *new(Response), config.Error
+				}
+				return *new(Response), ErrInjected
+			}
+
+			if i.roll(config.AbortPercent) {
+				i.count("abort")
+				next(ctx, request)
+				return *new(Response), ErrAborted
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// roll reports whether a fault with the given percentage should be injected.
+func (i *Injector) roll(percent float64) bool {
+	return percent > 0 && i.random()*100 < percent
+}
+
+func (i *Injector) count(fault string) {
+	if i.counter != nil {
+		i.counter.With("fault", fault).Add(1)
+	}
+}
@@ -0,0 +1,170 @@
+package fault_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/fault"
+	"github.com/openmesh/kit/metrics"
+)
+
+// counter records the label values it's called with.
+type counter struct {
+	counts map[string]float64
+	label  string
+}
+
+func (c *counter) With(labelValues ...string) metrics.Counter {
+	return &counter{counts: c.counts, label: labelValues[len(labelValues)-1]}
+}
+
+func (c *counter) Add(delta float64) { c.counts[c.label] += delta }
+
+func TestNoFaults(t *testing.T) {
+	var calls int
+	e := fault.Middleware[interface{}, interface{}](fault.NewInjector(fault.Config{}))(func(context.Context, interface{}) (interface{}, error) {
+		calls++
+		return "ok", nil
+	})
+	for i := 0; i < 100; i++ {
+		if response, err := e(context.Background(), struct{}{}); err != nil || response != "ok" {
+			t.Fatalf("want ok, nil, have %v, %v", response, err)
+		}
+	}
+	if want, have := 100, calls; want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	custom := errors.New("backend on fire")
+	for _, testcase := range []struct {
+		name   string
+		config fault.Config
+		err    error
+		called bool
+	}{
+		{"error", fault.Config{ErrorPercent: 100}, fault.ErrInjected, false},
+		{"custom error", fault.Config{ErrorPercent: 100, Error: custom}, custom, false},
+		{"abort", fault.Config{AbortPercent: 100}, fault.ErrAborted, true},
+	} {
+		var (
+			called bool
+			c      = &counter{counts: map[string]float64{}}
+			i      = fault.NewInjector(testcase.config, fault.WithCounter(c))
+			e      = fault.Middleware[interface{}, interface{}](i)(func(context.Context, interface{}) (interface{}, error) {
+				called = true
+				return "ok", nil
+			})
+		)
+		if _, err := e(context.Background(), struct{}{}); err != testcase.err {
+			t.Errorf("%s: want %v, have %v", testcase.name, testcase.err, err)
+		}
+		if want, have := testcase.called, called; want != have {
+			t.Errorf("%s: want called %v, have %v", testcase.name, want, have)
+		}
+		kind := "error"
+		if testcase.config.AbortPercent > 0 {
+			kind = "abort"
+		}
+		if want, have := 1.0, c.counts[kind]; want != have {
+			t.Errorf("%s: want %v %s faults counted, have %v", testcase.name, want, kind, have)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	var (
+		delay = 20 * time.Millisecond
+		e     = fault.Middleware[interface{}, interface{}](fault.NewInjector(fault.Config{
+			Delay:        delay,
+			DelayPercent: 100,
+		}))(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+	)
+	start := time.Now()
+	if _, err := e(context.Background(), struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("want at least %v, have %v", delay, elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	e = fault.Middleware[interface{}, interface{}](fault.NewInjector(fault.Config{
+		Delay:        time.Hour,
+		DelayPercent: 100,
+	}))(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+	if _, err := e(ctx, struct{}{}); err != context.DeadlineExceeded {
+		t.Errorf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	var (
+		i = fault.NewInjector(fault.Config{ErrorPercent: 25})
+		e = fault.Middleware[interface{}, interface{}](i)(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+		n = 10000
+	)
+	var failed int
+	for j := 0; j < n; j++ {
+		if _, err := e(context.Background(), struct{}{}); err != nil {
+			failed++
+		}
+	}
+	if ratio := float64(failed) / float64(n); ratio < 0.2 || ratio > 0.3 {
+		t.Errorf("want about 25%% failed, have %.1f%%", ratio*100)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for _, err := range []error{fault.ErrInjected, fault.ErrAborted} {
+		var e *errcode.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%v: want an *errcode.Error", err)
+		}
+		if want, have := errcode.Unavailable, e.Code; want != have {
+			t.Errorf("%v: want %v, have %v", err, want, have)
+		}
+		e.WithDetail("mutated", "true")
+
+		var fresh *errcode.Error
+		errors.As(err, &fresh)
+		if fresh.Details != nil {
+			t.Errorf("%v: want a new *errcode.Error each time, have %v", err, fresh.Details)
+		}
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	var (
+		i = fault.NewInjector(fault.Config{ErrorPercent: 100})
+		e = fault.Middleware[interface{}, interface{}](i)(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+	)
+	if _, err := e(context.Background(), struct{}{}); err != fault.ErrInjected {
+		t.Fatalf("want %v, have %v", fault.ErrInjected, err)
+	}
+	i.Set(fault.Config{})
+	if _, err := e(context.Background(), struct{}{}); err != nil {
+		t.Fatalf("want no error after reconfiguring, have %v", err)
+	}
+	if want, have := (fault.Config{}), i.Config(); want != have {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestRequested(t *testing.T) {
+	var (
+		i = fault.NewInjector(fault.Config{ErrorPercent: 100}, fault.When(fault.Requested))
+		e = fault.Middleware[interface{}, interface{}](i)(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+	)
+	if _, err := e(context.Background(), struct{}{}); err != nil {
+		t.Errorf("want no fault without opt-in, have %v", err)
+	}
+	if _, err := e(fault.NewContext(context.Background()), struct{}{}); err != fault.ErrInjected {
+		t.Errorf("want %v with opt-in, have %v", fault.ErrInjected, err)
+	}
+}
@@ -0,0 +1,85 @@
+package fault
+
+import (
+	"context"
+	stdhttp "net/http"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openmesh/kit/transport/grpc"
+	"github.com/openmesh/kit/transport/http"
+)
+
+// InjectHeader is the HTTP header, and the gRPC metadata key, with which a
+// request opts in to fault injection. Its value is a boolean, as accepted by
+// strconv.ParseBool.
+const InjectHeader = "X-Fault-Inject"
+
+// grpcInjectKey is InjectHeader in the lower case required by HTTP/2.
+const grpcInjectKey = "x-fault-inject"
+
+type contextKey int
+
+const injectKey contextKey = 0
+
+// NewContext returns a copy of ctx that opts in to fault injection.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, injectKey, true)
+}
+
+// Requested reports whether ctx opted in to fault injection. It's meant to be
+// passed to When.
+func Requested(ctx context.Context) bool {
+	requested, _ := ctx.Value(injectKey).(bool)
+	return requested
+}
+
+// HTTPToContext moves the opt-in from request header to context. Particularly
+// useful for servers.
+func HTTPToContext() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		if inject, _ := strconv.ParseBool(r.Header.Get(InjectHeader)); inject {
+			return NewContext(ctx)
+		}
+		return ctx
+	}
+}
+
+// ContextToHTTP moves the opt-in from context to request header, so that
+// faults are also injected further down the call chain. Particularly useful
+// for clients.
+func ContextToHTTP() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		if Requested(ctx) {
+			r.Header.Set(InjectHeader, "true")
+		}
+		return ctx
+	}
+}
+
+// GRPCToContext moves the opt-in from gRPC metadata to context. Particularly
+// useful for servers.
+func GRPCToContext() grpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		values := md.Get(grpcInjectKey)
+		if len(values) == 0 {
+			return ctx
+		}
+		if inject, _ := strconv.ParseBool(values[0]); inject {
+			return NewContext(ctx)
+		}
+		return ctx
+	}
+}
+
+// ContextToGRPC moves the opt-in from context to gRPC metadata. Particularly
+// useful for clients.
+func ContextToGRPC() grpc.ClientRequestFunc {
+	return func(ctx context.Context, md *metadata.MD) context.Context {
+		if Requested(ctx) {
+			(*md)[grpcInjectKey] = []string{"true"}
+		}
+		return ctx
+	}
+}
@@ -0,0 +1,40 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHTTPRoundTrip(t *testing.T) {
+	r := &http.Request{Header: http.Header{}}
+	ContextToHTTP()(NewContext(context.Background()), r)
+	if want, have := "true", r.Header.Get(InjectHeader); want != have {
+		t.Fatalf("header: want %q, have %q", want, have)
+	}
+	if !Requested(HTTPToContext()(context.Background(), r)) {
+		t.Fatal("want opt-in carried to context")
+	}
+}
+
+func TestHTTPToContextDeclined(t *testing.T) {
+	for _, value := range []string{"", "false", "0", "please"} {
+		r := &http.Request{Header: http.Header{InjectHeader: []string{value}}}
+		if Requested(HTTPToContext()(context.Background(), r)) {
+			t.Errorf("%q: want no opt-in", value)
+		}
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	md := metadata.MD{}
+	ContextToGRPC()(NewContext(context.Background()), &md)
+	if !Requested(GRPCToContext()(context.Background(), md)) {
+		t.Fatal("want opt-in carried to context")
+	}
+	if Requested(GRPCToContext()(context.Background(), metadata.MD{})) {
+		t.Fatal("want no opt-in without metadata")
+	}
+}
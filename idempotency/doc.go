@@ -0,0 +1,15 @@
+// Package idempotency makes endpoints with side effects safe to retry.
+//
+// A client attaches an idempotency key to a request, and keeps it across
+// retries of that request. Middleware, on the server, runs the endpoint for
+// the first request with a given key and saves its result in a Store. Later
+// requests with the same key get the saved result replayed, without running
+// the endpoint again, or ErrInFlight if the first one hasn't completed yet.
+// A request reusing a key for different content, as told by its fingerprint,
+// gets ErrMismatch. Scope looks keys up per principal rather than globally.
+//
+// Keys are carried in the Idempotency-Key HTTP header or gRPC metadata, see
+// HTTPToContext and GRPCToContext. On the client, Generate gives each request
+// a key before it's handed to lb.Retry, and ContextToHTTP or ContextToGRPC
+// send it along with every attempt.
+package idempotency
@@ -0,0 +1,158 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+)
+
+var (
+	// ErrInFlight is returned for a request whose key was reserved by a
+	// request that hasn't completed yet. It's reported as a 409 Conflict, or
+	// codes.Aborted, and may be retried later.
+	ErrInFlight = errcode.New(errcode.Aborted, "idempotency: a request with the same key is in flight")
+
+	// ErrMissingKey is returned for requests without a key, when keys are
+	// required.
+	ErrMissingKey = errcode.New(errcode.InvalidArgument, "idempotency: missing idempotency key")
+
+	// ErrMismatch is returned for a request whose key was reserved by a
+	// request with a different fingerprint, i.e. when a client reuses a key
+	// for another request. It's reported as a 409 Conflict, or
+	// codes.AlreadyExists.
+	ErrMismatch = errcode.New(errcode.AlreadyExists, "idempotency: key reused for a different request")
+)
+
+type options struct {
+	required    bool
+	keep        func(error) bool
+	fingerprint func(request interface{}) (string, error)
+	scope       func(context.Context) string
+}
+
+// Option sets an optional parameter for Middleware.
+type Option func(*options)
+
+// Required rejects requests without an idempotency key with ErrMissingKey.
+// By default, they're passed to the endpoint as is.
+func Required() Option {
+	return func(o *options) { o.required = true }
+}
+
+// SaveErrors saves the errors for which keep returns true, so that they're
+// replayed like responses. By default, errors aren't saved: the key is
+// released, and a retry runs the endpoint again.
+func SaveErrors(keep func(error) bool) Option {
+	return func(o *options) { o.keep = keep }
+}
+
+// Fingerprint sets the function that identifies the content of a request.
+// A request whose key was reserved by a request with another fingerprint
+// fails with ErrMismatch. By default, the fingerprint is the SHA-256 hash of
+// the JSON encoding of the request, and requests that can't be encoded as
+// JSON fail; pass a function returning "" to turn the check off.
+func Fingerprint(f func(request interface{}) (string, error)) Option {
+	return func(o *options) { o.fingerprint = f }
+}
+
+// Scope sets the function that returns the principal a request is made on
+// behalf of, e.g. the subject of its JWT claims. Keys are then looked up per
+// principal, so that clients can't see each other's responses by guessing
+// keys. By default, keys are global.
+func Scope(f func(context.Context) string) Option {
+	return func(o *options) { o.scope = f }
+}
+
+// jsonFingerprint is the default fingerprint.
+func jsonFingerprint(request interface{}) (string, error) {
+	buf, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Middleware returns an endpoint.Middleware that runs the wrapped endpoint at
+// most once per idempotency key, as carried in the context. The result of
+// the first request is saved in the store, and replayed for later requests
+// with the same key and fingerprint. Keys aren't scoped by endpoint, so each
+// endpoint needs a store of its own.
+//
+// If the store fails, the error is returned and the endpoint isn't called.
+func Middleware[Request, Response any](store Store[Response], opts ...Option) endpoint.Middleware[Request, Response] {
+	o := options{fingerprint: jsonFingerprint}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (response Response, err error) {
+			key, ok := FromContext(ctx)
+			if !ok {
+				if o.required {
+					return *new(Response), ErrMissingKey
+				}
+				return next(ctx, request)
+			}
+			if o.scope != nil {
+				// Quoting the principal keeps "a" + "b/c" apart from "a/b" + "c".
+				key = strconv.Quote(o.scope(ctx)) + key
+			}
+			fingerprint, err := o.fingerprint(request)
+			if err != nil {
+				return *new(Response), err
+			}
+
+			record, reserved, err := store.Reserve(ctx, key, fingerprint)
+			if err != nil {
+				return *new(Response), err
+			}
+			if !reserved {
+				if record.Fingerprint != fingerprint {
+					return *new(Response), ErrMismatch
+				}
+				if !record.Done {
+					return *new(Response), ErrInFlight
+				}
+				return record.Response, record.Err
+			}
+
+			// The store is updated even if the caller has gone away, and
+			// the key is released if next panics.
+			storeCtx := context.WithoutCancel(ctx)
+			saved := false
+			defer func() {
+				if !saved {
+					store.Release(storeCtx, key)
+				}
+			}()
+
+			response, err = next(ctx, request)
+			if err != nil && (o.keep == nil || !o.keep(err)) {
+				return response, err
+			}
+			saved = store.Save(storeCtx, key, Record[Response]{Done: true, Fingerprint: fingerprint, Response: response, Err: err}) == nil
+			return response, err
+		}
+	}
+}
+
+// Generate returns a client-side endpoint.Middleware that gives each request
+// a new idempotency key, unless its context already carries one. Wrap the
+// endpoint returned by lb.Retry with it, so that all attempts of a request
+// share the key.
+func Generate[Request, Response any]() endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			if _, ok := FromContext(ctx); !ok {
+				ctx = NewContext(ctx, NewKey())
+			}
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/idempotency"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+// counter is an endpoint that returns the number of times it's been called.
+type counter struct{ calls int }
+
+func (c *counter) endpoint(context.Context, interface{}) (int, error) {
+	c.calls++
+	return c.calls, nil
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		c   counter
+		e   = idempotency.Middleware[interface{}, int](idempotency.NewMemory[int](time.Minute))(c.endpoint)
+		ctx = idempotency.NewContext(context.Background(), "abc")
+	)
+	for i := 0; i < 3; i++ {
+		if response, err := e(ctx, struct{}{}); err != nil || response != 1 {
+			t.Fatalf("want 1, nil, have %v, %v", response, err)
+		}
+	}
+	if response, _ := e(idempotency.NewContext(context.Background(), "def"), struct{}{}); response != 2 {
+		t.Errorf("want a new key to run the endpoint, have %v", response)
+	}
+	if response, _ := e(context.Background(), struct{}{}); response != 3 {
+		t.Errorf("want a request without key to run the endpoint, have %v", response)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	var (
+		entered = make(chan struct{})
+		release = make(chan struct{})
+		e       = idempotency.Middleware[interface{}, interface{}](idempotency.NewMemory[interface{}](time.Minute))(func(context.Context, interface{}) (interface{}, error) {
+			close(entered)
+			<-release
+			return "ok", nil
+		})
+		ctx  = idempotency.NewContext(context.Background(), "abc")
+		done = make(chan error)
+	)
+	go func() { _, err := e(ctx, struct{}{}); done <- err }()
+	<-entered
+
+	_, err := e(ctx, struct{}{})
+	if err != idempotency.ErrInFlight {
+		t.Errorf("want %v, have %v", idempotency.ErrInFlight, err)
+	}
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), err, rec)
+	if want, have := http.StatusConflict, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var (
+		fail     = errors.New("payment declined")
+		calls    int
+		endpoint = func(context.Context, interface{}) (interface{}, error) {
+			calls++
+			return nil, fail
+		}
+		ctx = idempotency.NewContext(context.Background(), "abc")
+	)
+
+	e := idempotency.Middleware[interface{}, interface{}](idempotency.NewMemory[interface{}](time.Minute))(endpoint)
+	e(ctx, struct{}{})
+	e(ctx, struct{}{})
+	if want, have := 2, calls; want != have {
+		t.Errorf("errors should release the key: want %d calls, have %d", want, have)
+	}
+
+	calls = 0
+	e = idempotency.Middleware[interface{}, interface{}](
+		idempotency.NewMemory[interface{}](time.Minute),
+		idempotency.SaveErrors(func(err error) bool { return err == fail }),
+	)(endpoint)
+	e(ctx, struct{}{})
+	if _, err := e(ctx, struct{}{}); err != fail {
+		t.Errorf("want %v replayed, have %v", fail, err)
+	}
+	if want, have := 1, calls; want != have {
+		t.Errorf("saved errors should be replayed: want %d calls, have %d", want, have)
+	}
+}
+
+func TestPanicReleasesKey(t *testing.T) {
+	var (
+		panicking = true
+		e         = idempotency.Middleware[interface{}, interface{}](idempotency.NewMemory[interface{}](time.Minute))(func(context.Context, interface{}) (interface{}, error) {
+			if panicking {
+				panic("boom")
+			}
+			return "ok", nil
+		})
+		ctx = idempotency.NewContext(context.Background(), "abc")
+	)
+	func() {
+		defer func() { recover() }()
+		e(ctx, struct{}{})
+	}()
+	panicking = false
+	if response, err := e(ctx, struct{}{}); err != nil || response != "ok" {
+		t.Errorf("want ok, nil, have %v, %v", response, err)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	e := idempotency.Middleware[interface{}, int](idempotency.NewMemory[int](time.Minute), idempotency.Required())((&counter{}).endpoint)
+	if _, err := e(context.Background(), struct{}{}); err != idempotency.ErrMissingKey {
+		t.Errorf("want %v, have %v", idempotency.ErrMissingKey, err)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	var (
+		c   counter
+		e   = idempotency.Middleware[interface{}, int](idempotency.NewMemory[int](time.Minute))(c.endpoint)
+		ctx = idempotency.NewContext(context.Background(), "abc")
+	)
+	e(ctx, map[string]int{"amount": 10})
+	_, err := e(ctx, map[string]int{"amount": 20})
+	if err != idempotency.ErrMismatch {
+		t.Fatalf("want %v, have %v", idempotency.ErrMismatch, err)
+	}
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), err, rec)
+	if want, have := http.StatusConflict, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if response, err := e(ctx, map[string]int{"amount": 10}); err != nil || response != 1 {
+		t.Errorf("want 1, nil replayed, have %v, %v", response, err)
+	}
+
+	e = idempotency.Middleware[interface{}, int](
+		idempotency.NewMemory[int](time.Minute),
+		idempotency.Fingerprint(func(interface{}) (string, error) { return "", nil }),
+	)(c.endpoint)
+	e(ctx, 1)
+	if _, err := e(ctx, 2); err != nil {
+		t.Errorf("want fingerprints ignored, have %v", err)
+	}
+}
+
+type principalKey struct{}
+
+func TestScope(t *testing.T) {
+	var (
+		c     counter
+		scope = func(ctx context.Context) string { s, _ := ctx.Value(principalKey{}).(string); return s }
+		e     = idempotency.Middleware[interface{}, int](idempotency.NewMemory[int](time.Minute), idempotency.Scope(scope))(c.endpoint)
+		alice = context.WithValue(idempotency.NewContext(context.Background(), "abc"), principalKey{}, "alice")
+		bob   = context.WithValue(idempotency.NewContext(context.Background(), "abc"), principalKey{}, "bob")
+	)
+	e(alice, struct{}{})
+	if response, _ := e(bob, struct{}{}); response != 2 {
+		t.Errorf("want the same key of another principal to run the endpoint, have %v", response)
+	}
+	if response, _ := e(alice, struct{}{}); response != 1 {
+		t.Errorf("want 1 replayed, have %v", response)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var keys []string
+	attempt := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		key, _ := idempotency.FromContext(ctx)
+		keys = append(keys, key)
+		return nil, nil
+	}
+	retry := func(ctx context.Context, request interface{}) (interface{}, error) {
+		attempt(ctx, request)
+		return attempt(ctx, request)
+	}
+
+	e := idempotency.Generate[interface{}, interface{}]()(retry)
+	e(context.Background(), struct{}{})
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("want one key shared by both attempts, have %q", keys)
+	}
+
+	keys = nil
+	e(idempotency.NewContext(context.Background(), "mine"), struct{}{})
+	if keys[0] != "mine" {
+		t.Errorf("want existing key kept, have %q", keys[0])
+	}
+}
@@ -0,0 +1,116 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Record is what a Store holds for a key.
+type Record[Response any] struct {
+	// Done is false while the request that reserved the key is in flight.
+	Done bool
+
+	// Fingerprint identifies the content of the request that reserved the
+	// key.
+	Fingerprint string
+
+	Response Response
+	Err      error
+}
+
+// Store holds the records of idempotency keys. Implementations must be safe
+// for concurrent use, and Reserve must be atomic: of concurrent requests with
+// the same key, only one may reserve it.
+type Store[Response any] interface {
+	// Reserve claims key for a new request with the given fingerprint, which
+	// is kept in the record. If key was claimed before, it returns the
+	// existing record and false instead.
+	Reserve(ctx context.Context, key, fingerprint string) (record Record[Response], reserved bool, err error)
+
+	// Save stores the result of the request that reserved key.
+	Save(ctx context.Context, key string, record Record[Response]) error
+
+	// Release drops the claim on key, so that it may be reserved again.
+	Release(ctx context.Context, key string) error
+}
+
+// Memory is an in-memory Store. Records expire a fixed time after their key
+// is reserved, after which the key may be reused.
+type Memory[Response any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mtx       sync.Mutex
+	records   map[string]memoryRecord[Response]
+	nextSweep time.Time
+}
+
+type memoryRecord[Response any] struct {
+	Record[Response]
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory store whose records expire after ttl.
+func NewMemory[Response any](ttl time.Duration) *Memory[Response] {
+	return &Memory[Response]{
+		ttl:     ttl,
+		now:     time.Now,
+		records: map[string]memoryRecord[Response]{},
+	}
+}
+
+// Reserve implements Store.
+func (m *Memory[Response]) Reserve(_ context.Context, key, fingerprint string) (Record[Response], bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+	if r, ok := m.records[key]; ok && now.Before(r.expires) {
+		return r.Record, false, nil
+	}
+	m.sweep(now)
+	r := Record[Response]{Fingerprint: fingerprint}
+	m.records[key] = memoryRecord[Response]{Record: r, expires: now.Add(m.ttl)}
+	return r, true, nil
+}
+
+// Save implements Store.
+func (m *Memory[Response]) Save(_ context.Context, key string, record Record[Response]) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if r, ok := m.records[key]; ok {
+		m.records[key] = memoryRecord[Response]{Record: record, expires: r.expires}
+	}
+	return nil
+}
+
+// Release implements Store.
+func (m *Memory[Response]) Release(_ context.Context, key string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+// Len returns the number of records in the store, including expired ones
+// that haven't been dropped yet.
+func (m *Memory[Response]) Len() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return len(m.records)
+}
+
+// sweep drops expired records, at most once per ttl. The caller must hold
+// the lock.
+func (m *Memory[Response]) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(m.ttl)
+	for key, r := range m.records {
+		if !now.Before(r.expires) {
+			delete(m.records, key)
+		}
+	}
+}
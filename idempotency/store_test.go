@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryExpiry(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		m   = NewMemory[int](time.Minute)
+	)
+	m.now = func() time.Time { return now }
+
+	if _, reserved, _ := m.Reserve(ctx, "abc", ""); !reserved {
+		t.Fatal("want new key reserved")
+	}
+	m.Save(ctx, "abc", Record[int]{Done: true, Response: 42})
+	if r, reserved, _ := m.Reserve(ctx, "abc", ""); reserved || !r.Done || r.Response != 42 {
+		t.Fatalf("want saved record, have %+v (%v)", r, reserved)
+	}
+
+	now = now.Add(time.Minute)
+	if _, reserved, _ := m.Reserve(ctx, "abc", ""); !reserved {
+		t.Fatal("want expired key reserved again")
+	}
+	if _, reserved, _ := m.Reserve(ctx, "def", ""); !reserved {
+		t.Fatal("want new key reserved")
+	}
+	now = now.Add(2 * time.Minute)
+	m.Reserve(ctx, "ghi", "")
+	if want, have := 1, m.Len(); want != have {
+		t.Errorf("want expired records dropped, have %d records", have)
+	}
+}
+
+func TestMemoryRelease(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory[int](time.Minute)
+	m.Reserve(ctx, "abc", "")
+	if r, reserved, _ := m.Reserve(ctx, "abc", ""); reserved || r.Done {
+		t.Fatalf("want in-flight record, have %+v (%v)", r, reserved)
+	}
+	m.Release(ctx, "abc")
+	if _, reserved, _ := m.Reserve(ctx, "abc", ""); !reserved {
+		t.Fatal("want released key reserved again")
+	}
+}
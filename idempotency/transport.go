@@ -0,0 +1,95 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stdhttp "net/http"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openmesh/kit/transport/grpc"
+	"github.com/openmesh/kit/transport/http"
+)
+
+// KeyHeader is the HTTP header, and the gRPC metadata key, used to carry the
+// idempotency key of a request across the wire.
+const KeyHeader = "Idempotency-Key"
+
+// grpcKeyKey is KeyHeader in the lower case required by HTTP/2.
+const grpcKeyKey = "idempotency-key"
+
+type contextKey int
+
+const keyKey contextKey = 0
+
+// NewContext returns a copy of ctx carrying the idempotency key.
+func NewContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyKey, key)
+}
+
+// FromContext returns the idempotency key carried by ctx, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyKey).(string)
+	return key, ok && key != ""
+}
+
+// NewKey returns a new random idempotency key.
+func NewKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// HTTPToContext moves an idempotency key from request header to context.
+// Particularly useful for servers.
+func HTTPToContext() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		if key := r.Header.Get(KeyHeader); key != "" {
+			return NewContext(ctx, key)
+		}
+		return ctx
+	}
+}
+
+// ContextToHTTP moves an idempotency key from context to request header,
+// generating a new key if the context carries none. Particularly useful for
+// clients. A generated key is only shared by retries if the key is set
+// before lb.Retry, see Generate.
+func ContextToHTTP() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		key, ok := FromContext(ctx)
+		if !ok {
+			key = NewKey()
+		}
+		r.Header.Set(KeyHeader, key)
+		return NewContext(ctx, key)
+	}
+}
+
+// GRPCToContext moves an idempotency key from gRPC metadata to context.
+// Particularly useful for servers.
+func GRPCToContext() grpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		if values := md.Get(grpcKeyKey); len(values) > 0 && values[0] != "" {
+			return NewContext(ctx, values[0])
+		}
+		return ctx
+	}
+}
+
+// ContextToGRPC moves an idempotency key from context to gRPC metadata,
+// generating a new key if the context carries none. Particularly useful for
+// clients.
+func ContextToGRPC() grpc.ClientRequestFunc {
+	return func(ctx context.Context, md *metadata.MD) context.Context {
+		key, ok := FromContext(ctx)
+		if !ok {
+			key = NewKey()
+		}
+		(*md)[grpcKeyKey] = []string{key}
+		return NewContext(ctx, key)
+	}
+}
@@ -0,0 +1,37 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHTTPRoundTrip(t *testing.T) {
+	r := &http.Request{Header: http.Header{}}
+	ContextToHTTP()(NewContext(context.Background(), "abc"), r)
+	if want, have := "abc", r.Header.Get(KeyHeader); want != have {
+		t.Fatalf("header: want %q, have %q", want, have)
+	}
+	if key, ok := FromContext(HTTPToContext()(context.Background(), r)); !ok || key != "abc" {
+		t.Fatalf("want %q, have %q (%v)", "abc", key, ok)
+	}
+
+	r = &http.Request{Header: http.Header{}}
+	ContextToHTTP()(context.Background(), r)
+	if key := r.Header.Get(KeyHeader); len(key) != 32 {
+		t.Errorf("want a generated key, have %q", key)
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	md := metadata.MD{}
+	ContextToGRPC()(NewContext(context.Background(), "abc"), &md)
+	if key, ok := FromContext(GRPCToContext()(context.Background(), md)); !ok || key != "abc" {
+		t.Fatalf("want %q, have %q (%v)", "abc", key, ok)
+	}
+	if _, ok := FromContext(GRPCToContext()(context.Background(), metadata.MD{})); ok {
+		t.Fatal("want no key without metadata")
+	}
+}
@@ -0,0 +1,19 @@
+// Package loadshed implements priority-aware load shedding.
+//
+// Under overload it's better to reject some requests quickly than to serve
+// all of them slowly. A Shedder tracks the number of requests in flight and
+// the time requests spend queued for a slot. Each request carries a Priority
+// in its context. Requests over the concurrency threshold of their priority
+// queue for a slot, and lower priorities get a smaller share of the
+// Shedder's capacity: by default, batch traffic queues once half of it is in
+// use, while critical traffic may use all of it.
+//
+// Queue delay is managed in the style of CoDel: as long as the minimum queue
+// delay observed over an interval stays above a target, the Shedder raises
+// the priority below which all requests are rejected, one level per interval.
+// It lowers it again once the queue drains. The highest priority is never
+// rejected; critical requests queue, however saturated the Shedder is.
+//
+// Priorities are usually set by the caller and carried across the wire, see
+// HTTPToContext and GRPCToContext.
+package loadshed
@@ -0,0 +1,70 @@
+package loadshed
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Priority ranks requests for load shedding. When the service is overloaded,
+// requests with a lower Priority are rejected first.
+type Priority int
+
+const (
+	// Batch is for traffic that can be retried later without a user
+	// noticing, like background jobs and reports.
+	Batch Priority = iota
+
+	// Interactive is for traffic a user is actively waiting on.
+	Interactive
+
+	// Critical is for traffic that must be served for the system to remain
+	// healthy, like health checks and control plane operations.
+	Critical
+)
+
+var priorityNames = map[Priority]string{
+	Batch:       "batch",
+	Interactive: "interactive",
+	Critical:    "critical",
+}
+
+// String returns the name of the priority, or its numeric value if it isn't
+// one of the predefined priorities.
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return strconv.Itoa(int(p))
+}
+
+// ParsePriority parses a priority name, as returned by Priority.String, or a
+// numeric priority. Names are case-insensitive.
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.TrimSpace(s)
+	for p, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return p, true
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return Priority(n), true
+}
+
+type contextKey int
+
+const priorityKey contextKey = 0
+
+// NewContext returns a copy of ctx carrying the priority p.
+func NewContext(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, p)
+}
+
+// FromContext returns the priority carried by ctx, if any.
+func FromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey).(Priority)
+	return p, ok
+}
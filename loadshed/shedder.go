@@ -0,0 +1,294 @@
+package loadshed
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+// ErrOverloaded is returned in the request path when the Shedder rejects a
+// request. It implements StatusCoder for the HTTP transport, which encodes it
+// as 503 Service Unavailable, and GRPCStatus for the gRPC transport, which
+// encodes it as UNAVAILABLE.
+var ErrOverloaded error = overloadedError{}
+
+type overloadedError struct{}
+
+func (overloadedError) Error() string { return "service overloaded" }
+
+func (overloadedError) StatusCode() int { return http.StatusServiceUnavailable }
+
+func (e overloadedError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// Middleware returns an endpoint.Middleware that admits requests through the
+// Shedder s. The priority of each request is taken from its context, see
+// NewContext. Rejected requests fail with ErrOverloaded, and requests whose
+// context is done while waiting for a slot fail with the context's error.
+func Middleware[Request, Response any](s *Shedder) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			p, ok := FromContext(ctx)
+			if !ok {
+				p = s.defaultPriority
+			}
+			if err := s.acquire(ctx, p); err != nil {
+				return *new(Response), err
+			}
+			defer s.release()
+			return next(ctx, request)
+		}
+	}
+}
+
+// Shedder admits requests according to their priority, the number of
+// requests in flight, and the time requests spend waiting for a slot.
+//
+// Every priority has a concurrency threshold, expressed as a fraction of the
+// Shedder's capacity. A request is admitted immediately if the number of
+// requests in flight is below the threshold of its priority; otherwise it
+// waits until a slot becomes available. Waiting requests are admitted highest
+// priority first.
+//
+// If waiting requests keep seeing a delay above the target for a full
+// interval, the Shedder starts rejecting the lowest priority outright, and
+// escalates one priority per interval for as long as the delay persists. The
+// highest priority is never rejected this way.
+type Shedder struct {
+	capacity        int
+	thresholds      map[Priority]float64
+	target          time.Duration
+	interval        time.Duration
+	defaultPriority Priority
+	now             func() time.Time
+
+	mtx           sync.Mutex
+	levels        []Priority // thresholds keys, ascending
+	shedding      int        // number of levels currently rejected
+	inflight      int
+	queue         []*waiter
+	intervalStart time.Time
+	minDelay      time.Duration
+	sampled       bool
+}
+
+// Option sets an optional parameter for a Shedder.
+type Option func(*Shedder)
+
+// Threshold sets the concurrency threshold for priority p, as a fraction of
+// the Shedder's capacity. By default, Batch requests may use half of the
+// capacity, Interactive requests 90%, and Critical requests all of it.
+// Priorities without a threshold of their own use the threshold of the
+// nearest lower priority that has one.
+func Threshold(p Priority, fraction float64) Option {
+	return func(s *Shedder) { s.thresholds[p] = fraction }
+}
+
+// QueueDelay sets the target queue delay and the interval over which it's
+// measured. By default, the target is 5ms and the interval 100ms, as for
+// CoDel.
+func QueueDelay(target, interval time.Duration) Option {
+	return func(s *Shedder) { s.target, s.interval = target, interval }
+}
+
+// DefaultPriority sets the priority of requests whose context doesn't carry
+// one. By default, Interactive is used.
+func DefaultPriority(p Priority) Option {
+	return func(s *Shedder) { s.defaultPriority = p }
+}
+
+// NewShedder returns a Shedder that admits at most capacity requests at once.
+func NewShedder(capacity int, options ...Option) *Shedder {
+	s := &Shedder{
+		capacity: capacity,
+		thresholds: map[Priority]float64{
+			Batch:       0.5,
+			Interactive: 0.9,
+			Critical:    1.0,
+		},
+		target:          5 * time.Millisecond,
+		interval:        100 * time.Millisecond,
+		defaultPriority: Interactive,
+		now:             time.Now,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	for p := range s.thresholds {
+		s.levels = append(s.levels, p)
+	}
+	sort.Slice(s.levels, func(i, j int) bool { return s.levels[i] < s.levels[j] })
+	s.intervalStart = s.now()
+	return s
+}
+
+// Shedding returns the priority below which requests are currently rejected
+// because of sustained queue delay, and whether any are rejected at all.
+func (s *Shedder) Shedding() (Priority, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.levels[s.shedding], s.shedding > 0
+}
+
+type waiter struct {
+	p        Priority
+	enqueued time.Time
+	ready    chan struct{} // closed once err is final
+	err      error
+}
+
+func (s *Shedder) acquire(ctx context.Context, p Priority) error {
+	s.mtx.Lock()
+	now := s.now()
+	s.tick(now)
+
+	if s.shed(p) {
+		s.mtx.Unlock()
+		return ErrOverloaded
+	}
+	if s.inflight < s.limit(p) {
+		s.inflight++
+		s.observe(0)
+		s.mtx.Unlock()
+		return nil
+	}
+
+	w := &waiter{p: p, enqueued: now, ready: make(chan struct{})}
+	s.queue = append(s.queue, w)
+	s.mtx.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.err
+
+	case <-ctx.Done():
+		s.mtx.Lock()
+		select {
+		case <-w.ready:
+			// Lost the race: the waiter was resolved before we got the lock.
+			s.mtx.Unlock()
+			if w.err == nil {
+				s.release()
+			}
+		default:
+			s.remove(w)
+			s.mtx.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *Shedder) release() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.inflight--
+	now := s.now()
+	s.tick(now)
+	s.admit(now)
+}
+
+// admit hands free slots to waiting requests, highest priority first.
+// The caller must hold the lock.
+func (s *Shedder) admit(now time.Time) {
+	for {
+		var next *waiter
+		for _, w := range s.queue {
+			if s.inflight >= s.limit(w.p) {
+				continue
+			}
+			if next == nil || w.p > next.p {
+				next = w
+			}
+		}
+		if next == nil {
+			return
+		}
+		s.remove(next)
+		s.inflight++
+		s.observe(now.Sub(next.enqueued))
+		close(next.ready)
+	}
+}
+
+// tick ends the current measurement interval if it has elapsed, and adjusts
+// the shedding level based on the queue delay seen during the interval. The
+// caller must hold the lock.
+func (s *Shedder) tick(now time.Time) {
+	if now.Sub(s.intervalStart) < s.interval {
+		return
+	}
+
+	overloaded := s.sampled && s.minDelay > s.target
+	for _, w := range s.queue {
+		if now.Sub(w.enqueued) > s.target {
+			overloaded = true
+			break
+		}
+	}
+
+	switch {
+	case overloaded && s.shedding < len(s.levels)-1:
+		s.shedding++
+	case !overloaded && s.shedding > 0:
+		s.shedding--
+	}
+	s.intervalStart, s.minDelay, s.sampled = now, 0, false
+
+	// Reject waiters that are now being shed.
+	queue := s.queue[:0]
+	for _, w := range s.queue {
+		if s.shed(w.p) {
+			w.err = ErrOverloaded
+			close(w.ready)
+			continue
+		}
+		queue = append(queue, w)
+	}
+	s.queue = queue
+}
+
+// observe records the queue delay of an admitted request. The caller must
+// hold the lock.
+func (s *Shedder) observe(delay time.Duration) {
+	if !s.sampled || delay < s.minDelay {
+		s.minDelay = delay
+	}
+	s.sampled = true
+}
+
+func (s *Shedder) shed(p Priority) bool {
+	return s.shedding > 0 && p < s.levels[s.shedding]
+}
+
+func (s *Shedder) limit(p Priority) int {
+	fraction := s.thresholds[s.levels[0]]
+	for _, level := range s.levels {
+		if level > p {
+			break
+		}
+		fraction = s.thresholds[level]
+	}
+	if n := int(math.Ceil(fraction * float64(s.capacity))); n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (s *Shedder) remove(w *waiter) {
+	for i, x := range s.queue {
+		if x == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
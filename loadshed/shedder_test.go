@@ -0,0 +1,170 @@
+package loadshed_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/loadshed"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+// blocker is an endpoint that doesn't return until it's released.
+type blocker struct {
+	entered chan loadshed.Priority
+	release chan struct{}
+}
+
+func newBlocker() *blocker {
+	return &blocker{entered: make(chan loadshed.Priority, 100), release: make(chan struct{})}
+}
+
+func (b *blocker) endpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	p, _ := loadshed.FromContext(ctx)
+	b.entered <- p
+	<-b.release
+	return struct{}{}, nil
+}
+
+func call(e endpoint.Endpoint[interface{}, interface{}], ctx context.Context, p loadshed.Priority) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := e(loadshed.NewContext(ctx, p), struct{}{})
+		errc <- err
+	}()
+	return errc
+}
+
+func TestConcurrencyThresholds(t *testing.T) {
+	var (
+		s = loadshed.NewShedder(10, loadshed.QueueDelay(time.Hour, time.Hour))
+		b = newBlocker()
+		e = loadshed.Middleware[interface{}, interface{}](s)(b.endpoint)
+	)
+	defer close(b.release)
+
+	// Batch may use 5 of 10 slots.
+	for i := 0; i < 5; i++ {
+		call(e, context.Background(), loadshed.Batch)
+		<-b.entered
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, <-call(e, ctx, loadshed.Batch); want != have {
+		t.Fatalf("batch over threshold: want %v, have %v", want, have)
+	}
+
+	// Interactive may use 9 of 10 slots.
+	for i := 0; i < 4; i++ {
+		call(e, context.Background(), loadshed.Interactive)
+		<-b.entered
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, <-call(e, ctx, loadshed.Interactive); want != have {
+		t.Fatalf("interactive over threshold: want %v, have %v", want, have)
+	}
+
+	// Critical may use the last one.
+	call(e, context.Background(), loadshed.Critical)
+	select {
+	case <-b.entered:
+	case <-time.After(time.Second):
+		t.Fatal("critical request was not admitted")
+	}
+}
+
+func TestHighestPriorityAdmittedFirst(t *testing.T) {
+	var (
+		s = loadshed.NewShedder(1, loadshed.QueueDelay(time.Hour, time.Hour))
+		b = newBlocker()
+		e = loadshed.Middleware[interface{}, interface{}](s)(b.endpoint)
+	)
+
+	first := call(e, context.Background(), loadshed.Interactive)
+	<-b.entered
+
+	batch := call(e, context.Background(), loadshed.Batch)
+	time.Sleep(10 * time.Millisecond) // make sure batch is queued first
+	critical := call(e, context.Background(), loadshed.Critical)
+	time.Sleep(10 * time.Millisecond)
+
+	b.release <- struct{}{}
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if want, have := loadshed.Critical, <-b.entered; want != have {
+		t.Fatalf("want %v admitted first, have %v", want, have)
+	}
+	b.release <- struct{}{}
+	if err := <-critical; err != nil {
+		t.Fatal(err)
+	}
+	if want, have := loadshed.Batch, <-b.entered; want != have {
+		t.Fatalf("want %v admitted second, have %v", want, have)
+	}
+	b.release <- struct{}{}
+	if err := <-batch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueDelaySheds(t *testing.T) {
+	var (
+		interval = 20 * time.Millisecond
+		s        = loadshed.NewShedder(1, loadshed.QueueDelay(time.Millisecond, interval))
+		b        = newBlocker()
+		e        = loadshed.Middleware[interface{}, interface{}](s)(b.endpoint)
+	)
+	defer close(b.release)
+
+	call(e, context.Background(), loadshed.Critical)
+	<-b.entered
+	batch := call(e, context.Background(), loadshed.Batch)
+	interactive := call(e, context.Background(), loadshed.Interactive)
+
+	// The queue is standing for a whole interval, so the next request ends
+	// it and batch traffic starts being shed, including the queued request.
+	time.Sleep(2 * interval)
+	if want, have := loadshed.ErrOverloaded, <-call(e, context.Background(), loadshed.Batch); want != have {
+		t.Fatalf("new batch request: want %v, have %v", want, have)
+	}
+	if want, have := loadshed.ErrOverloaded, <-batch; want != have {
+		t.Fatalf("queued batch request: want %v, have %v", want, have)
+	}
+	if p, ok := s.Shedding(); !ok || p != loadshed.Interactive {
+		t.Fatalf("want shedding below %v, have %v (%v)", loadshed.Interactive, p, ok)
+	}
+
+	// Another interval of delay escalates to interactive traffic.
+	time.Sleep(2 * interval)
+	if want, have := loadshed.ErrOverloaded, <-call(e, context.Background(), loadshed.Interactive); want != have {
+		t.Fatalf("new interactive request: want %v, have %v", want, have)
+	}
+	if want, have := loadshed.ErrOverloaded, <-interactive; want != have {
+		t.Fatalf("queued interactive request: want %v, have %v", want, have)
+	}
+
+	// Critical traffic is never shed by queue delay.
+	time.Sleep(2 * interval)
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, <-call(e, ctx, loadshed.Critical); want != have {
+		t.Fatalf("critical request: want %v, have %v", want, have)
+	}
+}
+
+func TestErrOverloadedHTTP(t *testing.T) {
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), loadshed.ErrOverloaded, rec)
+	if want, have := http.StatusServiceUnavailable, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if !errors.Is(loadshed.ErrOverloaded, loadshed.ErrOverloaded) {
+		t.Error("ErrOverloaded should match itself")
+	}
+}
@@ -0,0 +1,70 @@
+package loadshed
+
+import (
+	"context"
+	stdhttp "net/http"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openmesh/kit/transport/grpc"
+	"github.com/openmesh/kit/transport/http"
+)
+
+// PriorityHeader is the HTTP header, and the gRPC metadata key, used to carry
+// the priority of a request across the wire. Its value is a priority name or
+// number, as accepted by ParsePriority.
+const PriorityHeader = "X-Request-Priority"
+
+// grpcPriorityKey is PriorityHeader in the lower case required by HTTP/2.
+const grpcPriorityKey = "x-request-priority"
+
+// HTTPToContext moves a priority from request header to context. Particularly
+// useful for servers. Requests with a missing or malformed header are left
+// without a priority, and get the Shedder's default.
+func HTTPToContext() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		p, ok := ParsePriority(r.Header.Get(PriorityHeader))
+		if !ok {
+			return ctx
+		}
+		return NewContext(ctx, p)
+	}
+}
+
+// ContextToHTTP moves a priority from context to request header. Particularly
+// useful for clients.
+func ContextToHTTP() http.RequestFunc {
+	return func(ctx context.Context, r *stdhttp.Request) context.Context {
+		if p, ok := FromContext(ctx); ok {
+			r.Header.Set(PriorityHeader, p.String())
+		}
+		return ctx
+	}
+}
+
+// GRPCToContext moves a priority from gRPC metadata to context. Particularly
+// useful for servers.
+func GRPCToContext() grpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		values := md.Get(grpcPriorityKey)
+		if len(values) == 0 {
+			return ctx
+		}
+		p, ok := ParsePriority(values[0])
+		if !ok {
+			return ctx
+		}
+		return NewContext(ctx, p)
+	}
+}
+
+// ContextToGRPC moves a priority from context to gRPC metadata. Particularly
+// useful for clients.
+func ContextToGRPC() grpc.ClientRequestFunc {
+	return func(ctx context.Context, md *metadata.MD) context.Context {
+		if p, ok := FromContext(ctx); ok {
+			(*md)[grpcPriorityKey] = []string{p.String()}
+		}
+		return ctx
+	}
+}
@@ -0,0 +1,56 @@
+package loadshed
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHTTPRoundTrip(t *testing.T) {
+	r := &http.Request{Header: http.Header{}}
+	ContextToHTTP()(NewContext(context.Background(), Batch), r)
+	if want, have := "batch", r.Header.Get(PriorityHeader); want != have {
+		t.Fatalf("header: want %q, have %q", want, have)
+	}
+
+	p, ok := FromContext(HTTPToContext()(context.Background(), r))
+	if !ok || p != Batch {
+		t.Fatalf("want %v, have %v (%v)", Batch, p, ok)
+	}
+}
+
+func TestHTTPToContextMalformed(t *testing.T) {
+	for _, value := range []string{"", "urgent"} {
+		r := &http.Request{Header: http.Header{PriorityHeader: []string{value}}}
+		if p, ok := FromContext(HTTPToContext()(context.Background(), r)); ok {
+			t.Errorf("%q: want no priority, have %v", value, p)
+		}
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	md := metadata.MD{}
+	ContextToGRPC()(NewContext(context.Background(), Critical), &md)
+
+	p, ok := FromContext(GRPCToContext()(context.Background(), md))
+	if !ok || p != Critical {
+		t.Fatalf("want %v, have %v (%v)", Critical, p, ok)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]Priority{
+		"batch":       Batch,
+		"Interactive": Interactive,
+		" CRITICAL ":  Critical,
+		"7":           7,
+		"-1":          -1,
+	} {
+		have, ok := ParsePriority(s)
+		if !ok || want != have {
+			t.Errorf("%q: want %v, have %v (%v)", s, want, have, ok)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Package mirror copies live requests to a shadow endpoint.
+//
+// Mirroring, or shadowing, validates a new version of a backend against real
+// traffic before it serves any. Middleware sends a sample of the requests of
+// the primary endpoint to a shadow endpoint as well, in the background, and
+// discards the shadow's responses. A CompareFunc may check them against the
+// primary's responses instead.
+//
+// The primary request path never waits for the shadow: shadow calls run in
+// their own goroutines, with a context that isn't canceled with the primary
+// request, and requests are simply not mirrored while too many shadow calls
+// are in flight.
+package mirror
@@ -0,0 +1,151 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+// Result is the outcome of a call to an endpoint.
+type Result[Response any] struct {
+	Response Response
+	Err      error
+}
+
+// CompareFunc is called with the results of the primary and shadow endpoints
+// for every mirrored request, once both have completed. It's called from the
+// shadow's goroutine. See Middleware.
+type CompareFunc[Request, Response any] func(ctx context.Context, request Request, primary, shadow Result[Response])
+
+// Option sets an optional parameter for Middleware.
+type Option func(*config)
+
+// Sample sets the ratio of requests, between 0 and 1, that are mirrored. By
+// default, all requests are.
+func Sample(ratio float64) Option {
+	return func(c *config) { c.sample = ratio }
+}
+
+// MaxConcurrent caps the number of shadow calls in flight. Requests made
+// while the cap is reached aren't mirrored, so zero disables mirroring. It
+// panics if n is negative. By default, it's 100.
+func MaxConcurrent(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("mirror: negative MaxConcurrent %d", n))
+	}
+	return func(c *config) { c.maxConcurrent = n }
+}
+
+// Timeout sets the deadline of shadow calls. By default, it's 10 seconds.
+func Timeout(d time.Duration) Option {
+	return func(c *config) { c.timeout = d }
+}
+
+type config struct {
+	sample        float64
+	maxConcurrent int
+	timeout       time.Duration
+}
+
+type mirror[Request, Response any] struct {
+	shadow  endpoint.Endpoint[Request, Response]
+	sample  float64
+	timeout time.Duration
+	compare CompareFunc[Request, Response]
+	sem     chan struct{}
+}
+
+// Middleware returns an endpoint.Middleware that mirrors requests of the
+// wrapped endpoint to shadow. The shadow is called with the same request
+// value as the primary endpoint, concurrently, so requests must not be
+// mutated by either endpoint. The shadow's context carries the values of the
+// primary request's context, and is marked so that Shadowed reports true.
+//
+// If compare isn't nil, it's called with the results of both endpoints, and
+// the shadow's context. A panic in the shadow endpoint is recovered, and
+// reported to compare as an error.
+func Middleware[Request, Response any](shadow endpoint.Endpoint[Request, Response], compare CompareFunc[Request, Response], options ...Option) endpoint.Middleware[Request, Response] {
+	c := &config{
+		sample:        1,
+		maxConcurrent: 100,
+		timeout:       10 * time.Second,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	m := &mirror[Request, Response]{
+		shadow:  shadow,
+		sample:  c.sample,
+		timeout: c.timeout,
+		compare: compare,
+		sem:     make(chan struct{}, c.maxConcurrent),
+	}
+
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			primary := m.mirror(ctx, request)
+			if primary == nil {
+				return next(ctx, request)
+			}
+			defer close(primary) // without a result if next panics
+			response, err := next(ctx, request)
+			primary <- Result[Response]{Response: response, Err: err}
+			return response, err
+		}
+	}
+}
+
+// mirror starts a shadow call for the request, if it's sampled and there's
+// room for it. It returns a channel on which to pass the primary result, or
+// nil if the request isn't mirrored.
+func (m *mirror[Request, Response]) mirror(ctx context.Context, request Request) chan<- Result[Response] {
+	if m.sample < 1 && rand.Float64() >= m.sample {
+		return nil
+	}
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		return nil
+	}
+
+	primary := make(chan Result[Response], 1)
+	go func() {
+		defer func() { <-m.sem }()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(newContext(ctx)), m.timeout)
+		defer cancel()
+		shadow := m.call(ctx, request)
+		if p, ok := <-primary; ok && m.compare != nil {
+			m.compare(ctx, request, p, shadow)
+		}
+	}()
+	return primary
+}
+
+func (m *mirror[Request, Response]) call(ctx context.Context, request Request) (result Result[Response]) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = Result[Response]{Err: fmt.Errorf("mirror: shadow panicked: %v", r)}
+		}
+	}()
+	response, err := m.shadow(ctx, request)
+	return Result[Response]{Response: response, Err: err}
+}
+
+type contextKey int
+
+const shadowKey contextKey = 0
+
+func newContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowKey, true)
+}
+
+// Shadowed reports whether ctx is the context of a shadow call. Shadow
+// endpoints may use it to skip side effects that mustn't be duplicated.
+func Shadowed(ctx context.Context) bool {
+	shadowed, _ := ctx.Value(shadowKey).(bool)
+	return shadowed
+}
@@ -0,0 +1,171 @@
+package mirror_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/mirror"
+)
+
+func primary(context.Context, string) (string, error) { return "v1", nil }
+
+func TestCompare(t *testing.T) {
+	type comparison struct {
+		request         string
+		primary, shadow mirror.Result[string]
+		shadowed        bool
+	}
+	compared := make(chan comparison, 1)
+	e := mirror.Middleware(
+		func(ctx context.Context, request string) (string, error) { return "v2", nil },
+		func(ctx context.Context, request string, primary, shadow mirror.Result[string]) {
+			compared <- comparison{request, primary, shadow, mirror.Shadowed(ctx)}
+		},
+	)(primary)
+
+	response, err := e(context.Background(), "hello")
+	if err != nil || response != "v1" {
+		t.Fatalf("want v1, nil, have %v, %v", response, err)
+	}
+	select {
+	case c := <-compared:
+		if c.request != "hello" || c.primary.Response != "v1" || c.shadow.Response != "v2" || !c.shadowed {
+			t.Errorf("unexpected comparison %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for comparison")
+	}
+}
+
+func TestPrimaryDoesNotWait(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		done    = make(chan struct{})
+		e       = mirror.Middleware(
+			func(ctx context.Context, request string) (string, error) {
+				<-release
+				return "", ctx.Err()
+			},
+			func(_ context.Context, _ string, _, shadow mirror.Result[string]) {
+				if shadow.Err != nil {
+					t.Errorf("shadow should outlive the primary request, have %v", shadow.Err)
+				}
+				close(done)
+			},
+		)(primary)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := e(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	close(release)
+	<-done
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		calls   int
+		release = make(chan struct{})
+		e       = mirror.Middleware(
+			func(context.Context, string) (string, error) {
+				mtx.Lock()
+				calls++
+				mtx.Unlock()
+				<-release
+				return "", nil
+			},
+			nil,
+			mirror.MaxConcurrent(2),
+		)(primary)
+	)
+	for i := 0; i < 10; i++ {
+		e(context.Background(), "hello")
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if want, have := 2, calls; want != have {
+		t.Errorf("want %d shadow calls, have %d", want, have)
+	}
+}
+
+func TestSample(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		calls int
+		e     = mirror.Middleware(
+			func(context.Context, string) (string, error) {
+				mtx.Lock()
+				calls++
+				mtx.Unlock()
+				return "", nil
+			},
+			nil,
+			mirror.Sample(0),
+		)(primary)
+	)
+	for i := 0; i < 100; i++ {
+		e(context.Background(), "hello")
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if want, have := 0, calls; want != have {
+		t.Errorf("want %d shadow calls, have %d", want, have)
+	}
+}
+
+func TestShadowPanic(t *testing.T) {
+	shadow := make(chan error, 1)
+	e := mirror.Middleware(
+		func(context.Context, string) (string, error) { panic("boom") },
+		func(_ context.Context, _ string, _, s mirror.Result[string]) { shadow <- s.Err },
+	)(primary)
+	if _, err := e(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-shadow; err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("want the panic reported, have %v", err)
+	}
+}
+
+func TestPrimaryError(t *testing.T) {
+	var (
+		want     = errors.New("primary failed")
+		compared = make(chan error, 1)
+		e        = mirror.Middleware(
+			func(context.Context, string) (string, error) { return "v2", nil },
+			func(_ context.Context, _ string, p, _ mirror.Result[string]) { compared <- p.Err },
+		)(func(context.Context, string) (string, error) { return "", want })
+	)
+	if _, err := e(context.Background(), "hello"); err != want {
+		t.Fatalf("want %v, have %v", want, err)
+	}
+	if have := <-compared; have != want {
+		t.Errorf("want %v compared, have %v", want, have)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for name, f := range map[string]func(){
+		"negative MaxConcurrent": func() { mirror.MaxConcurrent(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: want panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+// Quota describes the state of a rate limit at the moment it was observed.
+// Zero values mean the corresponding detail is unknown.
+type Quota struct {
+	// Limit is the maximum number of requests permitted in a window.
+	Limit int
+
+	// Remaining is the number of requests still permitted in the current
+	// window.
+	Remaining int
+
+	// Reset is the time until the quota has been replenished enough to
+	// permit another request.
+	Reset time.Duration
+}
+
+// QuotaAllower is an Allower that can also describe its current Quota. When
+// the Allower given to NewErroringLimiter implements QuotaAllower, rejected
+// requests carry the Quota in the returned Error.
+type QuotaAllower interface {
+	Allower
+	Quota() Quota
+}
+
+// Error is returned in the request path by NewErroringLimiter when a request
+// is rejected. It matches ErrLimited via errors.Is, and is encoded by the Go
+// kit transports as an *errcode.Error with the errcode.ResourceExhausted
+// code, so that clients can decode it as such. The known details of the
+// Quota are added:
+//
+//   - HTTP: 429 Too Many Requests with RateLimit-* and Retry-After headers.
+//   - gRPC: RESOURCE_EXHAUSTED with a RetryInfo detail.
+//   - JSON-RPC: the code of errcode.ResourceExhausted.
+type Error struct {
+	Quota
+}
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return ErrLimited.Error()
+}
+
+// Is reports whether target is ErrLimited.
+func (e Error) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Errcode returns e as an *errcode.Error with the errcode.ResourceExhausted
+// code. The details known from the Quota are set as the limit, remaining and
+// reset details, the latter in seconds.
+func (e Error) Errcode() *errcode.Error {
+	err := errcode.New(errcode.ResourceExhausted, e.Error())
+	if e.Limit > 0 {
+		err.WithDetail("limit", strconv.Itoa(e.Limit))
+		err.WithDetail("remaining", strconv.Itoa(e.Remaining))
+	}
+	if e.Reset > 0 {
+		err.WithDetail("reset", strconv.Itoa(resetSeconds(e.Reset)))
+	}
+	return err
+}
+
+// StatusCode implements the transport/http StatusCoder interface.
+func (e Error) StatusCode() int {
+	return errcode.ResourceExhausted.HTTPStatus()
+}
+
+// Headers implements the transport/http Headerer interface. Besides the
+// errcode.Header, only the details known from the Quota are reported.
+func (e Error) Headers() http.Header {
+	h := e.Errcode().Headers()
+	if e.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(e.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(e.Remaining))
+	}
+	if e.Reset > 0 {
+		seconds := strconv.Itoa(resetSeconds(e.Reset))
+		h.Set("RateLimit-Reset", seconds)
+		h.Set("Retry-After", seconds)
+	}
+	return h
+}
+
+// MarshalJSON implements json.Marshaler, encoding e as its Errcode.
+func (e Error) MarshalJSON() ([]byte, error) {
+	return e.Errcode().MarshalJSON()
+}
+
+// ErrorCode implements the transport/http/jsonrpc ErrorCoder interface.
+func (e Error) ErrorCode() int {
+	return errcode.ResourceExhausted.JSONRPCCode()
+}
+
+// GRPCStatus is used by the gRPC runtime to produce the status returned to
+// the caller when the error reaches a gRPC server. It's the status of its
+// Errcode, with a RetryInfo detail if the reset time is known.
+func (e Error) GRPCStatus() *status.Status {
+	st := e.Errcode().GRPCStatus()
+	if e.Reset <= 0 {
+		return st
+	}
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(e.Reset),
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// resetSeconds rounds d up to whole seconds, as required by the Retry-After
+// and RateLimit-Reset headers.
+func resetSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,131 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/ratelimit"
+	httptransport "github.com/openmesh/kit/transport/http"
+	"github.com/openmesh/kit/transport/http/jsonrpc"
+)
+
+func TestErroringLimiterQuota(t *testing.T) {
+	limit := ratelimit.NewXRateAllower(rate.NewLimiter(rate.Every(time.Minute), 2))
+	e := ratelimit.NewErroringLimiter[interface{}, interface{}](limit)(nopEndpoint)
+
+	for i := 0; i < 2; i++ {
+		if _, err := e(context.Background(), struct{}{}); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	_, err := e(context.Background(), struct{}{})
+	if !errors.Is(err, ratelimit.ErrLimited) {
+		t.Fatalf("want %v, have %v", ratelimit.ErrLimited, err)
+	}
+	var lerr ratelimit.Error
+	if !errors.As(err, &lerr) {
+		t.Fatalf("want ratelimit.Error, have %T", err)
+	}
+	if want, have := 2, lerr.Limit; want != have {
+		t.Errorf("Limit: want %d, have %d", want, have)
+	}
+	if want, have := 0, lerr.Remaining; want != have {
+		t.Errorf("Remaining: want %d, have %d", want, have)
+	}
+	if lerr.Reset <= 0 || lerr.Reset > time.Minute {
+		t.Errorf("Reset: want (0, 1m], have %v", lerr.Reset)
+	}
+}
+
+func TestErrorHTTP(t *testing.T) {
+	err := ratelimit.Error{Quota: ratelimit.Quota{Limit: 10, Remaining: 0, Reset: 1500 * time.Millisecond}}
+
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), err, rec)
+
+	if want, have := http.StatusTooManyRequests, rec.Code; want != have {
+		t.Errorf("status: want %d, have %d", want, have)
+	}
+	for k, want := range map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+		"Retry-After":         "2",
+		errcode.Header:        "RESOURCE_EXHAUSTED",
+	} {
+		if have := rec.Header().Get(k); want != have {
+			t.Errorf("%s: want %q, have %q", k, want, have)
+		}
+	}
+
+	resp := rec.Result()
+	decoded, ok := httptransport.DecodeError(resp).(*errcode.Error)
+	if !ok {
+		t.Fatalf("want *errcode.Error, have %v", decoded)
+	}
+	if want, have := errcode.ResourceExhausted, decoded.Code; want != have {
+		t.Errorf("code: want %v, have %v", want, have)
+	}
+	if want, have := "2", decoded.Details["reset"]; want != have {
+		t.Errorf("reset: want %q, have %q", want, have)
+	}
+}
+
+func TestErrorHTTPUnknownQuota(t *testing.T) {
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), ratelimit.Error{}, rec)
+
+	if want, have := http.StatusTooManyRequests, rec.Code; want != have {
+		t.Errorf("status: want %d, have %d", want, have)
+	}
+	for _, k := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"} {
+		if have := rec.Header().Get(k); have != "" {
+			t.Errorf("%s: want no header, have %q", k, have)
+		}
+	}
+}
+
+func TestErrorGRPC(t *testing.T) {
+	err := ratelimit.Error{Quota: ratelimit.Quota{Reset: 3 * time.Second}}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatal("want a gRPC status")
+	}
+	if want, have := codes.ResourceExhausted, st.Code(); want != have {
+		t.Errorf("code: want %v, have %v", want, have)
+	}
+	var ri *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.RetryInfo); ok {
+			ri = d
+		}
+	}
+	if ri == nil {
+		t.Fatalf("want a *errdetails.RetryInfo, have %v", st.Details())
+	}
+	if want, have := 3*time.Second, ri.RetryDelay.AsDuration(); want != have {
+		t.Errorf("retry delay: want %v, have %v", want, have)
+	}
+	if e, ok := errcode.FromGRPCStatus(st); !ok || e.Code != errcode.ResourceExhausted {
+		t.Errorf("want an errcode.Error with code %v, have %v", errcode.ResourceExhausted, e)
+	}
+}
+
+func TestErrorJSONRPC(t *testing.T) {
+	var ec jsonrpc.ErrorCoder = ratelimit.Error{}
+	if want, have := errcode.ResourceExhausted.JSONRPCCode(), ec.ErrorCode(); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
@@ -8,7 +8,8 @@ import (
 )
 
 // ErrLimited is returned in the request path when the rate limiter is
-// triggered and the request is rejected.
+// triggered and the request is rejected. NewErroringLimiter wraps it in an
+// Error, so callers should compare with errors.Is.
 var ErrLimited = errors.New("rate limit exceeded")
 
 // Allower dictates whether or not a request is acceptable to run.
@@ -20,12 +21,17 @@ type Allower interface {
 
 // NewErroringLimiter returns an endpoint.Middleware that acts as a rate
 // limiter. Requests that would exceed the
-// maximum request rate are simply rejected with an error.
+// maximum request rate are simply rejected with an Error. If limit implements
+// QuotaAllower, the Error describes the state of the limit.
 func NewErroringLimiter[Request, Response any](limit Allower) endpoint.Middleware[Request, Response] {
 	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
 		return func(ctx context.Context, request Request) (Response, error) {
 			if !limit.Allow() {
-				return *new(Response), ErrLimited
+				var err Error
+				if qa, ok := limit.(QuotaAllower); ok {
+					err.Quota = qa.Quota()
+				}
+				return *new(Response), err
 			}
 			return next(ctx, request)
 		}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// NewXRateAllower wraps a limiter from "golang.org/x/time/rate" so that it
+// also reports its Quota. The limiter's burst is reported as the limit, and
+// the number of whole tokens currently in the bucket as the remaining count.
+func NewXRateAllower(limiter *rate.Limiter) QuotaAllower {
+	return xrateAllower{limiter}
+}
+
+type xrateAllower struct {
+	*rate.Limiter
+}
+
+func (a xrateAllower) Quota() Quota {
+	var (
+		limit  = a.Limit()
+		tokens = a.Tokens()
+		q      = Quota{Limit: a.Burst()}
+	)
+	if tokens > 0 {
+		q.Remaining = int(tokens)
+	}
+	if tokens < 1 && limit != rate.Inf && limit > 0 {
+		q.Reset = time.Duration((1 - tokens) / float64(limit) * float64(time.Second))
+	}
+	return q
+}
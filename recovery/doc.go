@@ -0,0 +1,17 @@
+// Package recovery turns panics in endpoints into errors.
+//
+// Without it, a panic in an endpoint takes down the goroutine serving the
+// request, and every transport reacts differently: net/http logs it and
+// drops the connection, while the NATS and AMQP handlers crash the process.
+// Middleware recovers the panic and returns it as an Error carrying the panic
+// value and the stack trace. The transports then handle it like any other
+// endpoint error: it's passed to their transport.ErrorHandler, which logs the
+// panic value as the error message, and encoded by their error encoder as a
+// 500 or codes.Internal reply, with a generic message that doesn't leak it to
+// clients.
+//
+// Only panics in endpoints are recovered. Recovering panics in the decoders,
+// encoders and other funcs of the transports is out of the scope of this
+// package; with net/http, for instance, they're still handled by the
+// http.Server.
+package recovery
@@ -0,0 +1,86 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+)
+
+// Middleware returns an endpoint.Middleware that recovers panics in the
+// wrapped endpoint, and returns them as an Error. Panics in the decoders and
+// encoders of the transports happen outside of the endpoint, and aren't
+// recovered.
+//
+// http.ErrAbortHandler is re-panicked, since it's used to deliberately abort
+// the response of an HTTP handler.
+func Middleware[Request, Response any]() endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (response Response, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					response, err = *new(Response), Error{Panic: r, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, request)
+		}
+	}
+}
+
+// Error is a recovered panic. Its message includes the panic value, so that
+// transport.ErrorHandlers such as transport.LogErrorHandler log it, and the
+// stack trace is kept in its Stack field. Transports don't send either to
+// clients: the error matches a generic *errcode.Error, see As, which is what
+// their error encoders reply with.
+type Error struct {
+	// Panic is the value passed to panic.
+	Panic interface{}
+
+	// Stack is the stack trace of the goroutine that panicked, as formatted
+	// by runtime/debug.Stack.
+	Stack []byte
+}
+
+// Error implements the error interface. The message includes the panic
+// value, but not the stack trace.
+func (e Error) Error() string {
+	return fmt.Sprintf("panic: %v", e.Panic)
+}
+
+// As makes e match an *errcode.Error with the errcode.Internal code and the
+// generic message "internal error", with errors.As. The error encoders of
+// the transports encode that Error instead of e, so that the panic value
+// isn't sent to clients.
+func (e Error) As(target interface{}) bool {
+	if p, ok := target.(**errcode.Error); ok {
+		*p = errcode.New(errcode.Internal, "internal error")
+		return true
+	}
+	return false
+}
+
+// Unwrap returns the panic value if it's an error.
+func (e Error) Unwrap() error {
+	err, _ := e.Panic.(error)
+	return err
+}
+
+// StatusCode implements the StatusCoder interface of the HTTP transport.
+func (e Error) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// GRPCStatus allows the gRPC transport to report the error as codes.Internal,
+// with a generic message.
+func (e Error) GRPCStatus() *status.Status {
+	return status.New(codes.Internal, "internal error")
+}
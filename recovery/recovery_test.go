@@ -0,0 +1,113 @@
+package recovery_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/recovery"
+	"github.com/openmesh/kit/transport"
+	kithttp "github.com/openmesh/kit/transport/http"
+)
+
+func panicking(context.Context, interface{}) (interface{}, error) {
+	panic("boom")
+}
+
+func TestMiddleware(t *testing.T) {
+	_, err := recovery.Middleware[interface{}, interface{}]()(panicking)(context.Background(), struct{}{})
+
+	var rerr recovery.Error
+	if !errors.As(err, &rerr) {
+		t.Fatalf("want recovery.Error, have %v", err)
+	}
+	if want, have := "boom", rerr.Panic; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := "panic: boom", err.Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if e := errcode.Convert(err); e.Code != errcode.Internal || e.Message != "internal error" {
+		t.Errorf("want generic %v errcode.Error, have %v: %v", errcode.Internal, e.Code, e)
+	}
+	if !strings.Contains(string(rerr.Stack), "recovery_test.panicking") {
+		t.Errorf("want stack trace including the panicking function, have\n%s", rerr.Stack)
+	}
+	if want, have := codes.Internal, status.Code(err); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if want, have := "internal error", status.Convert(err).Message(); want != have {
+		t.Errorf("want gRPC message %q, have %q", want, have)
+	}
+}
+
+func TestMiddlewarePassesThrough(t *testing.T) {
+	response, err := recovery.Middleware[interface{}, interface{}]()(endpoint.Nop)(context.Background(), struct{}{})
+	if err != nil || response == nil {
+		t.Errorf("want response, have %v, %v", response, err)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	errBoom := errors.New("boom")
+	_, err := recovery.Middleware[interface{}, interface{}]()(func(context.Context, interface{}) (interface{}, error) {
+		panic(errBoom)
+	})(context.Background(), struct{}{})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("want error wrapping %v, have %v", errBoom, err)
+	}
+}
+
+func TestAbortHandlerRepanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("want %v, have %v", http.ErrAbortHandler, r)
+		}
+	}()
+	recovery.Middleware[interface{}, interface{}]()(func(context.Context, interface{}) (interface{}, error) {
+		panic(http.ErrAbortHandler)
+	})(context.Background(), struct{}{})
+}
+
+func TestHTTPServer(t *testing.T) {
+	var (
+		handled error
+		logs    bytes.Buffer
+		logger  = transport.NewLogErrorHandler(log.NewLogfmtLogger(&logs))
+	)
+	handler := kithttp.NewServer(
+		recovery.Middleware[interface{}, interface{}]()(panicking),
+		kithttp.NopRequestDecoder,
+		kithttp.EncodeJSONResponse[interface{}],
+		kithttp.ServerErrorHandler[interface{}, interface{}](transport.ErrorHandlerFunc(func(ctx context.Context, err error) {
+			handled = err
+			logger.Handle(ctx, err)
+		})),
+	)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if want, have := http.StatusInternalServerError, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("want panic value not sent to the client, have %s", rec.Body)
+	}
+	if !strings.Contains(logs.String(), "panic: boom") {
+		t.Errorf("want panic value logged, have %q", logs.String())
+	}
+	var rerr recovery.Error
+	if !errors.As(handled, &rerr) || len(rerr.Stack) == 0 {
+		t.Errorf("want recovery.Error with stack passed to the error handler, have %v", handled)
+	}
+}
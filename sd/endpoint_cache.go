@@ -20,6 +20,7 @@ type endpointCache[Request, Response any] struct {
 	cache              map[string]endpointCloser[Request, Response]
 	err                error
 	endpoints          []endpoint.Endpoint[Request, Response]
+	instances          []string // of the endpoints, in the same order
 	logger             log.Logger
 	invalidateDeadline time.Time
 	timeNow            func() time.Time
@@ -102,16 +103,19 @@ func (c *endpointCache[Request, Response]) updateCache(instances []string) {
 
 	// Populate the slice of endpoints.
 	endpoints := make([]endpoint.Endpoint[Request, Response], 0, len(cache))
+	present := make([]string, 0, len(cache))
 	for _, instance := range instances {
 		// A bad factory may mean an instance is not present.
 		if _, ok := cache[instance]; !ok {
 			continue
 		}
 		endpoints = append(endpoints, cache[instance].Endpoint)
+		present = append(present, instance)
 	}
 
 	// Swap and trigger GC for old copies.
 	c.endpoints = endpoints
+	c.instances = present
 	c.cache = cache
 }
 
@@ -141,3 +145,30 @@ func (c *endpointCache[Request, Response]) Endpoints() ([]endpoint.Endpoint[Requ
 	c.updateCache(nil) // close any remaining active endpoints
 	return nil, c.err
 }
+
+// Status yields the status of the current set of endpoints, in the same order
+// as Endpoints.
+func (c *endpointCache[Request, Response]) Status() ([]EndpointStatus[Request, Response], error) {
+	// Endpoints takes care of invalidation.
+	if _, err := c.Endpoints(); err != nil {
+		return nil, err
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	statuses := make([]EndpointStatus[Request, Response], len(c.instances))
+	for i, instance := range c.instances {
+		sc := c.cache[instance]
+		statuses[i] = EndpointStatus[Request, Response]{
+			Instance:  instance,
+			Endpoint:  sc.Endpoint,
+			Available: true,
+		}
+		if r, ok := sc.Closer.(StatusReporter); ok {
+			statuses[i].State = r.State()
+			statuses[i].Available = r.Available()
+		}
+	}
+	return statuses, nil
+}
@@ -153,6 +153,52 @@ func TestBadFactory(t *testing.T) {
 	assertEndpointsLen(t, cache, 0)
 }
 
+func TestEndpointCacheStatus(t *testing.T) {
+	cache := newEndpointCache(func(instance string) (endpoint.Endpoint[interface{}, interface{}], io.Closer, error) {
+		if instance == "b" {
+			return endpoint.Nop, reporter{"open", false}, nil
+		}
+		return endpoint.Nop, nil, nil
+	}, log.NewNopLogger(), endpointerOptions{invalidateOnError: true})
+
+	cache.Update(Event{Instances: []string{"c", "b", "a"}})
+	statuses, err := cache.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, len(statuses); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+	for i, want := range []struct {
+		instance  string
+		state     string
+		available bool
+	}{
+		{"a", "", true},
+		{"b", "open", false},
+		{"c", "", true},
+	} {
+		have := statuses[i]
+		if want.instance != have.Instance || want.state != have.State || want.available != have.Available {
+			t.Errorf("%d: want %+v, have %+v", i, want, have)
+		}
+	}
+
+	cache.Update(Event{Err: errors.New("sd error")})
+	if _, err := cache.Status(); err == nil {
+		t.Error("want error, have none")
+	}
+}
+
+type reporter struct {
+	state     string
+	available bool
+}
+
+func (r reporter) Close() error    { return nil }
+func (r reporter) State() string   { return r.state }
+func (r reporter) Available() bool { return r.available }
+
 func assertEndpointsLen(t *testing.T, cache *endpointCache[interface{}, interface{}], l int) {
 	endpoints, err := cache.Endpoints()
 	if err != nil {
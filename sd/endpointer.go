@@ -15,6 +15,35 @@ type Endpointer[Request, Response any] interface {
 	Endpoints() ([]endpoint.Endpoint[Request, Response], error)
 }
 
+// EndpointStatus describes one of the endpoints yielded by an Endpointer.
+type EndpointStatus[Request, Response any] struct {
+	Instance string
+	Endpoint endpoint.Endpoint[Request, Response]
+
+	// State is a description of the endpoint's state, e.g. the state of a
+	// circuit breaker in front of it. It's empty if nothing is reported.
+	State string
+
+	// Available is false if the endpoint is known to reject requests, e.g.
+	// because a circuit breaker in front of it is open. Balancers skip
+	// unavailable endpoints.
+	Available bool
+}
+
+// StatusEndpointer is an Endpointer that can also report the status of each
+// of its endpoints.
+type StatusEndpointer[Request, Response any] interface {
+	Endpointer[Request, Response]
+	Status() ([]EndpointStatus[Request, Response], error)
+}
+
+// StatusReporter may be implemented by the io.Closer returned by a Factory to
+// report the status of the endpoint it belongs to.
+type StatusReporter interface {
+	State() string
+	Available() bool
+}
+
 // FixedEndpointer yields a fixed set of endpoints.
 type FixedEndpointer[Request, Response any] []endpoint.Endpoint[Request, Response]
 
@@ -90,3 +119,10 @@ func (de *DefaultEndpointer[Request, Response]) Close() {
 func (de *DefaultEndpointer[Request, Response]) Endpoints() ([]endpoint.Endpoint[Request, Response], error) {
 	return de.cache.Endpoints()
 }
+
+// Status implements StatusEndpointer. Endpoints are reported in the same
+// order as they're yielded by Endpoints, and are available unless the closer
+// returned by the Factory implements StatusReporter and says otherwise.
+func (de *DefaultEndpointer[Request, Response]) Status() ([]EndpointStatus[Request, Response], error) {
+	return de.cache.Status()
+}
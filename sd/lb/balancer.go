@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/sd"
 )
 
 // Balancer yields endpoints according to some heuristic.
@@ -13,3 +14,24 @@ type Balancer[Request, Response any] interface {
 
 // ErrNoEndpoints is returned when no qualifying endpoints are available.
 var ErrNoEndpoints = errors.New("no endpoints available")
+
+// available yields the endpoints of s that may be picked. If s is an
+// sd.StatusEndpointer, endpoints it reports as unavailable, e.g. because
+// their circuit breaker is open, are skipped.
+func available[Request, Response any](s sd.Endpointer[Request, Response]) ([]endpoint.Endpoint[Request, Response], error) {
+	se, ok := s.(sd.StatusEndpointer[Request, Response])
+	if !ok {
+		return s.Endpoints()
+	}
+	statuses, err := se.Status()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]endpoint.Endpoint[Request, Response], 0, len(statuses))
+	for _, status := range statuses {
+		if status.Available {
+			endpoints = append(endpoints, status.Endpoint)
+		}
+	}
+	return endpoints, nil
+}
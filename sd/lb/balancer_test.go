@@ -0,0 +1,68 @@
+package lb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/sd"
+)
+
+// statusEndpointer is a FixedEndpointer that reports the endpoints at the
+// given indices as unavailable.
+type statusEndpointer struct {
+	sd.FixedEndpointer[interface{}, interface{}]
+	unavailable map[int]bool
+}
+
+func (s statusEndpointer) Status() ([]sd.EndpointStatus[interface{}, interface{}], error) {
+	statuses := make([]sd.EndpointStatus[interface{}, interface{}], len(s.FixedEndpointer))
+	for i, e := range s.FixedEndpointer {
+		statuses[i] = sd.EndpointStatus[interface{}, interface{}]{Endpoint: e, Available: !s.unavailable[i]}
+	}
+	return statuses, nil
+}
+
+func TestBalancersSkipUnavailable(t *testing.T) {
+	var (
+		counts    = []int{0, 0, 0}
+		endpoints = []endpoint.Endpoint[interface{}, interface{}]{
+			func(context.Context, interface{}) (interface{}, error) { counts[0]++; return struct{}{}, nil },
+			func(context.Context, interface{}) (interface{}, error) { counts[1]++; return struct{}{}, nil },
+			func(context.Context, interface{}) (interface{}, error) { counts[2]++; return struct{}{}, nil },
+		}
+		endpointer = statusEndpointer{endpoints, map[int]bool{1: true}}
+	)
+
+	for name, balancer := range map[string]Balancer[interface{}, interface{}]{
+		"random":      NewRandom[interface{}, interface{}](endpointer, 1),
+		"round robin": NewRoundRobin[interface{}, interface{}](endpointer),
+	} {
+		counts[0], counts[1], counts[2] = 0, 0, 0
+		for i := 0; i < 100; i++ {
+			e, err := balancer.Endpoint()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			e(context.Background(), struct{}{})
+		}
+		if counts[0] == 0 || counts[1] != 0 || counts[2] == 0 {
+			t.Errorf("%s: want only available endpoints called, have %v", name, counts)
+		}
+	}
+}
+
+func TestBalancersAllUnavailable(t *testing.T) {
+	endpointer := statusEndpointer{
+		sd.FixedEndpointer[interface{}, interface{}]{endpoint.Nop},
+		map[int]bool{0: true},
+	}
+	for name, balancer := range map[string]Balancer[interface{}, interface{}]{
+		"random":      NewRandom[interface{}, interface{}](endpointer, 1),
+		"round robin": NewRoundRobin[interface{}, interface{}](endpointer),
+	} {
+		if _, err := balancer.Endpoint(); err != ErrNoEndpoints {
+			t.Errorf("%s: want %v, have %v", name, ErrNoEndpoints, err)
+		}
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"github.com/openmesh/kit/sd"
 )
 
-// NewRandom returns a load balancer that selects services randomly.
+// NewRandom returns a load balancer that selects services randomly. Endpoints
+// reported as unavailable by an sd.StatusEndpointer are skipped.
 func NewRandom[Request, Response any](s sd.Endpointer[Request, Response], seed int64) Balancer[Request, Response] {
 	return &random[Request, Response]{
 		s: s,
@@ -21,7 +22,7 @@ type random[Request, Response any] struct {
 }
 
 func (r *random[Request, Response]) Endpoint() (endpoint.Endpoint[Request, Response], error) {
-	endpoints, err := r.s.Endpoints()
+	endpoints, err := available(r.s)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +28,12 @@ func (e RetryError) Error() string {
 	return fmt.Sprintf("%v%s", e.Final, suffix)
 }
 
+// Unwrap returns the final error, so that errors.Is and errors.As see
+// through the RetryError.
+func (e RetryError) Unwrap() error {
+	return e.Final
+}
+
 // Callback is a function that is given the current attempt count and the error
 // received from the underlying endpoint. It should return whether the Retry
 // function should continue trying to get a working endpoint, and a custom error
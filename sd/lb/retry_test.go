@@ -139,3 +139,14 @@ func TestHandleNilCallback(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRetryErrorUnwrap(t *testing.T) {
+	var (
+		endpoints = sd.FixedEndpointer[interface{}, interface{}]{} // no endpoints
+		rr        = lb.NewRoundRobin[interface{}, interface{}](endpoints)
+		retry     = lb.Retry[interface{}, interface{}](3, time.Second, rr)
+	)
+	if _, err := retry(context.Background(), struct{}{}); !errors.Is(err, lb.ErrNoEndpoints) {
+		t.Errorf("want error wrapping %v, have %v", lb.ErrNoEndpoints, err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 )
 
 // NewRoundRobin returns a load balancer that returns services in sequence.
+// Endpoints reported as unavailable by an sd.StatusEndpointer are skipped.
 func NewRoundRobin[Request, Response any](s sd.Endpointer[Request, Response]) Balancer[Request, Response] {
 	return &roundRobin[Request, Response]{
 		s: s,
@@ -21,7 +22,7 @@ type roundRobin[Request, Response any] struct {
 }
 
 func (rr *roundRobin[Request, Response]) Endpoint() (endpoint.Endpoint[Request, Response], error) {
-	endpoints, err := rr.s.Endpoints()
+	endpoints, err := available(rr.s)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,133 @@
+package lb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+// ErrNoRoute is returned by a Router when a request matches no route, and all
+// weights are zero.
+var ErrNoRoute = errors.New("no route available")
+
+// MatchFunc reports whether a request should be sent to a route. Values from
+// the transport, like headers or JWT claims, are usually moved into the
+// context by a server request func so that they can be matched here.
+type MatchFunc[Request any] func(ctx context.Context, request Request) bool
+
+// Route is a destination of a Router.
+type Route[Request, Response any] struct {
+	// Name identifies the route, to change its weight.
+	Name string
+
+	// Balancer yields the endpoints of the route, typically from an
+	// sd.Endpointer of the instances of one version of a service.
+	Balancer Balancer[Request, Response]
+
+	// Weight is the share of unmatched requests sent to the route, relative
+	// to the weights of the other routes.
+	Weight int
+
+	// Match, if set, sends every request it matches to the route,
+	// regardless of weights.
+	Match MatchFunc[Request]
+}
+
+// Router splits traffic across several Balancers, e.g. a stable and a canary
+// version of a service. A request goes to the first route whose Match
+// function matches it. Other requests are spread across routes in proportion
+// to their weights, which may be changed while the Router is in use to
+// progressively roll out a new version.
+type Router[Request, Response any] struct {
+	mtx     sync.RWMutex
+	routes  []Route[Request, Response]
+	weights []int
+}
+
+// NewRouter returns a Router over the given routes.
+func NewRouter[Request, Response any](routes ...Route[Request, Response]) *Router[Request, Response] {
+	r := &Router[Request, Response]{
+		routes:  routes,
+		weights: make([]int, len(routes)),
+	}
+	for i, route := range routes {
+		r.weights[i] = route.Weight
+	}
+	return r
+}
+
+// SetWeight changes the weight of the named route.
+func (r *Router[Request, Response]) SetWeight(name string, weight int) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i, route := range r.routes {
+		if route.Name == name {
+			r.weights[i] = weight
+			return nil
+		}
+	}
+	return fmt.Errorf("lb: no route named %q", name)
+}
+
+// Weights returns the current weight of each route, by name.
+func (r *Router[Request, Response]) Weights() map[string]int {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	weights := make(map[string]int, len(r.routes))
+	for i, route := range r.routes {
+		weights[route.Name] = r.weights[i]
+	}
+	return weights
+}
+
+// Endpoint returns an endpoint that sends each request to an endpoint of the
+// Balancer of the route picked for it. Errors from the Balancer, such as
+// ErrNoEndpoints, are returned as is; they aren't retried on other routes.
+func (r *Router[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
+	return func(ctx context.Context, request Request) (Response, error) {
+		route, ok := r.pick(ctx, request)
+		if !ok {
+			return *new(Response), ErrNoRoute
+		}
+		e, err := route.Balancer.Endpoint()
+		if err != nil {
+			return *new(Response), err
+		}
+		return e(ctx, request)
+	}
+}
+
+func (r *Router[Request, Response]) pick(ctx context.Context, request Request) (Route[Request, Response], bool) {
+	for _, route := range r.routes {
+		if route.Match != nil && route.Match(ctx, request) {
+			return route, true
+		}
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	var total int
+	for _, w := range r.weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total == 0 {
+		return Route[Request, Response]{}, false
+	}
+	n := rand.Intn(total)
+	for i, w := range r.weights {
+		if w <= 0 {
+			continue
+		}
+		if n < w {
+			return r.routes[i], true
+		}
+		n -= w
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,103 @@
+package lb_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/sd"
+	"github.com/openmesh/kit/sd/lb"
+)
+
+type versionKey struct{}
+
+func version(v string) lb.Balancer[interface{}, interface{}] {
+	return lb.NewRoundRobin[interface{}, interface{}](sd.FixedEndpointer[interface{}, interface{}]{
+		func(context.Context, interface{}) (interface{}, error) { return v, nil },
+	})
+}
+
+func countRoutes(t *testing.T, e endpoint.Endpoint[interface{}, interface{}], ctx context.Context, n int) map[interface{}]int {
+	t.Helper()
+	counts := map[interface{}]int{}
+	for i := 0; i < n; i++ {
+		response, err := e(ctx, struct{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[response]++
+	}
+	return counts
+}
+
+func TestRouterWeights(t *testing.T) {
+	var (
+		n      = 10000
+		router = lb.NewRouter(
+			lb.Route[interface{}, interface{}]{Name: "stable", Balancer: version("stable"), Weight: 90},
+			lb.Route[interface{}, interface{}]{Name: "canary", Balancer: version("canary"), Weight: 10},
+		)
+	)
+	counts := countRoutes(t, router.Endpoint(), context.Background(), n)
+	if want, have := 0.1, float64(counts["canary"])/float64(n); math.Abs(want-have) > 0.02 {
+		t.Errorf("want %.2f of requests to canary, have %.2f", want, have)
+	}
+
+	// Promote the canary.
+	if err := router.SetWeight("canary", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.SetWeight("stable", 0); err != nil {
+		t.Fatal(err)
+	}
+	counts = countRoutes(t, router.Endpoint(), context.Background(), 100)
+	if want, have := 100, counts["canary"]; want != have {
+		t.Errorf("want %d requests to canary, have %d", want, have)
+	}
+	if want, have := map[string]int{"stable": 0, "canary": 100}, router.Weights(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	if err := router.SetWeight("nope", 1); err == nil {
+		t.Error("want error for unknown route")
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	router := lb.NewRouter(
+		lb.Route[interface{}, interface{}]{Name: "stable", Balancer: version("stable"), Weight: 1},
+		lb.Route[interface{}, interface{}]{
+			Name:     "canary",
+			Balancer: version("canary"),
+			Match: func(ctx context.Context, _ interface{}) bool {
+				return ctx.Value(versionKey{}) == "canary"
+			},
+		},
+	)
+	counts := countRoutes(t, router.Endpoint(), context.WithValue(context.Background(), versionKey{}, "canary"), 100)
+	if want, have := 100, counts["canary"]; want != have {
+		t.Errorf("want %d matched requests to canary, have %d", want, have)
+	}
+	counts = countRoutes(t, router.Endpoint(), context.Background(), 100)
+	if want, have := 100, counts["stable"]; want != have {
+		t.Errorf("want %d unmatched requests to stable, have %d", want, have)
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	router := lb.NewRouter(lb.Route[interface{}, interface{}]{Name: "stable", Balancer: version("stable")})
+	if _, err := router.Endpoint()(context.Background(), struct{}{}); err != lb.ErrNoRoute {
+		t.Errorf("want %v, have %v", lb.ErrNoRoute, err)
+	}
+
+	router = lb.NewRouter(lb.Route[interface{}, interface{}]{
+		Name:     "empty",
+		Balancer: lb.NewRoundRobin[interface{}, interface{}](sd.FixedEndpointer[interface{}, interface{}]{}),
+		Weight:   1,
+	})
+	if _, err := router.Endpoint()(context.Background(), struct{}{}); err != lb.ErrNoEndpoints {
+		t.Errorf("want %v, have %v", lb.ErrNoEndpoints, err)
+	}
+}
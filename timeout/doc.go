@@ -0,0 +1,8 @@
+// Package timeout bounds the time an endpoint may take to serve a request.
+//
+// Middleware replaces ad hoc context.WithTimeout calls with a consistent
+// timeout and a typed Error that transports map to 504 Gateway Timeout and
+// codes.DeadlineExceeded. Combined with the deadline propagation the
+// transports offer, see transport.BudgetHeader, a chain of calls across
+// services shares a single budget.
+package timeout
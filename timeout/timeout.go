@@ -0,0 +1,70 @@
+package timeout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+)
+
+// Middleware returns an endpoint.Middleware that gives the wrapped endpoint at
+// most d to serve each request, by way of its context. If the context's
+// deadline passes and the endpoint fails, the failure is reported as an Error.
+// A deadline already set on the incoming context is kept if it's earlier.
+//
+// The wrapped endpoint must honor context cancellation for the timeout to
+// take effect.
+func Middleware[Request, Response any](d time.Duration) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			start := time.Now()
+			ctx, cancel := context.WithDeadline(ctx, start.Add(d))
+			defer cancel()
+
+			response, err := next(ctx, request)
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				deadline, _ := ctx.Deadline()
+				timeout := deadline.Sub(start)
+				if timeout < 0 {
+					timeout = 0 // the incoming context had expired already
+				}
+				return *new(Response), Error{Timeout: timeout}
+			}
+			return response, err
+		}
+	}
+}
+
+// Error is returned by Middleware when the wrapped endpoint doesn't complete
+// in time. It matches context.DeadlineExceeded with errors.Is.
+type Error struct {
+	// Timeout is how long the endpoint was given, which may be less than the
+	// Middleware's duration if the incoming context had an earlier deadline.
+	Timeout time.Duration
+}
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return fmt.Sprintf("endpoint timed out after %v", e.Timeout)
+}
+
+// Is reports whether target is context.DeadlineExceeded.
+func (e Error) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// StatusCode implements the StatusCoder interface of the HTTP transport.
+func (e Error) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// GRPCStatus allows the gRPC transport to report the error as
+// codes.DeadlineExceeded.
+func (e Error) GRPCStatus() *status.Status {
+	return status.New(codes.DeadlineExceeded, e.Error())
+}
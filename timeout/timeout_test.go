@@ -0,0 +1,98 @@
+package timeout_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/timeout"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+func blocking(ctx context.Context, _ interface{}) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestMiddlewareTimesOut(t *testing.T) {
+	e := timeout.Middleware[interface{}, interface{}](10 * time.Millisecond)(blocking)
+
+	_, err := e(context.Background(), struct{}{})
+	var terr timeout.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("want timeout.Error, have %v", err)
+	}
+	if want, have := 10*time.Millisecond, terr.Timeout; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("want error to match context.DeadlineExceeded")
+	}
+}
+
+func TestMiddlewareEarlierDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	e := timeout.Middleware[interface{}, interface{}](time.Hour)(blocking)
+
+	_, err := e(ctx, struct{}{})
+	var terr timeout.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("want timeout.Error, have %v", err)
+	}
+	if terr.Timeout > 10*time.Millisecond {
+		t.Errorf("want at most 10ms, have %v", terr.Timeout)
+	}
+}
+
+func TestMiddlewareExpiredDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	e := timeout.Middleware[interface{}, interface{}](time.Hour)(blocking)
+
+	_, err := e(ctx, struct{}{})
+	var terr timeout.Error
+	if !errors.As(err, &terr) {
+		t.Fatalf("want timeout.Error, have %v", err)
+	}
+	if want, have := time.Duration(0), terr.Timeout; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestMiddlewarePassesThrough(t *testing.T) {
+	errBoom := errors.New("boom")
+	for _, testcase := range []struct {
+		e   endpoint.Endpoint[interface{}, interface{}]
+		err error
+	}{
+		{endpoint.Nop, nil},
+		{func(context.Context, interface{}) (interface{}, error) { return nil, errBoom }, errBoom},
+	} {
+		e := timeout.Middleware[interface{}, interface{}](time.Second)(testcase.e)
+		if _, err := e(context.Background(), struct{}{}); err != testcase.err {
+			t.Errorf("want %v, have %v", testcase.err, err)
+		}
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	err := timeout.Error{Timeout: time.Second}
+
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), err, rec)
+	if want, have := http.StatusGatewayTimeout, rec.Code; want != have {
+		t.Errorf("HTTP: want %d, have %d", want, have)
+	}
+
+	if want, have := codes.DeadlineExceeded, status.Code(err); want != have {
+		t.Errorf("gRPC: want %v, have %v", want, have)
+	}
+}
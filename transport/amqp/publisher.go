@@ -2,9 +2,12 @@ package amqp
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -73,7 +76,10 @@ func PublisherTimeout[Request, Response any](timeout time.Duration) PublisherOpt
 	return func(p *Publisher[Request, Response]) { p.timeout = timeout }
 }
 
-// Endpoint returns a usable endpoint that invokes the remote endpoint.
+// Endpoint returns a usable endpoint that invokes the remote endpoint. If the
+// reply carries an *errcode.Error, as written by ReplyErrorEncoder, that Error
+// is returned instead of calling the DecodeResponseFunc, once the
+// PublisherResponseFuncs have been applied.
 func (p Publisher[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
 	return func(ctx context.Context, request Request) (Response, error) {
 		ctx, cancel := context.WithTimeout(ctx, p.timeout)
@@ -101,6 +107,12 @@ func (p Publisher[Request, Response]) Endpoint() endpoint.Endpoint[Request, Resp
 		for _, f := range p.after {
 			ctx = f(ctx, deliv)
 		}
+
+		if deliv != nil {
+			if _, ok := deliv.Headers[errcode.Header]; ok {
+				return *new(Response), decodeError(deliv)
+			}
+		}
 		response, err := p.dec(ctx, deliv)
 		if err != nil {
 			return *new(Response), err
@@ -186,3 +198,19 @@ func SendAndForgetDeliverer[Request, Response any](
 	)
 	return nil, err
 }
+
+// decodeError decodes the *errcode.Error in a reply written by
+// ReplyErrorEncoder.
+func decodeError(deliv *amqp.Delivery) error {
+	code, _ := errcode.ParseCode(fmt.Sprint(deliv.Headers[errcode.Header]))
+	var response DefaultErrorResponse
+	if err := json.Unmarshal(deliv.Body, &response); err != nil {
+		return errcode.New(code, "")
+	}
+	return &errcode.Error{
+		Code:       code,
+		Message:    response.Error,
+		Details:    response.Details,
+		Violations: response.Violations,
+	}
+}
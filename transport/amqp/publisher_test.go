@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/openmesh/kit/errcode"
 	amqptransport "github.com/openmesh/kit/transport/amqp"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -268,3 +270,54 @@ func TestSendAndForgetPublisher(t *testing.T) {
 	}
 
 }
+
+// TestPublisherDecodesErrcodeError tests that an *errcode.Error replied by
+// ReplyErrorEncoder is decoded by the publisher.
+func TestPublisherDecodesErrcodeError(t *testing.T) {
+	want := errcode.New(errcode.NotFound, "no such squadron").WithDetail("squadron", "437")
+
+	replies := make(chan amqp.Publishing, 1)
+	sub := amqptransport.NewSubscriber(
+		func(context.Context, interface{}) (interface{}, error) { return nil, want },
+		func(context.Context, *amqp.Delivery) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, *amqp.Publishing, interface{}) error { return nil },
+		amqptransport.SubscriberErrorEncoder[interface{}, interface{}](amqptransport.ReplyErrorEncoder),
+	)
+	sub.ServeDelivery(&mockChannel{f: nullFunc, c: replies})(&amqp.Delivery{})
+	reply := <-replies
+
+	cid := "correlation"
+	ch := &mockChannel{
+		f: nullFunc,
+		c: make(chan amqp.Publishing, 1),
+		deliveries: []amqp.Delivery{
+			{CorrelationId: cid, Headers: reply.Headers, Body: reply.Body},
+		},
+	}
+	var after bool
+	pub := amqptransport.NewPublisher(
+		ch,
+		&amqp.Queue{Name: "some queue"},
+		testReqEncoder,
+		testResDeliveryDecoder,
+		amqptransport.PublisherBefore[interface{}, interface{}](
+			amqptransport.SetCorrelationID(cid),
+		),
+		amqptransport.PublisherAfter[interface{}, interface{}](func(ctx context.Context, _ *amqp.Delivery) context.Context {
+			after = true
+			return ctx
+		}),
+	)
+	_, err := pub.Endpoint()(context.Background(), testReq{437})
+	if !after {
+		t.Error("want PublisherAfter applied to error replies")
+	}
+
+	have, ok := err.(*errcode.Error)
+	if !ok {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
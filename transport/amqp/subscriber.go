@@ -3,10 +3,13 @@ package amqp
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 	"github.com/openmesh/kit/transport"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -21,6 +24,7 @@ type Subscriber[Request, Response any] struct {
 	responsePublisher ResponsePublisher
 	errorEncoder      ErrorEncoder
 	errorHandler      transport.ErrorHandler
+	budget            bool
 }
 
 // NewSubscriber constructs a new subscriber, which provides a handler
@@ -92,6 +96,14 @@ func SubscriberErrorHandler[Request, Response any](errorHandler transport.ErrorH
 	return func(s *Subscriber[Request, Response]) { s.errorHandler = errorHandler }
 }
 
+// SubscriberBudget makes the subscriber restore the transport.BudgetHeader
+// header of deliveries as the deadline of the request context. Only use it if
+// publishers are trusted, since a publisher can then shorten the time given
+// to the endpoint. By default, the header is ignored.
+func SubscriberBudget[Request, Response any]() SubscriberOption[Request, Response] {
+	return func(s *Subscriber[Request, Response]) { s.budget = true }
+}
+
 // ServeDelivery handles AMQP Delivery messages
 // It is strongly recommended to use *amqp.Channel as the
 // Channel interface implementation.
@@ -100,6 +112,12 @@ func (s Subscriber[Request, Response]) ServeDelivery(ch Channel) func(deliv *amq
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		if budget, ok := deliv.Headers[transport.BudgetHeader]; ok && s.budget {
+			var cancelBudget context.CancelFunc
+			ctx, cancelBudget = transport.WithBudget(ctx, fmt.Sprint(budget))
+			defer cancelBudget()
+		}
+
 		pub := amqp.Publishing{}
 
 		for _, f := range s.before {
@@ -240,7 +258,9 @@ func SingleNackRequeueErrorEncoder(ctx context.Context,
 }
 
 // ReplyErrorEncoder serializes the error message as a DefaultErrorResponse
-// JSON and sends the message to the ReplyTo address.
+// JSON and sends the message to the ReplyTo address. If the error is, or
+// wraps, an *errcode.Error, its code, details and violations are included,
+// and the code is also set in the errcode.Header header of the message.
 func ReplyErrorEncoder(
 	ctx context.Context,
 	err error,
@@ -259,7 +279,20 @@ func ReplyErrorEncoder(
 		replyTo = deliv.ReplyTo
 	}
 
-	response := DefaultErrorResponse{err.Error()}
+	response := DefaultErrorResponse{Error: err.Error()}
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		response = DefaultErrorResponse{
+			Error:      e.Error(),
+			Code:       e.Code.String(),
+			Details:    e.Details,
+			Violations: e.Violations,
+		}
+		if pub.Headers == nil {
+			pub.Headers = amqp.Table{}
+		}
+		pub.Headers[errcode.Header] = e.Code.String()
+	}
 
 	b, err := json.Marshal(response)
 	if err != nil {
@@ -287,7 +320,10 @@ func ReplyAndAckErrorEncoder(ctx context.Context, err error, deliv *amqp.Deliver
 // DefaultErrorResponse is the default structure of responses in the event
 // of an error.
 type DefaultErrorResponse struct {
-	Error string `json:"err"`
+	Error      string              `json:"err"`
+	Code       string              `json:"code,omitempty"`
+	Details    map[string]string   `json:"details,omitempty"`
+	Violations []errcode.Violation `json:"violations,omitempty"`
 }
 
 // Channel is a channel interface to make testing possible.
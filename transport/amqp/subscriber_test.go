@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openmesh/kit/transport"
 	amqptransport "github.com/openmesh/kit/transport/amqp"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -432,3 +433,35 @@ var names = map[int]string{
 	436: "tusker",
 	437: "husky",
 }
+
+// TestSubscriberBudget checks that a budget header is restored as the deadline
+// of the request context.
+func TestSubscriberBudget(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	sub := amqptransport.NewSubscriber(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return nil, errors.New("no deadline")
+			}
+			remaining <- time.Until(deadline)
+			return struct{}{}, nil
+		},
+		func(context.Context, *amqp.Delivery) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, *amqp.Publishing, interface{}) error { return nil },
+		amqptransport.SubscriberResponsePublisher[interface{}, interface{}](amqptransport.NopResponsePublisher),
+		amqptransport.SubscriberBudget[interface{}, interface{}](),
+	)
+
+	ch := &mockChannel{f: nullFunc, c: make(chan amqp.Publishing, 1)}
+	sub.ServeDelivery(ch)(&amqp.Delivery{Headers: amqp.Table{transport.BudgetHeader: int64(5000)}})
+
+	select {
+	case d := <-remaining:
+		if d <= 4*time.Second || d > 5*time.Second {
+			t.Errorf("want about 5s, have %v", d)
+		}
+	default:
+		t.Fatal("endpoint wasn't called with a deadline")
+	}
+}
@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BudgetHeader is the header, or metadata key, in which clients send the time
+// remaining until the deadline of a request, in whole milliseconds. Servers
+// restore it as the deadline of the request context, so that a chain of calls
+// across services shares a single budget.
+//
+// Propagation is off by default, since a server restoring the header lets
+// its clients shorten the time given to its endpoints. The HTTP, gRPC and
+// WebSocket clients send it with their ClientBudget option, and the HTTP and
+// WebSocket servers and the NATS and AMQP subscribers restore it with their
+// ServerBudget or SubscriberBudget option. gRPC servers don't need it, as
+// gRPC propagates deadlines natively.
+const BudgetHeader = "X-Request-Budget"
+
+// Budget returns the time remaining until the deadline of ctx, formatted as
+// the value of BudgetHeader. It returns false if ctx has no deadline.
+func Budget(ctx context.Context) (string, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	return strconv.FormatInt(ms, 10), true
+}
+
+// WithBudget returns a copy of ctx whose deadline is no later than the budget
+// in value, as formatted by Budget, from now. If value is empty or malformed,
+// ctx is returned as it is. The returned cancel function must be called in
+// either case.
+func WithBudget(ctx context.Context, value string) (context.Context, context.CancelFunc) {
+	ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || ms < 0 || ms > math.MaxInt64/int64(time.Millisecond) {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+}
@@ -0,0 +1,69 @@
+package transport_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/transport"
+)
+
+func TestBudgetRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	budget, ok := transport.Budget(ctx)
+	if !ok {
+		t.Fatal("want budget, have none")
+	}
+	if ms, err := strconv.Atoi(budget); err != nil || ms <= 59000 || ms > 60000 {
+		t.Fatalf("want about 60000ms, have %q", budget)
+	}
+
+	restored, cancel := transport.WithBudget(context.Background(), budget)
+	defer cancel()
+	deadline, ok := restored.Deadline()
+	if !ok {
+		t.Fatal("want deadline, have none")
+	}
+	if remaining := time.Until(deadline); remaining <= 59*time.Second || remaining > time.Minute {
+		t.Fatalf("want about a minute, have %v", remaining)
+	}
+}
+
+func TestBudgetNoDeadline(t *testing.T) {
+	if budget, ok := transport.Budget(context.Background()); ok {
+		t.Errorf("want no budget, have %q", budget)
+	}
+}
+
+func TestBudgetExpired(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if budget, _ := transport.Budget(ctx); budget != "0" {
+		t.Errorf("want 0, have %q", budget)
+	}
+}
+
+func TestWithBudgetMalformed(t *testing.T) {
+	for _, value := range []string{"", "soon", "-5", "99999999999999999999"} {
+		ctx, cancel := transport.WithBudget(context.Background(), value)
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("%q: want no deadline", value)
+		}
+		cancel()
+	}
+}
+
+func TestWithBudgetKeepsEarlierDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	want, _ := parent.Deadline()
+
+	ctx, cancel := transport.WithBudget(parent, "60000")
+	defer cancel()
+	if have, _ := ctx.Deadline(); !want.Equal(have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -7,8 +7,11 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/transport"
 )
 
 // Client wraps a gRPC connection and provides a method that implements
@@ -23,6 +26,7 @@ type Client[Request, Response any] struct {
 	before      []ClientRequestFunc
 	after       []ClientResponseFunc
 	finalizer   []ClientFinalizerFunc
+	budget      bool
 }
 
 // NewClient constructs a usable Client for a single remote endpoint.
@@ -82,8 +86,16 @@ func ClientFinalizer[Request, Response any](f ...ClientFinalizerFunc) ClientOpti
 	return func(s *Client[Request, Response]) { s.finalizer = append(s.finalizer, f...) }
 }
 
+// ClientBudget makes the client send the time remaining until the deadline of
+// the context, if any, in the transport.BudgetHeader metadata key, besides
+// the native gRPC deadline, for servers that are reached through a gateway.
+// By default, it isn't sent.
+func ClientBudget[Request, Response any]() ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.budget = true }
+}
+
 // Endpoint returns a usable endpoint that will invoke the gRPC specified by the
-// client.
+// client. If the server replies with the status of an *errcode.Error, that Error is returned.
 func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
 	return func(ctx context.Context, request Request) (response Response, err error) {
 		ctx, cancel := context.WithCancel(ctx)
@@ -105,6 +117,9 @@ func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Respons
 		}
 
 		md := &metadata.MD{}
+		if budget, ok := transport.Budget(ctx); ok && c.budget {
+			md.Set(transport.BudgetHeader, budget)
+		}
 		for _, f := range c.before {
 			ctx = f(ctx, md)
 		}
@@ -116,6 +131,11 @@ func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Respons
 			ctx, c.method, req, grpcReply, grpc.Header(&header),
 			grpc.Trailer(&trailer),
 		); err != nil {
+			if s, ok := status.FromError(err); ok {
+				if e, ok := errcode.FromGRPCStatus(s); ok {
+					err = e
+				}
+			}
 			return *new(Response), err
 		}
 
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+// Bind sets the fields of the struct v points to from the path params, query
+// parameters and headers of r, as named by the path, query and header struct
+// tags of the fields. A ",required" suffix makes a parameter mandatory.
+//
+//	type getUsersRequest struct {
+//		Org   string   `path:"org"`
+//		Limit int      `query:"limit"`
+//		Tags  []string `query:"tag"`
+//		Token string   `header:"X-Token,required"`
+//	}
+//
+// Fields may be strings, booleans, numbers, time.Durations, types that
+// implement encoding.TextUnmarshaler, or pointers or slices of those. Pointer
+// fields are left nil, and other fields unchanged, when the parameter is
+// absent. Path params are taken from the context, as put there by Router, or
+// else from r.PathValue.
+//
+// Parameters that are missing or malformed are reported together, as an
+// *errcode.Error with the InvalidArgument code and a violation per parameter,
+// which servers encode as a 400.
+func Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("kithttp: Bind needs a pointer to a struct, have %T", v)
+	}
+	b := binder{r: r, params: PathParamsFromContext(r.Context())}
+	if err := b.bind(rv.Elem()); err != nil {
+		return err
+	}
+	if b.invalid != nil {
+		return b.invalid
+	}
+	return nil
+}
+
+// DecodeParams returns a DecodeRequestFunc that binds the parameters of the
+// request into a new Request with Bind. Request must be a struct, or a pointer
+// to one.
+func DecodeParams[Request any]() DecodeRequestFunc[Request] {
+	return func(_ context.Context, r *http.Request) (Request, error) {
+		request, target := newTarget[Request]()
+		if err := Bind(r, target); err != nil {
+			return *new(Request), err
+		}
+		return *request, nil
+	}
+}
+
+type binder struct {
+	r       *http.Request
+	params  PathParams
+	invalid *errcode.Error
+}
+
+func (b *binder) bind(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		source, name, required, ok := parseBindTag(field)
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := b.bind(v.Field(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		values := b.lookup(source, name)
+		if len(values) == 0 {
+			if required {
+				b.violation(name, source+" parameter is required")
+			}
+			continue
+		}
+		if err := setField(v.Field(i), values); err != nil {
+			var pe parseError
+			if !errors.As(err, &pe) {
+				return fmt.Errorf("kithttp: binding field %s: %w", field.Name, err)
+			}
+			b.violation(name, fmt.Sprintf("%s parameter %s", source, pe.msg))
+		}
+	}
+	return nil
+}
+
+func (b *binder) lookup(source, name string) []string {
+	switch source {
+	case "path":
+		if value, ok := b.params[name]; ok {
+			return []string{value}
+		}
+		if value := b.r.PathValue(name); value != "" {
+			return []string{value}
+		}
+		return nil
+	case "query":
+		return b.r.URL.Query()[name]
+	default:
+		return b.r.Header.Values(name)
+	}
+}
+
+func (b *binder) violation(name, description string) {
+	if b.invalid == nil {
+		b.invalid = errcode.New(errcode.InvalidArgument, "invalid request parameters")
+	}
+	b.invalid.WithViolation(name, description)
+}
+
+func parseBindTag(field reflect.StructField) (source, name string, required, ok bool) {
+	for _, source := range []string{"path", "query", "header"} {
+		tag, ok := field.Tag.Lookup(source)
+		if !ok || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		return source, name, opts == "required", true
+	}
+	return "", "", false, false
+}
+
+// parseError is a malformed parameter, as opposed to an unsupported field.
+type parseError struct{ msg string }
+
+func (e parseError) Error() string { return e.msg }
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+func setField(v reflect.Value, values []string) error {
+	switch {
+	case reflect.PointerTo(v.Type()).Implements(textUnmarshalerType):
+		return setValue(v, values[0])
+	case v.Kind() == reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := setField(p.Elem(), values); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case v.Kind() == reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return parseError{fmt.Sprintf("is invalid: %v", err)}
+		}
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return parseError{fmt.Sprintf("must be a duration, have %q", s)}
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		x, err := strconv.ParseBool(s)
+		if err != nil {
+			return parseError{fmt.Sprintf("must be a boolean, have %q", s)}
+		}
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return parseError{fmt.Sprintf("must be an integer, have %q", s)}
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return parseError{fmt.Sprintf("must be a non-negative integer, have %q", s)}
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return parseError{fmt.Sprintf("must be a number, have %q", s)}
+		}
+		v.SetFloat(x)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
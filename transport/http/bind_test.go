@@ -0,0 +1,112 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type listUsersRequest struct {
+	Org      string        `path:"org"`
+	Limit    int           `query:"limit"`
+	Active   *bool         `query:"active"`
+	Tags     []string      `query:"tag"`
+	Since    time.Time     `query:"since"`
+	Timeout  time.Duration `query:"timeout"`
+	Token    string        `header:"X-Token,required"`
+	Ignored  string
+	internal string `query:"internal"`
+}
+
+func TestBind(t *testing.T) {
+	var (
+		have   listUsersRequest
+		router = httptransport.NewRouter()
+	)
+	router.Handle(http.MethodGet, "/orgs/{org}/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := httptransport.Bind(r, &have); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/orgs/acme/users?limit=10&active=true&tag=a&tag=b&since=2024-01-02T03:04:05Z&timeout=1s&internal=x", nil)
+	r.Header.Set("X-Token", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	active := true
+	want := listUsersRequest{
+		Org:     "acme",
+		Limit:   10,
+		Active:  &active,
+		Tags:    []string{"a", "b"},
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout: time.Second,
+		Token:   "secret",
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?limit=ten&active=maybe&since=yesterday", nil)
+	var req listUsersRequest
+	err := httptransport.Bind(r, &req)
+
+	var e *errcode.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if want, have := http.StatusBadRequest, e.StatusCode(); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	var fields []string
+	for _, v := range e.Violations {
+		fields = append(fields, v.Field)
+	}
+	if want := []string{"limit", "active", "since", "X-Token"}; !reflect.DeepEqual(want, fields) {
+		t.Errorf("want violations of %v, have %v", want, e.Violations)
+	}
+	if want, have := `query parameter must be an integer, have "ten"`, e.Violations[0].Description; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	if err := httptransport.Bind(r, req); err == nil || errors.As(err, &e) && e.Code == errcode.InvalidArgument {
+		t.Errorf("want a programming error for a non-pointer, have %v", err)
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	type getUserRequest struct {
+		ID uint64 `path:"id"`
+	}
+	var (
+		have   *getUserRequest
+		router = httptransport.NewRouter()
+	)
+	router.Handle(http.MethodGet, "/users/{id}", httptransport.NewServer(
+		func(_ context.Context, request *getUserRequest) (interface{}, error) {
+			have = request
+			return struct{}{}, nil
+		},
+		httptransport.DecodeParams[*getUserRequest](),
+		httptransport.EncodeJSONResponse[interface{}],
+	))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if have == nil || have.ID != 42 {
+		t.Fatalf("want ID 42, have %+v", have)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/-1", nil))
+	if want, have := http.StatusBadRequest, rec.Code; want != have {
+		t.Errorf("want %d, have %d: %s", want, have, rec.Body)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"net/url"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/transport"
 )
 
 // HTTPClient is an interface that models *http.Client.
@@ -27,6 +29,10 @@ type Client[Request, Response any] struct {
 	after          []ClientResponseFunc
 	finalizer      []ClientFinalizerFunc
 	bufferedStream bool
+	compression    *compression
+	cache          ResponseCache
+	decodeErrors   bool
+	budget         bool
 }
 
 // NewClient constructs a usable Client for a single remote method.
@@ -86,6 +92,23 @@ func BufferedStream[Request, Response any](buffered bool) ClientOption[Request,
 	return func(c *Client[Request, Response]) { c.bufferedStream = buffered }
 }
 
+// ClientDecodeErrors makes the client decode the errors servers reply with,
+// and return them, instead of calling the DecodeResponseFunc. Those are the
+// responses with a status code of 400 or more that carry an *errcode.Error,
+// as indicated by the errcode.Header header, or problem details. See
+// DecodeError. By default, every response is passed to the
+// DecodeResponseFunc, which may call DecodeError itself.
+func ClientDecodeErrors[Request, Response any]() ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.decodeErrors = true }
+}
+
+// ClientBudget makes the client send the time remaining until the deadline of
+// the context, if any, in the transport.BudgetHeader header. By default, it
+// isn't sent.
+func ClientBudget[Request, Response any]() ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.budget = true }
+}
+
 // Endpoint returns a usable Go kit endpoint that calls the remote HTTP endpoint.
 func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
 	return func(ctx context.Context, request Request) (Response, error) {
@@ -113,11 +136,22 @@ func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Respons
 			return *new(Response), err
 		}
 
+		if budget, ok := transport.Budget(ctx); ok && c.budget {
+			req.Header.Set(transport.BudgetHeader, budget)
+		}
+
 		for _, f := range c.before {
 			ctx = f(ctx, req)
 		}
 
-		resp, err = c.client.Do(req.WithContext(ctx))
+		if c.compression != nil {
+			if err = c.compression.compressRequest(req); err != nil {
+				cancel()
+				return *new(Response), err
+			}
+		}
+
+		resp, err = c.do(req.WithContext(ctx))
 		if err != nil {
 			cancel()
 			return *new(Response), err
@@ -137,6 +171,13 @@ func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Respons
 			ctx = f(ctx, resp)
 		}
 
+		if c.decodeErrors && resp.StatusCode >= 400 && isDecodableError(resp) {
+			if c.bufferedStream {
+				defer resp.Body.Close()
+			}
+			return *new(Response), DecodeError(resp)
+		}
+
 		response, err := c.dec(ctx, resp)
 		if err != nil {
 			return *new(Response), err
@@ -160,6 +201,38 @@ func (bwc bodyWithCancel) Close() error {
 	return nil
 }
 
+// isDecodableError reports whether DecodeError understands the body of
+// resp.
+func isDecodableError(resp *http.Response) bool {
+	return isProblem(resp.Header) || resp.Header.Get(errcode.Header) != ""
+}
+
+// DecodeError decodes the error in the body of an error response, as written
+// by DefaultErrorEncoder or CodecErrorEncoder for an *errcode.Error, in JSON
+// or XML, or by ProblemErrorEncoder. Clients with the ClientDecodeErrors
+// option call it for responses with such errors. Problem details are decoded
+// by DecodeProblem. An *errcode.Error that can't be decoded is built from the
+// errcode.Header header and the status code. Other responses are returned as
+// an *errcode.Error with the Unknown code and the status text.
+func DecodeError(resp *http.Response) error {
+	if isProblem(resp.Header) {
+		return DecodeProblem(resp)
+	}
+	if resp.Header.Get(errcode.Header) == "" {
+		return errcode.New(errcode.Unknown, http.StatusText(resp.StatusCode))
+	}
+	e := &errcode.Error{}
+	decode := json.NewDecoder(resp.Body).Decode
+	if mediaType := mediaTypeOf(resp.Header.Get("Content-Type")); mediaType == "application/xml" || mediaType == "text/xml" {
+		decode = xml.NewDecoder(resp.Body).Decode
+	}
+	if err := decode(e); err != nil {
+		code, _ := errcode.ParseCode(resp.Header.Get(errcode.Header))
+		return errcode.New(code, http.StatusText(resp.StatusCode))
+	}
+	return e
+}
+
 // ClientFinalizerFunc can be used to perform work at the end of a client HTTP
 // request, after the response is returned. The principal
 // intended use is for error logging. Additional response parameters are
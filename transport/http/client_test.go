@@ -9,10 +9,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/openmesh/kit/errcode"
 	httptransport "github.com/openmesh/kit/transport/http"
 )
 
@@ -348,3 +350,64 @@ type httpClientFunc func(req *http.Request) (*http.Response, error)
 func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestClientDecodesErrcodeError(t *testing.T) {
+	want := errcode.New(errcode.NotFound, "no such squadron").WithDetail("squadron", "437")
+
+	server := httptest.NewServer(httptransport.NewServer(
+		func(context.Context, interface{}) (interface{}, error) { return nil, want },
+		func(context.Context, *http.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+	))
+	defer server.Close()
+
+	client := httptransport.NewClient(
+		"GET",
+		mustParse(server.URL),
+		func(context.Context, *http.Request, interface{}) error { return nil },
+		func(context.Context, *http.Response) (interface{}, error) {
+			t.Fatal("decode should not be called for an errcode error")
+			return nil, nil
+		},
+		httptransport.ClientDecodeErrors[interface{}, interface{}](),
+	)
+	_, err := client.Endpoint()(context.Background(), struct{}{})
+
+	have, ok := err.(*errcode.Error)
+	if !ok {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestClientDecodesErrorsOptIn(t *testing.T) {
+	server := httptest.NewServer(httptransport.NewServer(
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, errcode.New(errcode.NotFound, "nope")
+		},
+		func(context.Context, *http.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+	))
+	defer server.Close()
+
+	// Without ClientDecodeErrors, the DecodeResponseFunc gets error
+	// responses too.
+	var decoded int
+	client := httptransport.NewClient(
+		"GET",
+		mustParse(server.URL),
+		func(context.Context, *http.Request, interface{}) error { return nil },
+		func(_ context.Context, resp *http.Response) (interface{}, error) {
+			decoded = resp.StatusCode
+			return nil, nil
+		},
+	)
+	if _, err := client.Endpoint()(context.Background(), struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusNotFound, decoded; want != have {
+		t.Errorf("want %d decoded, have %d", want, have)
+	}
+}
@@ -0,0 +1,407 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+// Codec marshals and unmarshals values in one media type.
+type Codec interface {
+	// ContentType is the Content-Type header of marshaled values, e.g.
+	// "application/json; charset=utf-8". Its media type is the one the
+	// Codec is registered for.
+	ContentType() string
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is a Codec for application/json.
+type JSONCodec struct{}
+
+// ContentType implements Codec.
+func (JSONCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+// Marshal implements Codec.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// XMLCodec is a Codec for application/xml. It's also registered for text/xml
+// in DefaultCodecs, to decode requests sent with EncodeXMLRequest.
+type XMLCodec struct{}
+
+// ContentType implements Codec.
+func (XMLCodec) ContentType() string { return "application/xml; charset=utf-8" }
+
+// Marshal implements Codec.
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) { return xml.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+var (
+	// ErrUnsupportedMediaType is returned when decoding a request whose
+	// Content-Type has no registered Codec. It's reported as a 415.
+	ErrUnsupportedMediaType error = codecError{"unsupported media type", http.StatusUnsupportedMediaType}
+
+	// ErrNotAcceptable is returned by servers with the ServerCodecs option
+	// when no registered Codec is acceptable to the client. It's reported as
+	// a 406.
+	ErrNotAcceptable error = codecError{"no acceptable media type", http.StatusNotAcceptable}
+)
+
+type codecError struct {
+	msg  string
+	code int
+}
+
+func (e codecError) Error() string   { return e.msg }
+func (e codecError) StatusCode() int { return e.code }
+
+// Codecs is a registry of Codecs by media type, used to pick the Codec of a
+// request by its Content-Type, and of a response by the Accept header of the
+// request. It's safe for concurrent use.
+type Codecs struct {
+	mtx     sync.RWMutex
+	entries []codecEntry // in order of preference
+}
+
+type codecEntry struct {
+	mediaType string
+	codec     Codec
+}
+
+// DefaultCodecs holds JSONCodec, which is the default, and XMLCodec.
+var DefaultCodecs = NewCodecs(JSONCodec{}, XMLCodec{})
+
+func init() {
+	DefaultCodecs.Register(XMLCodec{}, "text/xml")
+}
+
+// NewCodecs returns a registry of the given codecs, each registered for the
+// media type of its ContentType. The first one is the default, used when the
+// client states no preference. When the client finds several codecs equally
+// acceptable, they're preferred in the order they were registered.
+func NewCodecs(codecs ...Codec) *Codecs {
+	c := &Codecs{}
+	for _, codec := range codecs {
+		c.Register(codec)
+	}
+	return c
+}
+
+// Register adds a Codec for the media type of its ContentType, and for each
+// of the additional media types, e.g. "application/protobuf" along with
+// "application/x-protobuf". A Codec registered again for a media type
+// replaces the previous one.
+func (c *Codecs) Register(codec Codec, mediaTypes ...string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, mediaType := range append([]string{mediaTypeOf(codec.ContentType())}, mediaTypes...) {
+		mediaType = strings.ToLower(mediaType)
+		replaced := false
+		for i, e := range c.entries {
+			if e.mediaType == mediaType {
+				c.entries[i].codec, replaced = codec, true
+			}
+		}
+		if !replaced {
+			c.entries = append(c.entries, codecEntry{mediaType, codec})
+		}
+	}
+}
+
+// Default returns the first registered Codec.
+func (c *Codecs) Default() (Codec, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if len(c.entries) == 0 {
+		return nil, false
+	}
+	return c.entries[0].codec, true
+}
+
+// Lookup returns the Codec registered for the media type of a Content-Type
+// header. An empty contentType gets the default Codec.
+func (c *Codecs) Lookup(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return c.Default()
+	}
+	mediaType := mediaTypeOf(contentType)
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	for _, e := range c.entries {
+		if e.mediaType == mediaType {
+			return e.codec, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate returns the registered Codec most acceptable according to an
+// Accept header, with its quality values and wildcards. An empty accept gets
+// the default Codec. It returns false if no Codec is acceptable.
+func (c *Codecs) Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return c.Default()
+	}
+	ranges := parseAccept(accept)
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, e := range c.entries {
+		if q := quality(ranges, e.mediaType); q > bestQ {
+			best, bestQ = e.codec, q
+		}
+	}
+	return best, best != nil
+}
+
+// mediaRange is an element of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity ranks how closely a media range matches a media type it
+// matches: */* less than type/*, less than type/subtype.
+func (r mediaRange) specificity() int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+func (r mediaRange) matches(typ, subtype string) bool {
+	return (r.typ == "*" || r.typ == typ) && (r.subtype == "*" || r.subtype == subtype)
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ, subtype, q})
+	}
+	// The most specific range matching a media type sets its quality.
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].specificity() > ranges[j].specificity() })
+	return ranges
+}
+
+// quality returns the quality value the ranges give to mediaType, or 0 if
+// it's not acceptable.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	for _, r := range ranges {
+		if r.matches(typ, subtype) {
+			return r.q
+		}
+	}
+	return 0
+}
+
+func mediaTypeOf(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// ServerCodecs makes the server negotiate the Codec of the response from the
+// Accept header of each request, before decoding it, and reply with
+// ErrNotAcceptable if there's none. The negotiated Codec is put in the
+// context under ContextKeyResponseCodec, for EncodeResponse and
+// CodecErrorEncoder.
+func ServerCodecs[Request, Response any](codecs *Codecs) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.codecs = codecs }
+}
+
+// DecodeRequest returns a DecodeRequestFunc that unmarshals the request body
+// with the Codec registered for its Content-Type. It returns
+// ErrUnsupportedMediaType if there's none, and an errcode.InvalidArgument
+// error if the body can't be unmarshaled. If Request is a pointer type, e.g.
+// a generated protobuf message, the value it points to is allocated.
+func DecodeRequest[Request any](codecs *Codecs) DecodeRequestFunc[Request] {
+	return func(_ context.Context, r *http.Request) (Request, error) {
+		codec, ok := codecs.Lookup(r.Header.Get("Content-Type"))
+		if !ok {
+			return *new(Request), ErrUnsupportedMediaType
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return *new(Request), err
+		}
+		request, target := newTarget[Request]()
+		if err := codec.Unmarshal(b, target); err != nil {
+			return *new(Request), errcode.Errorf(errcode.InvalidArgument, "decoding request: %v", err)
+		}
+		return *request, nil
+	}
+}
+
+// EncodeResponse returns an EncodeResponseFunc that marshals the response
+// with the Codec negotiated by a server with the ServerCodecs option, or else
+// with the default Codec of codecs. Like EncodeJSONResponse, it applies the
+// Headerer and StatusCoder interfaces of the response.
+func EncodeResponse[Response any](codecs *Codecs) EncodeResponseFunc[Response] {
+	return func(ctx context.Context, w http.ResponseWriter, response Response) error {
+		codec, ok := responseCodec(ctx, codecs)
+		if !ok {
+			return ErrNotAcceptable
+		}
+		b, err := codec.Marshal(response)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", codec.ContentType())
+		if headerer, ok := interface{}(response).(Headerer); ok {
+			for k, values := range headerer.Headers() {
+				for _, v := range values {
+					w.Header().Add(k, v)
+				}
+			}
+		}
+		code := http.StatusOK
+		if sc, ok := interface{}(response).(StatusCoder); ok {
+			code = sc.StatusCode()
+		}
+		w.WriteHeader(code)
+		if code == http.StatusNoContent {
+			return nil
+		}
+		_, err = w.Write(b)
+		return err
+	}
+}
+
+// CodecErrorEncoder returns an ErrorEncoder that marshals *errcode.Errors
+// with the Codec negotiated by a server with the ServerCodecs option, or else
+// with the default Codec of codecs. Other errors, and errors the Codec can't
+// marshal, are encoded by DefaultErrorEncoder.
+func CodecErrorEncoder(codecs *Codecs) ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		var e *errcode.Error
+		if !errors.As(err, &e) {
+			DefaultErrorEncoder(ctx, err, w)
+			return
+		}
+		codec, ok := responseCodec(ctx, codecs)
+		if !ok {
+			DefaultErrorEncoder(ctx, e, w)
+			return
+		}
+		body, marshalErr := codec.Marshal(e)
+		if marshalErr != nil {
+			DefaultErrorEncoder(ctx, e, w)
+			return
+		}
+		w.Header().Set("Content-Type", codec.ContentType())
+		for k, values := range e.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+		w.WriteHeader(e.StatusCode())
+		w.Write(body)
+	}
+}
+
+// EncodeRequest returns an EncodeRequestFunc that marshals the request with
+// codec, and sets the Content-Type and Accept headers to its content type. If
+// the request implements Headerer, the provided headers will be applied to
+// the request.
+func EncodeRequest[Request any](codec Codec) EncodeRequestFunc[Request] {
+	return func(_ context.Context, r *http.Request, request Request) error {
+		b, err := codec.Marshal(request)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Content-Type", codec.ContentType())
+		r.Header.Set("Accept", mediaTypeOf(codec.ContentType()))
+		if headerer, ok := interface{}(request).(Headerer); ok {
+			for k := range headerer.Headers() {
+				r.Header.Set(k, headerer.Headers().Get(k))
+			}
+		}
+		r.ContentLength = int64(len(b))
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		return nil
+	}
+}
+
+// DecodeResponse returns a DecodeResponseFunc that unmarshals the response
+// body with the Codec registered for its Content-Type. It returns
+// ErrUnsupportedMediaType if there's none. If Response is a pointer type, the
+// value it points to is allocated.
+func DecodeResponse[Response any](codecs *Codecs) DecodeResponseFunc[Response] {
+	return func(_ context.Context, r *http.Response) (Response, error) {
+		codec, ok := codecs.Lookup(r.Header.Get("Content-Type"))
+		if !ok {
+			return *new(Response), ErrUnsupportedMediaType
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return *new(Response), err
+		}
+		response, target := newTarget[Response]()
+		if err := codec.Unmarshal(b, target); err != nil {
+			return *new(Response), err
+		}
+		return *response, nil
+	}
+}
+
+func responseCodec(ctx context.Context, codecs *Codecs) (Codec, bool) {
+	if codec, ok := ctx.Value(ContextKeyResponseCodec).(Codec); ok {
+		return codec, true
+	}
+	return codecs.Default()
+}
+
+// newTarget returns a new T, and the value to unmarshal into it. That's the
+// T itself if it's a pointer, which is allocated, and a pointer to it
+// otherwise.
+func newTarget[T any]() (*T, interface{}) {
+	v := new(T)
+	if t := reflect.TypeOf(v).Elem(); t.Kind() == reflect.Pointer {
+		reflect.ValueOf(v).Elem().Set(reflect.New(t.Elem()))
+		return v, *v
+	}
+	return v, v
+}
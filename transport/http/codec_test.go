@@ -0,0 +1,165 @@
+package http_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type greeting struct {
+	XMLName xml.Name `json:"-" xml:"greeting"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestCodecsNegotiate(t *testing.T) {
+	codecs := httptransport.DefaultCodecs
+	for _, testcase := range []struct {
+		accept string
+		want   string // content type, or empty if not acceptable
+	}{
+		{"", "application/json; charset=utf-8"},
+		{"*/*", "application/json; charset=utf-8"},
+		{"application/xml", "application/xml; charset=utf-8"},
+		{"text/xml", "application/xml; charset=utf-8"},
+		{"application/json;q=0.5, application/xml", "application/xml; charset=utf-8"},
+		{"application/*;q=0.9, application/json;q=0.1", "application/xml; charset=utf-8"},
+		{"text/html, */*;q=0.1", "application/json; charset=utf-8"},
+		{"*/*, application/json;q=0", "application/xml; charset=utf-8"},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+	} {
+		codec, ok := codecs.Negotiate(testcase.accept)
+		var have string
+		if ok {
+			have = codec.ContentType()
+		}
+		if testcase.want != have {
+			t.Errorf("%q: want %q, have %q", testcase.accept, testcase.want, have)
+		}
+	}
+}
+
+func newCodecServer() *httptest.Server {
+	codecs := httptransport.DefaultCodecs
+	return httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, g greeting) (greeting, error) {
+			if g.Name == "" {
+				return greeting{}, errcode.New(errcode.InvalidArgument, "who?")
+			}
+			return greeting{Name: "hello " + g.Name}, nil
+		},
+		httptransport.DecodeRequest[greeting](codecs),
+		httptransport.EncodeResponse[greeting](codecs),
+		httptransport.ServerCodecs[greeting, greeting](codecs),
+		httptransport.ServerErrorEncoder[greeting, greeting](httptransport.CodecErrorEncoder(codecs)),
+	))
+}
+
+func post(t *testing.T, url, contentType, accept, body string) (int, string, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), strings.TrimSpace(string(b))
+}
+
+func TestServerCodecs(t *testing.T) {
+	server := newCodecServer()
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		name, contentType, accept, body string
+		code                            int
+		responseType, response          string
+	}{
+		{
+			"JSON", "application/json", "", `{"name":"Ada"}`,
+			http.StatusOK, "application/json; charset=utf-8", `{"name":"hello Ada"}`,
+		},
+		{
+			"XML in, JSON out", "text/xml", "application/json", `<greeting><name>Ada</name></greeting>`,
+			http.StatusOK, "application/json; charset=utf-8", `{"name":"hello Ada"}`,
+		},
+		{
+			"JSON in, XML out", "application/json", "application/xml", `{"name":"Ada"}`,
+			http.StatusOK, "application/xml; charset=utf-8", `<greeting><name>hello Ada</name></greeting>`,
+		},
+		{
+			"unsupported media type", "text/csv", "", `name\nAda`,
+			http.StatusUnsupportedMediaType, "text/plain; charset=utf-8", "unsupported media type",
+		},
+		{
+			"not acceptable", "application/json", "text/csv", `{"name":"Ada"}`,
+			http.StatusNotAcceptable, "text/plain; charset=utf-8", "no acceptable media type",
+		},
+		{
+			"malformed", "application/json", "", `{"name":`,
+			http.StatusBadRequest, "application/json; charset=utf-8", `{"code":"INVALID_ARGUMENT","message":"decoding request: unexpected end of JSON input"}`,
+		},
+		{
+			"endpoint error", "application/json", "", `{}`,
+			http.StatusBadRequest, "application/json; charset=utf-8", `{"code":"INVALID_ARGUMENT","message":"who?"}`,
+		},
+		{
+			"endpoint error, XML out", "application/json", "application/xml", `{}`,
+			http.StatusBadRequest, "application/xml; charset=utf-8", `<Error><code>INVALID_ARGUMENT</code><message>who?</message></Error>`,
+		},
+	} {
+		code, responseType, response := post(t, server.URL, testcase.contentType, testcase.accept, testcase.body)
+		if testcase.code != code || testcase.responseType != responseType || testcase.response != response {
+			t.Errorf("%s: want %d %q %s, have %d %q %s", testcase.name,
+				testcase.code, testcase.responseType, testcase.response,
+				code, responseType, response)
+		}
+	}
+}
+
+func TestCodecClient(t *testing.T) {
+	server := newCodecServer()
+	defer server.Close()
+
+	client := httptransport.NewClient(
+		http.MethodPost,
+		mustParse(server.URL),
+		httptransport.EncodeRequest[greeting](httptransport.XMLCodec{}),
+		httptransport.DecodeResponse[greeting](httptransport.DefaultCodecs),
+		httptransport.ClientDecodeErrors[greeting, greeting](),
+	)
+	response, err := client.Endpoint()(context.Background(), greeting{Name: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hello Ada", response.Name; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	_, err = client.Endpoint()(context.Background(), greeting{})
+	if want, have := errcode.New(errcode.InvalidArgument, "who?"), err; !reflect.DeepEqual(want, have) {
+		t.Errorf("want %#v, have %#v", want, have)
+	}
+}
+
+func TestCodecsRegister(t *testing.T) {
+	codecs := httptransport.NewCodecs(httptransport.JSONCodec{})
+	codecs.Register(httptransport.JSONCodec{}, "application/vnd.api+json")
+	if _, ok := codecs.Lookup("application/vnd.api+json; charset=utf-8"); !ok {
+		t.Error("want codec registered for additional media type")
+	}
+	if _, ok := codecs.Lookup("application/xml"); ok {
+		t.Error("want no codec for unregistered media type")
+	}
+}
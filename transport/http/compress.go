@@ -0,0 +1,548 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings supported by ServerCompression and ClientCompression.
+const (
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// ErrUnsupportedEncoding is returned by servers with the ServerCompression
+// option when the request body has a content coding that isn't supported.
+// It's reported as a 415.
+var ErrUnsupportedEncoding error = codecError{"unsupported content encoding", http.StatusUnsupportedMediaType}
+
+// compression holds the parameters of ServerCompression and
+// ClientCompression.
+type compression struct {
+	encodings   []string // in order of preference
+	minSize     int
+	types       []string
+	maxInflated int64
+}
+
+// CompressionOption sets an optional parameter for ServerCompression and
+// ClientCompression.
+type CompressionOption func(*compression)
+
+// CompressionEncodings sets the content codings used, out of EncodingZstd,
+// EncodingGzip and EncodingDeflate, in order of preference. Servers use the
+// first of those the client finds most acceptable, and clients compress
+// request bodies with the first. By default, all three are used, in that
+// order.
+func CompressionEncodings(encodings ...string) CompressionOption {
+	return func(c *compression) {
+		c.encodings = c.encodings[:0]
+		for _, e := range encodings {
+			if _, ok := codings[e]; ok {
+				c.encodings = append(c.encodings, e)
+			}
+		}
+	}
+}
+
+// CompressionMinSize sets the size, in bytes, below which bodies aren't
+// compressed, since the overhead of compression isn't worth it. Streamed
+// responses, which are flushed before reaching that size, are compressed
+// regardless. By default, it's 1024.
+func CompressionMinSize(n int) CompressionOption {
+	return func(c *compression) { c.minSize = n }
+}
+
+// CompressionTypes sets the media types of the bodies that are compressed.
+// A media type may be exact, such as "application/json", have a wildcard
+// subtype, such as "text/*", or a wildcard with a structured syntax suffix,
+// such as "application/*+json". By default, text, JSON, XML and JavaScript
+// bodies are compressed.
+func CompressionTypes(types ...string) CompressionOption {
+	return func(c *compression) { c.types = types }
+}
+
+// CompressionMaxInflatedSize sets the size, in bytes, past which servers stop
+// decompressing request bodies, so that a small compressed body can't
+// inflate without bound. Reading past it fails, so that the
+// DecodeRequestFunc returns an error, which the server replaces with
+// ErrBodyTooLarge. ServerMaxBodySize, if smaller, applies as well. Zero or
+// less means no limit. By default, it's 10 MiB. It has no effect on clients.
+func CompressionMaxInflatedSize(n int64) CompressionOption {
+	return func(c *compression) { c.maxInflated = n }
+}
+
+func newCompression(options ...CompressionOption) *compression {
+	c := &compression{
+		encodings: []string{EncodingZstd, EncodingGzip, EncodingDeflate},
+		minSize:   1024,
+		types: []string{
+			"text/*",
+			"application/json",
+			"application/*+json",
+			"application/xml",
+			"application/*+xml",
+			"application/javascript",
+			"image/svg+xml",
+		},
+		maxInflated: 10 << 20,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// compressible reports whether bodies with the given Content-Type are
+// compressed.
+func (c *compression) compressible(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	typ, subtype, _ := strings.Cut(mediaTypeOf(contentType), "/")
+	for _, pattern := range c.types {
+		ptyp, psubtype, _ := strings.Cut(strings.ToLower(pattern), "/")
+		if ptyp != typ {
+			continue
+		}
+		if psubtype == "*" || psubtype == subtype {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(psubtype, "*"); ok && strings.HasSuffix(subtype, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate returns the content coding most acceptable according to an
+// Accept-Encoding header, or "" if the response should not be compressed.
+func (c *compression) negotiate(acceptEncoding string) string {
+	var (
+		qs       = map[string]float64{}
+		wildcard = -1.0
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		qs[coding] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, e := range c.encodings {
+		q, ok := qs[e]
+		if !ok && wildcard >= 0 {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best
+}
+
+// ServerCompression makes the server compress responses with the content
+// coding the client finds most acceptable according to the Accept-Encoding
+// header of the request, if their media type is compressible and they're
+// large enough. Responses that already have a Content-Encoding aren't
+// compressed again. The ETag of compressed responses, and of 304s to
+// requests that accept compression, is made weak, since the compressed and
+// identity representations aren't the same bytes. The server also
+// decompresses request bodies with a supported Content-Encoding, up to the
+// CompressionMaxInflatedSize, and replies with ErrUnsupportedEncoding to
+// those with another.
+func ServerCompression[Request, Response any](options ...CompressionOption) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.compression = newCompression(options...) }
+}
+
+// ClientCompression makes the client compress request bodies with the first
+// of the CompressionEncodings, if their media type is compressible and
+// they're large enough, and ask for compressed responses, which it
+// decompresses before they reach the ClientResponseFuncs.
+func ClientCompression[Request, Response any](options ...CompressionOption) ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.compression = newCompression(options...) }
+}
+
+// decompressRequest replaces the body of r, if it has a Content-Encoding,
+// with its decompressed form, limited to the CompressionMaxInflatedSize. It's
+// given the ResponseWriter of the http.Server, for http.MaxBytesReader.
+func (c *compression) decompressRequest(w http.ResponseWriter, r *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	if _, ok := codings[encoding]; !ok {
+		return ErrUnsupportedEncoding
+	}
+	body, err := newDecompressor(encoding, r.Body)
+	if err != nil {
+		return err
+	}
+	if c.maxInflated > 0 {
+		body = http.MaxBytesReader(w, body, c.maxInflated)
+	}
+	r.Body = body
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+// compressRequest compresses the body of req, if it should be, and asks for
+// a compressed response.
+func (c *compression) compressRequest(req *http.Request) error {
+	if len(c.encodings) == 0 {
+		return nil
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", strings.Join(c.encodings, ", "))
+	}
+	if req.Body == nil || req.Body == http.NoBody ||
+		req.Header.Get("Content-Encoding") != "" ||
+		!c.compressible(req.Header.Get("Content-Type")) {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(body) >= c.minSize {
+		var buf bytes.Buffer
+		enc := getEncoder(c.encodings[0], &buf)
+		_, err := enc.Write(body)
+		if closeErr := enc.Close(); err == nil {
+			err = closeErr
+		}
+		putEncoder(c.encodings[0], enc)
+		if err != nil {
+			return err
+		}
+		body = buf.Bytes()
+		req.Header.Set("Content-Encoding", c.encodings[0])
+		req.Header.Del("Content-Length")
+	}
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return nil
+}
+
+// decompressResponse replaces the body of resp, if it has a supported
+// Content-Encoding, with its decompressed form.
+func (c *compression) decompressResponse(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if _, ok := codings[encoding]; !ok {
+		return nil
+	}
+	body, err := newDecompressor(encoding, resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = body
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return nil
+}
+
+// encoder is implemented by the writers of all supported content codings.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// coding is a supported content coding.
+type coding struct {
+	encoders  *sync.Pool
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+var codings = map[string]coding{
+	EncodingZstd: {
+		encoders: &sync.Pool{New: func() interface{} {
+			enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return enc
+		}},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		},
+	},
+	EncodingGzip: {
+		encoders: &sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	// HTTP's deflate is the zlib format, not raw DEFLATE (RFC 9110, section
+	// 8.4.1.2).
+	EncodingDeflate: {
+		encoders: &sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	},
+}
+
+func getEncoder(encoding string, w io.Writer) encoder {
+	enc := codings[encoding].encoders.Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func putEncoder(encoding string, enc encoder) {
+	codings[encoding].encoders.Put(enc)
+}
+
+// newDecompressor returns a reader of the decompressed body, which closes
+// body when it's closed. An empty body stays empty.
+func newDecompressor(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	r, err := codings[encoding].newReader(body)
+	if err == io.EOF {
+		return body, nil
+	}
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return decompressor{r, body}, nil
+}
+
+type decompressor struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (d decompressor) Close() error {
+	d.ReadCloser.Close()
+	return d.body.Close()
+}
+
+// compressWriter compresses the response written to it, once it's decided
+// that it should be compressed: when enough of the body has been written,
+// or when it's flushed. Until then, the body is buffered.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compression
+	encoding string // negotiated for the request, "" if none
+	code     int    // written by the handler, 0 if not yet
+	buf      []byte
+	decided  bool
+	enc      encoder // set if the response is being compressed
+}
+
+func (c *compression) newWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: w,
+		c:              c,
+		encoding:       c.negotiate(r.Header.Get("Accept-Encoding")),
+	}
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code) // informational or superfluous
+		return
+	}
+	w.code = code
+	if code == http.StatusNotModified && w.encoding != "" {
+		// The response validated may have been compressed, and so have
+		// had its entity tag weakened.
+		weakenETag(w.Header())
+	}
+	if !w.eligible() {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		if !w.eligible() {
+			w.decide(false)
+		} else {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) < w.c.minSize {
+				return len(p), nil
+			}
+			return len(p), w.decide(true)
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// eligible reports whether the response may be compressed, given its status
+// code and headers. It sets the Vary header of responses whose media type is
+// compressible, since they depend on the Accept-Encoding of the request.
+func (w *compressWriter) eligible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !w.c.compressible(h.Get("Content-Type")) {
+		return false
+	}
+	if !strings.Contains(strings.ToLower(h.Get("Vary")), "accept-encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	switch {
+	case w.encoding == "",
+		w.code == http.StatusNoContent,
+		w.code == http.StatusNotModified,
+		strings.Contains(h.Get("Cache-Control"), "no-transform"):
+		return false
+	}
+	return true
+}
+
+// weakenETag makes the strong entity tag in h, if any, weak. A compressed
+// representation isn't the same bytes as the identity one, so they may only
+// share a weak tag (RFC 9110, section 8.8.1).
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// decide writes the header, compressing the response if compress is true,
+// and then whatever has been buffered.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+		weakenETag(w.Header())
+		w.enc = getEncoder(w.encoding, w.ResponseWriter)
+	}
+	if w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush implements http.Flusher. A response that's flushed before it's been
+// decided is compressed if it's eligible, whatever its size.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		w.decide(w.eligible())
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if fl, ok := w.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. Whatever has been buffered is dropped.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.decided, w.buf = true, nil
+	return hj.Hijack()
+}
+
+// Close writes whatever remains of the response. Responses that are still
+// buffered are smaller than the minimum size, so they're not compressed.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.code == 0 && len(w.buf) == 0 {
+			return nil // nothing was written
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	putEncoder(w.encoding, w.enc)
+	w.enc = nil
+	return err
+}
+
+// reimplementInterfaces returns a wrapped version of w, which implements
+// http.Flusher and http.Hijacker only if the ResponseWriter it wraps does.
+// Other optional interfaces, such as io.ReaderFrom, would bypass the
+// compression, so they aren't implemented.
+func (w *compressWriter) reimplementInterfaces() http.ResponseWriter {
+	var (
+		_, fl = w.ResponseWriter.(http.Flusher)
+		_, hj = w.ResponseWriter.(http.Hijacker)
+	)
+	switch {
+	case fl && hj:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, w, w}
+	case fl:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+		}{w, w}
+	case hj:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{w, w}
+	default:
+		return struct {
+			http.ResponseWriter
+		}{w}
+	}
+}
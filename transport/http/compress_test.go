@@ -0,0 +1,322 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type payload struct {
+	Text string `json:"text"`
+}
+
+func newCompressionServer(options ...httptransport.CompressionOption) *httptest.Server {
+	return httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, p payload) (payload, error) { return p, nil },
+		httptransport.DecodeRequest[payload](httptransport.DefaultCodecs),
+		httptransport.EncodeJSONResponse[payload],
+		httptransport.ServerCompression[payload, payload](options...),
+	))
+}
+
+func TestServerCompression(t *testing.T) {
+	server := newCompressionServer(httptransport.CompressionMinSize(64))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		acceptEncoding string
+		text           string
+		want           string // Content-Encoding
+	}{
+		{"gzip", strings.Repeat("a", 100), "gzip"},
+		{"gzip, deflate", strings.Repeat("a", 100), "gzip"},
+		{"deflate", strings.Repeat("a", 100), "deflate"},
+		{"zstd, gzip", strings.Repeat("a", 100), "zstd"},
+		{"zstd;q=0.5, gzip", strings.Repeat("a", 100), "gzip"},
+		{"*", strings.Repeat("a", 100), "zstd"},
+		{"*, zstd;q=0", strings.Repeat("a", 100), "gzip"},
+		{"gzip", "short", ""},
+		{"br", strings.Repeat("a", 100), ""},
+		{"identity", strings.Repeat("a", 100), ""},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"text":"`+testcase.text+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Encoding", testcase.acceptEncoding)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.want, resp.Header.Get("Content-Encoding"); want != have {
+			t.Errorf("%q: want Content-Encoding %q, have %q", testcase.acceptEncoding, want, have)
+		}
+		if want, have := "Accept-Encoding", resp.Header.Get("Vary"); want != have {
+			t.Errorf("%q: want Vary %q, have %q", testcase.acceptEncoding, want, have)
+		}
+		if testcase.want == "" && !strings.Contains(string(body), testcase.text) {
+			t.Errorf("%q: unexpected body %q", testcase.acceptEncoding, body)
+		}
+	}
+}
+
+func TestServerCompressionETag(t *testing.T) {
+	server := httptest.NewServer(httptransport.NewServer(
+		func(context.Context, struct{}) (document, error) {
+			return document{Body: strings.Repeat("a", 100), Version: "v1"}, nil
+		},
+		func(context.Context, *http.Request) (struct{}, error) { return struct{}{}, nil },
+		httptransport.EncodeJSONResponse[document],
+		httptransport.ServerCompression[struct{}, document](httptransport.CompressionMinSize(64)),
+	))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		acceptEncoding, ifNoneMatch string
+		code                        int
+		etag                        string
+	}{
+		{"gzip", "", http.StatusOK, `W/"v1"`},
+		{"identity", "", http.StatusOK, `"v1"`},
+		{"gzip", `W/"v1"`, http.StatusNotModified, `W/"v1"`},
+		{"identity", `W/"v1"`, http.StatusNotModified, `"v1"`},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Accept-Encoding", testcase.acceptEncoding)
+		if testcase.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", testcase.ifNoneMatch)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%+v: want %d, have %d", testcase, want, have)
+		}
+		if want, have := testcase.etag, resp.Header.Get("ETag"); want != have {
+			t.Errorf("%+v: want ETag %s, have %s", testcase, want, have)
+		}
+	}
+}
+
+func TestServerCompressionTypes(t *testing.T) {
+	server := httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, p payload) (payload, error) { return p, nil },
+		func(context.Context, *http.Request) (payload, error) { return payload{}, nil },
+		func(_ context.Context, w http.ResponseWriter, _ payload) error {
+			w.Header().Set("Content-Type", "image/png")
+			_, err := w.Write(make([]byte, 4096))
+			return err
+		},
+		httptransport.ServerCompression[payload, payload](),
+	))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := "", resp.Header.Get("Content-Encoding"); want != have {
+		t.Errorf("want Content-Encoding %q, have %q", want, have)
+	}
+	if body, _ := ioutil.ReadAll(resp.Body); len(body) != 4096 {
+		t.Errorf("want 4096 bytes, have %d", len(body))
+	}
+}
+
+func TestServerCompressionFlush(t *testing.T) {
+	server := httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, p payload) (payload, error) { return p, nil },
+		func(context.Context, *http.Request) (payload, error) { return payload{}, nil },
+		func(_ context.Context, w http.ResponseWriter, _ payload) error {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: hello\n\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("data: world\n\n"))
+			return nil
+		},
+		httptransport.ServerCompression[payload, payload](),
+		httptransport.ServerFinalizer[payload, payload](func(context.Context, int, *http.Request) {}),
+	))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := "gzip", resp.Header.Get("Content-Encoding"); want != have {
+		t.Fatalf("want Content-Encoding %q, have %q", want, have)
+	}
+	r, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(r)
+	if want, have := "data: hello\n\ndata: world\n\n", string(body); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestServerCompressionRequest(t *testing.T) {
+	server := newCompressionServer()
+	defer server.Close()
+
+	var buf bytes.Buffer
+	enc, _ := zstd.NewWriter(&buf)
+	enc.Write([]byte(`{"text":"hello"}`))
+	enc.Close()
+
+	for _, testcase := range []struct {
+		encoding string
+		body     []byte
+		want     int
+	}{
+		{"zstd", buf.Bytes(), http.StatusOK},
+		{"", []byte(`{"text":"hello"}`), http.StatusOK},
+		{"br", []byte("whatever"), http.StatusUnsupportedMediaType},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(testcase.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", testcase.encoding)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.want, resp.StatusCode; want != have {
+			t.Errorf("%q: want %d, have %d (%s)", testcase.encoding, want, have, body)
+		}
+	}
+}
+
+func TestServerCompressionInflatedSize(t *testing.T) {
+	server := newCompressionServer(httptransport.CompressionMaxInflatedSize(1024))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		text string
+		want int
+	}{
+		{strings.Repeat("a", 1000), http.StatusOK},
+		{strings.Repeat("a", 1<<20), http.StatusRequestEntityTooLarge},
+	} {
+		var buf bytes.Buffer
+		enc := gzip.NewWriter(&buf)
+		enc.Write([]byte(`{"text":"` + testcase.text + `"}`))
+		enc.Close()
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.want, resp.StatusCode; want != have {
+			t.Errorf("%d bytes: want %d, have %d", len(testcase.text), want, have)
+		}
+	}
+}
+
+func TestServerCompressionDeflate(t *testing.T) {
+	server := newCompressionServer(httptransport.CompressionMinSize(64))
+	defer server.Close()
+
+	// Request bodies compressed by the standard library's zlib are read.
+	text := strings.Repeat("hello ", 50)
+	var buf bytes.Buffer
+	enc := zlib.NewWriter(&buf)
+	enc.Write([]byte(`{"text":"` + text + `"}`))
+	enc.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "deflate")
+	req.Header.Set("Accept-Encoding", "deflate")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+	if want, have := "deflate", resp.Header.Get("Content-Encoding"); want != have {
+		t.Fatalf("want Content-Encoding %q, have %q", want, have)
+	}
+
+	// Responses are read by the standard library's zlib.
+	dec, err := zlib.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"text":"`+text+`"}`, strings.TrimSpace(string(body)); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestClientCompression(t *testing.T) {
+	var (
+		contentEncoding string
+		acceptEncoding  string
+	)
+	server := newCompressionServer(httptransport.CompressionMinSize(64))
+	defer server.Close()
+	spy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get("Content-Encoding")
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer spy.Close()
+
+	target, _ := url.Parse(spy.URL)
+	client := httptransport.NewClient(
+		http.MethodPost,
+		target,
+		httptransport.EncodeRequest[payload](httptransport.JSONCodec{}),
+		httptransport.DecodeResponse[payload](httptransport.DefaultCodecs),
+		httptransport.ClientCompression[payload, payload](
+			httptransport.CompressionEncodings(httptransport.EncodingGzip, httptransport.EncodingDeflate),
+			httptransport.CompressionMinSize(64),
+		),
+	).Endpoint()
+
+	text := strings.Repeat("hello ", 50)
+	response, err := client(context.Background(), payload{Text: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := text, response.Text; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "gzip", contentEncoding; want != have {
+		t.Errorf("want Content-Encoding %q, have %q", want, have)
+	}
+	if want, have := "gzip, deflate", acceptEncoding; want != have {
+		t.Errorf("want Accept-Encoding %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,447 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETagger is checked by the server. If a response implements ETagger, its
+// entity tag is set in the ETag header, before the ServerResponseFuncs are
+// applied. A tag that isn't in double quotes, nor weak, is quoted.
+type ETagger interface {
+	ETag() string
+}
+
+// LastModifieder is checked by the server. If a response implements
+// LastModifieder, and its time isn't zero, it's set in the Last-Modified
+// header, before the ServerResponseFuncs are applied.
+type LastModifieder interface {
+	LastModified() time.Time
+}
+
+// ErrPreconditionFailed is returned by servers when a conditional request's
+// precondition, such as If-Match, doesn't hold. It's reported as a 412.
+var ErrPreconditionFailed error = codecError{"precondition failed", http.StatusPreconditionFailed}
+
+// ValidatorsFunc returns the validators of the current state of the resource
+// targeted by a request: its entity tag, and the time it was last modified.
+// Either may be empty. If the resource doesn't exist, both must be.
+type ValidatorsFunc[Request any] func(ctx context.Context, request Request) (etag string, lastModified time.Time, err error)
+
+// ServerPreconditions makes the server evaluate the conditional headers of
+// each request against the validators returned by f, once the request is
+// decoded and before the endpoint is invoked. This is how If-Match and
+// If-Unmodified-Since are enforced for PUT, PATCH and other unsafe methods:
+// the server replies with ErrPreconditionFailed without invoking the
+// endpoint. GET and HEAD requests whose If-None-Match or If-Modified-Since
+// show the client's copy is current are answered with a 304, also without
+// invoking the endpoint. Errors returned by f are encoded with the
+// ErrorEncoder.
+//
+// Without this option, the server still answers GET and HEAD requests with a
+// 304 after invoking the endpoint, if the ETag or Last-Modified header of the
+// response, as set from ETagger and LastModifieder or by a
+// ServerResponseFunc, shows the client's copy is current, or with
+// ErrPreconditionFailed if If-Match doesn't hold. The response isn't encoded
+// then.
+func ServerPreconditions[Request, Response any](f ValidatorsFunc[Request]) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.preconditions = f }
+}
+
+// checkPreconditions evaluates the conditional headers of r with the
+// ServerPreconditions of the server, writing the response if the endpoint
+// shouldn't be invoked. It reports whether the response was written.
+func (s Server[Request, Response]) checkPreconditions(ctx context.Context, w http.ResponseWriter, r *http.Request, request Request) bool {
+	if s.preconditions == nil || !hasPreconditions(r) {
+		return false
+	}
+	etag, lastModified, err := s.preconditions(ctx, request)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.errorEncoder(ctx, err, w)
+		return true
+	}
+	switch evaluatePreconditions(r, formatETag(etag), lastModified) {
+	case http.StatusNotModified:
+		setValidators(w.Header(), formatETag(etag), lastModified)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	case http.StatusPreconditionFailed:
+		s.errorHandler.Handle(ctx, ErrPreconditionFailed)
+		s.errorEncoder(ctx, ErrPreconditionFailed, w)
+		return true
+	}
+	return false
+}
+
+// checkValidators evaluates the conditional headers of a GET or HEAD
+// request against the validators in the header of w, once the endpoint has
+// been invoked, writing a 304, or ErrPreconditionFailed, instead of the
+// response if it shouldn't be encoded. It reports whether the response was
+// written.
+func (s Server[Request, Response]) checkValidators(ctx context.Context, w http.ResponseWriter, r *http.Request, response Response) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || !hasPreconditions(r) {
+		return false
+	}
+	if sc, ok := interface{}(response).(StatusCoder); ok && (sc.StatusCode() < 200 || sc.StatusCode() > 299) {
+		return false
+	}
+	etag := w.Header().Get("ETag")
+	lastModified, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+	if etag == "" && lastModified.IsZero() {
+		return false
+	}
+	switch evaluatePreconditions(r, etag, lastModified) {
+	case http.StatusNotModified:
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	case http.StatusPreconditionFailed:
+		s.errorHandler.Handle(ctx, ErrPreconditionFailed)
+		s.errorEncoder(ctx, ErrPreconditionFailed, w)
+		return true
+	}
+	return false
+}
+
+// responseValidators returns the validators of response, from its ETagger
+// and LastModifieder interfaces.
+func responseValidators(response interface{}) (etag string, lastModified time.Time) {
+	if e, ok := response.(ETagger); ok {
+		etag = formatETag(e.ETag())
+	}
+	if lm, ok := response.(LastModifieder); ok {
+		lastModified = lm.LastModified()
+	}
+	return etag, lastModified
+}
+
+func setValidators(h http.Header, etag string, lastModified time.Time) {
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// formatETag quotes a strong entity tag that isn't quoted already.
+func formatETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return strconv.Quote(etag)
+}
+
+func hasPreconditions(r *http.Request) bool {
+	for _, h := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePreconditions evaluates the conditional headers of r against the
+// validators of the current state of the resource, as specified by RFC 9110,
+// section 13.2.2. It returns http.StatusNotModified or
+// http.StatusPreconditionFailed if the request shouldn't be served, and 0
+// otherwise.
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	var (
+		exists = etag != "" || !lastModified.IsZero()
+		safe   = r.Method == http.MethodGet || r.Method == http.MethodHead
+	)
+	lastModified = lastModified.Truncate(time.Second) // as precise as HTTP dates
+
+	if im := strings.Join(r.Header.Values("If-Match"), ","); im != "" {
+		if !matchETag(im, etag, exists, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := strings.Join(r.Header.Values("If-None-Match"), ","); inm != "" {
+		if matchETag(inm, etag, exists, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag reports whether an If-Match or If-None-Match header, a list of
+// entity tags or "*", matches etag, with the strong comparison if strong is
+// true, or else the weak one.
+func matchETag(header, etag string, exists, strong bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return exists
+	}
+	if etag == "" || strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for header != "" {
+		var tag string
+		tag, header = scanETag(header)
+		if tag == "" {
+			return false // malformed
+		}
+		if strong && strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(tag, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// scanETag returns the entity tag at the start of s, and what follows it
+// after a comma. The tag is empty if s doesn't start with one.
+func scanETag(s string) (tag, rest string) {
+	s = strings.TrimLeft(s, " \t,")
+	start := s
+	s = strings.TrimPrefix(s, "W/")
+	if len(s) < 2 || s[0] != '"' {
+		return "", ""
+	}
+	end := strings.IndexByte(s[1:], '"')
+	if end < 0 {
+		return "", ""
+	}
+	n := len(start) - len(s) + end + 2
+	return start[:n], strings.TrimLeft(start[n:], " \t,")
+}
+
+// ResponseCache stores responses for clients with the ClientCache option.
+// Implementations must be safe for concurrent use. Errors returned by a
+// ResponseCache are treated as cache misses.
+type ResponseCache interface {
+	Get(ctx context.Context, key string) (response CachedResponse, ok bool, err error)
+	Set(ctx context.Context, key string, response CachedResponse) error
+}
+
+// CachedResponse is a response stored in a ResponseCache.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Stored     time.Time
+}
+
+// ClientCache makes the client keep the responses to GET requests in cache,
+// under their URL and the values of the request headers named by their Vary
+// header, if they have an ETag or Last-Modified header, or a Cache-Control
+// max-age. While a cached response is fresh, according to its max-age, it's
+// returned without making a request. Afterwards, the request is made
+// conditional on the cached response being current, with If-None-Match and
+// If-Modified-Since, and the cached response is returned if the server
+// replies with a 304. Responses with Cache-Control no-store or private, or
+// Vary *, aren't cached, and ones with no-cache are always revalidated.
+// Requests with credentials bypass the cache, so that a cache shared by
+// several clients doesn't serve one user's responses to another. Those are
+// the requests with an Authorization or Cookie header, and all requests if
+// the client set with SetClient is an *http.Client with a cookie Jar.
+//
+// When responses vary, the cache also holds, under the URL alone, an entry
+// with no StatusCode and only the Vary header, to find the variant matching a
+// request.
+func ClientCache[Request, Response any](cache ResponseCache) ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.cache = cache }
+}
+
+// credentialed reports whether req carries credentials, or may have them
+// added by the cookie jar of the client.
+func (c Client[Request, Response]) credentialed(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return true
+	}
+	client, ok := c.client.(*http.Client)
+	return ok && client.Jar != nil
+}
+
+// do sends req, using the ClientCache of the client, if any.
+func (c Client[Request, Response]) do(req *http.Request) (*http.Response, error) {
+	if c.cache == nil || req.Method != http.MethodGet || c.credentialed(req) {
+		return c.roundTrip(req)
+	}
+
+	var (
+		ctx  = req.Context()
+		base = req.URL.String()
+		key  = base
+		now  = time.Now()
+	)
+	cached, ok := c.cacheGet(ctx, key)
+	if vary := cached.Header.Get("Vary"); ok && vary != "" {
+		if key = cacheKey(req, vary); key != base {
+			cached, ok = c.cacheGet(ctx, key)
+		}
+	}
+	if ok && cached.StatusCode == 0 {
+		ok = false
+	}
+	if ok {
+		if _, noCache := directives(req.Header.Get("Cache-Control"))["no-cache"]; !noCache && fresh(cached, now) {
+			return cached.response(req), nil
+		}
+		req = req.Clone(ctx)
+		if etag := cached.Header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := cached.Header.Get("Last-Modified"); lm != "" && req.Header.Get("If-Modified-Since") == "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// The cache may hand the same header to concurrent callers.
+		header := cached.Header.Clone()
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		cached.Header, cached.Stored = header, now
+		c.cache.Set(ctx, key, cached)
+		return cached.response(req), nil
+	}
+
+	if !cacheable(resp) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	vary := strings.Join(resp.Header.Values("Vary"), ",")
+	if key = cacheKey(req, vary); key != base {
+		c.cache.Set(ctx, base, CachedResponse{Header: http.Header{"Vary": {vary}}, Stored: now})
+	}
+	c.cache.Set(ctx, key, CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Stored:     now,
+	})
+	return resp, nil
+}
+
+func (c Client[Request, Response]) cacheGet(ctx context.Context, key string) (CachedResponse, bool) {
+	cached, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return CachedResponse{}, false
+	}
+	return cached, ok
+}
+
+// cacheKey returns the key of the response to req in the ClientCache: its
+// URL, followed by the values of the request headers named by vary.
+func cacheKey(req *http.Request, vary string) string {
+	var b strings.Builder
+	b.WriteString(req.URL.String())
+	for _, name := range strings.Split(vary, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			b.WriteString("\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(req.Header.Values(name), ","))
+		}
+	}
+	return b.String()
+}
+
+// roundTrip sends req with the HTTPClient of the client, and decompresses the
+// response if the client has the ClientCompression option.
+func (c Client[Request, Response]) roundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.compression != nil {
+		if err := c.compression.decompressResponse(resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// response returns a copy of the cached response, as a response to req.
+func (cr CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(cr.StatusCode) + " " + http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
+
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	cc := directives(resp.Header.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["private"]; ok {
+		return false
+	}
+	for _, vary := range resp.Header.Values("Vary") {
+		if strings.Contains(vary, "*") {
+			return false
+		}
+	}
+	_, maxAge := cc["max-age"]
+	return maxAge || resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// fresh reports whether the cached response may be returned without being
+// revalidated.
+func fresh(cr CachedResponse, now time.Time) bool {
+	cc := directives(cr.Header.Get("Cache-Control"))
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+	maxAge, err := strconv.Atoi(cc["max-age"])
+	if err != nil {
+		return false
+	}
+	return now.Before(cr.Stored.Add(time.Duration(maxAge) * time.Second))
+}
+
+// directives parses a Cache-Control header into its directives, which map to
+// their argument, if any.
+func directives(cacheControl string) map[string]string {
+	d := map[string]string{}
+	for _, part := range strings.Split(cacheControl, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			d[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return d
+}
@@ -0,0 +1,354 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+var modified = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type document struct {
+	Body    string `json:"body"`
+	Version string `json:"-"`
+}
+
+func (d document) ETag() string            { return d.Version }
+func (d document) LastModified() time.Time { return modified }
+
+func TestServerConditionalGet(t *testing.T) {
+	var calls int
+	handler := httptransport.NewServer(
+		func(context.Context, struct{}) (document, error) {
+			calls++
+			return document{Body: "hello", Version: "v1"}, nil
+		},
+		func(context.Context, *http.Request) (struct{}, error) { return struct{}{}, nil },
+		httptransport.EncodeJSONResponse[document],
+	)
+
+	for _, testcase := range []struct {
+		header, value string
+		want          int
+	}{
+		{"", "", http.StatusOK},
+		{"If-None-Match", `"v1"`, http.StatusNotModified},
+		{"If-None-Match", `W/"v1"`, http.StatusNotModified},
+		{"If-None-Match", `"v0", "v1"`, http.StatusNotModified},
+		{"If-None-Match", `*`, http.StatusNotModified},
+		{"If-None-Match", `"v0"`, http.StatusOK},
+		{"If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"If-Match", `"v1"`, http.StatusOK},
+		{"If-Match", `"v0"`, http.StatusPreconditionFailed},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if testcase.header != "" {
+			req.Header.Set(testcase.header, testcase.value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if want, have := testcase.want, rec.Code; want != have {
+			t.Errorf("%s: %s: want %d, have %d", testcase.header, testcase.value, want, have)
+		}
+		if want, have := `"v1"`, rec.Header().Get("ETag"); want != have {
+			t.Errorf("%s: %s: want ETag %s, have %s", testcase.header, testcase.value, want, have)
+		}
+		if want, have := modified.Format(http.TimeFormat), rec.Header().Get("Last-Modified"); want != have {
+			t.Errorf("%s: %s: want Last-Modified %s, have %s", testcase.header, testcase.value, want, have)
+		}
+		if testcase.want == http.StatusNotModified && rec.Body.Len() > 0 {
+			t.Errorf("%s: %s: want no body, have %q", testcase.header, testcase.value, rec.Body)
+		}
+	}
+	if want, have := 10, calls; want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestServerPreconditions(t *testing.T) {
+	var (
+		version = "v1"
+		calls   int
+	)
+	handler := httptransport.NewServer(
+		func(_ context.Context, body string) (document, error) {
+			calls++
+			version = "v2"
+			return document{Body: body, Version: version}, nil
+		},
+		func(context.Context, *http.Request) (string, error) { return "hello", nil },
+		httptransport.EncodeJSONResponse[document],
+		httptransport.ServerPreconditions[string, document](func(context.Context, string) (string, time.Time, error) {
+			return version, modified, nil
+		}),
+	)
+
+	for _, testcase := range []struct {
+		method, header, value string
+		want                  int
+	}{
+		{http.MethodPut, "If-Match", `"v0"`, http.StatusPreconditionFailed},
+		{http.MethodPatch, "If-Match", `W/"v1"`, http.StatusPreconditionFailed},
+		{http.MethodPut, "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{http.MethodPut, "If-None-Match", "*", http.StatusPreconditionFailed},
+		{http.MethodGet, "If-None-Match", `"v1"`, http.StatusNotModified},
+		{http.MethodPatch, "If-Match", `"v1"`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(testcase.method, "/", strings.NewReader("hello"))
+		req.Header.Set(testcase.header, testcase.value)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if want, have := testcase.want, rec.Code; want != have {
+			t.Errorf("%s %s: %s: want %d, have %d", testcase.method, testcase.header, testcase.value, want, have)
+		}
+	}
+	if want, have := 1, calls; want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+	if want, have := "v2", version; want != have {
+		t.Errorf("want version %s, have %s", want, have)
+	}
+}
+
+// memoryCache is a ResponseCache in a map.
+type memoryCache struct {
+	mtx       sync.Mutex
+	responses map[string]httptransport.CachedResponse
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) (httptransport.CachedResponse, bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	response, ok := c.responses[key]
+	return response, ok, nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, response httptransport.CachedResponse) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.responses[key] = response
+	return nil
+}
+
+func TestClientCache(t *testing.T) {
+	var (
+		requests     []string
+		cacheControl string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"body":"hello"}`))
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient(
+		http.MethodGet,
+		target,
+		func(context.Context, *http.Request, struct{}) error { return nil },
+		httptransport.DecodeResponse[document](httptransport.DefaultCodecs),
+		httptransport.ClientCache[struct{}, document](&memoryCache{responses: map[string]httptransport.CachedResponse{}}),
+	).Endpoint()
+
+	for _, testcase := range []struct {
+		cacheControl string
+		want         []string // If-None-Match of the requests made so far
+	}{
+		{"no-cache", []string{""}},
+		{"no-cache", []string{"", `"v1"`}},
+		{"max-age=60", []string{"", `"v1"`, `"v1"`}},
+		{"no-cache", []string{"", `"v1"`, `"v1"`}}, // fresh, until max-age
+	} {
+		cacheControl = testcase.cacheControl
+		response, err := client(context.Background(), struct{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "hello", response.Body; want != have {
+			t.Errorf("want %q, have %q", want, have)
+		}
+		if want, have := strings.Join(testcase.want, "|"), strings.Join(requests, "|"); want != have {
+			t.Errorf("want requests %s, have %s", want, have)
+		}
+	}
+}
+
+func TestClientCacheVary(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		w.Header().Set("Vary", "Accept-Language")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"body":"` + r.Header.Get("Accept-Language") + r.Header.Get("Authorization") + r.Header.Get("Cookie") + `"}`))
+	}))
+	defer server.Close()
+
+	type request struct{ cc, lang, auth, cookie string }
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient(
+		http.MethodGet,
+		target,
+		func(_ context.Context, r *http.Request, request request) error {
+			r.URL.RawQuery = url.Values{"cc": {request.cc}}.Encode()
+			r.Header.Set("Accept-Language", request.lang)
+			if request.auth != "" {
+				r.Header.Set("Authorization", request.auth)
+			}
+			if request.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "session", Value: request.cookie})
+			}
+			return nil
+		},
+		httptransport.DecodeResponse[document](httptransport.DefaultCodecs),
+		httptransport.ClientCache[request, document](&memoryCache{responses: map[string]httptransport.CachedResponse{}}),
+	).Endpoint()
+
+	for _, testcase := range []struct {
+		request  request
+		want     string
+		requests int
+	}{
+		{request{"max-age=60", "en", "", ""}, "en", 1},
+		{request{"max-age=60", "fr", "", ""}, "fr", 2},
+		{request{"max-age=60", "en", "", ""}, "en", 2},
+		{request{"max-age=60", "fr", "", ""}, "fr", 2},
+		{request{"max-age=60", "en", "alice", ""}, "enalice", 3},
+		{request{"max-age=60", "en", "bob", ""}, "enbob", 4},
+		{request{"max-age=60", "en", "", "carol"}, "ensession=carol", 5},
+		{request{"max-age=60", "en", "", "dave"}, "ensession=dave", 6},
+		{request{"max-age=60, private", "de", "", ""}, "de", 7},
+		{request{"max-age=60, private", "de", "", ""}, "de", 8},
+	} {
+		response, err := client(context.Background(), testcase.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := testcase.want, response.Body; want != have {
+			t.Errorf("%+v: want %q, have %q", testcase.request, want, have)
+		}
+		if want, have := testcase.requests, requests; want != have {
+			t.Errorf("%+v: want %d requests, have %d", testcase.request, want, have)
+		}
+	}
+}
+
+func TestClientCacheCookieJar(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"body":"hello"}`))
+	}))
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient(
+		http.MethodGet,
+		target,
+		func(context.Context, *http.Request, struct{}) error { return nil },
+		httptransport.DecodeResponse[document](httptransport.DefaultCodecs),
+		httptransport.SetClient[struct{}, document](&http.Client{Jar: jar}),
+		httptransport.ClientCache[struct{}, document](&memoryCache{responses: map[string]httptransport.CachedResponse{}}),
+	).Endpoint()
+	for i := 0; i < 2; i++ {
+		if _, err := client(context.Background(), struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 2, requests; want != have {
+		t.Errorf("want a client with a cookie jar to bypass the cache: want %d requests, have %d", want, have)
+	}
+}
+
+func TestClientCacheConcurrentRevalidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Date", time.Now().Format(http.TimeFormat))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"body":"hello"}`))
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient(
+		http.MethodGet,
+		target,
+		func(context.Context, *http.Request, struct{}) error { return nil },
+		httptransport.DecodeResponse[document](httptransport.DefaultCodecs),
+		httptransport.ClientCache[struct{}, document](&memoryCache{responses: map[string]httptransport.CachedResponse{}}),
+	).Endpoint()
+	if _, err := client(context.Background(), struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				response, err := client(context.Background(), struct{}{})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if want, have := "hello", response.Body; want != have {
+					t.Errorf("want %q, have %q", want, have)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientCacheKeepsRequestHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"body":"hello"}`))
+	}))
+	defer server.Close()
+
+	var header http.Header
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient(
+		http.MethodGet,
+		target,
+		func(_ context.Context, r *http.Request, _ struct{}) error { header = r.Header; return nil },
+		httptransport.DecodeResponse[document](httptransport.DefaultCodecs),
+		httptransport.ClientCache[struct{}, document](&memoryCache{responses: map[string]httptransport.CachedResponse{}}),
+	).Endpoint()
+	for i := 0; i < 2; i++ {
+		if _, err := client(context.Background(), struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+		if have := header.Get("If-None-Match"); have != "" {
+			t.Errorf("want no If-None-Match on the caller's request, have %s", have)
+		}
+	}
+}
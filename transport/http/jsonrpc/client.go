@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 	httptransport "github.com/openmesh/kit/transport/http"
 )
 
@@ -68,10 +69,11 @@ func DefaultRequestEncoder[Req any](_ context.Context, req Req) (json.RawMessage
 }
 
 // DefaultResponseDecoder unmarshals the result to interface{}, or returns an
-// error, if found.
+// error, if found. If the error carries an *errcode.Error as its data, as
+// encoded by DefaultErrorEncoder, that Error is returned.
 func DefaultResponseDecoder[Res any](_ context.Context, res Response) (Res, error) {
 	if res.Error != nil {
-		return *new(Res), *res.Error
+		return *new(Res), decodeError(*res.Error)
 	}
 	var result Res
 	err := json.Unmarshal(res.Result, &result)
@@ -81,6 +83,22 @@ func DefaultResponseDecoder[Res any](_ context.Context, res Response) (Res, erro
 	return result, nil
 }
 
+// decodeError returns the *errcode.Error in the data of e, or else e.
+func decodeError(e Error) error {
+	if e.Data == nil {
+		return e
+	}
+	b, err := json.Marshal(e.Data)
+	if err != nil {
+		return e
+	}
+	ec := &errcode.Error{}
+	if err := json.Unmarshal(b, ec); err != nil {
+		return e
+	}
+	return ec
+}
+
 // ClientOption sets an optional parameter for clients.
 type ClientOption[Req, Res any] func(*Client[Req, Res])
 
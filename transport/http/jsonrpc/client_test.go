@@ -3,13 +3,16 @@ package jsonrpc_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"testing"
 
+	"github.com/openmesh/kit/errcode"
 	"github.com/openmesh/kit/transport/http/jsonrpc"
 )
 
@@ -367,3 +370,33 @@ func mustParse(s string) *url.URL {
 	}
 	return u
 }
+
+func TestClientDecodesErrcodeError(t *testing.T) {
+	t.Parallel()
+
+	want := errcode.New(errcode.NotFound, "no such user").WithDetail("id", "42")
+	server := httptest.NewServer(jsonrpc.NewServer(jsonrpc.EndpointCodecMap{
+		"get": jsonrpc.EndpointCodec[interface{}, interface{}]{
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				return nil, fmt.Errorf("getting user: %w", want)
+			},
+			Decode: func(context.Context, json.RawMessage) (interface{}, error) { return struct{}{}, nil },
+			Encode: func(context.Context, interface{}) (json.RawMessage, error) { return []byte("{}"), nil },
+		},
+	}))
+	defer server.Close()
+
+	sut := jsonrpc.NewClient[interface{}, interface{}](mustParse(server.URL), "get")
+	_, err := sut.Endpoint()(context.Background(), struct{}{})
+
+	have, ok := err.(*errcode.Error)
+	if !ok {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+	if want, have := -32005, have.ErrorCode(); want != have {
+		t.Errorf("want code %d, have %d", want, have)
+	}
+}
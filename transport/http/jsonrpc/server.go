@@ -3,11 +3,13 @@ package jsonrpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/go-kit/log"
+	"github.com/openmesh/kit/errcode"
 	httptransport "github.com/openmesh/kit/transport/http"
 )
 
@@ -180,7 +182,14 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // If the error implements ErrorCoder, the provided code will be set on the
 // response error.
 // If the error implements Headerer, the given headers will be set.
+// If the error wraps an *errcode.Error, that Error is encoded instead of the
+// wrapper, and is also set as the data of the response error.
 func DefaultErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	var ec *errcode.Error
+	if errors.As(err, &ec) {
+		err = ec
+	}
+
 	w.Header().Set("Content-Type", ContentType)
 	if headerer, ok := err.(httptransport.Headerer); ok {
 		for k := range headerer.Headers() {
@@ -195,6 +204,9 @@ func DefaultErrorEncoder(ctx context.Context, err error, w http.ResponseWriter)
 	if sc, ok := err.(ErrorCoder); ok {
 		e.Code = sc.ErrorCode()
 	}
+	if ec != nil {
+		e.Data = ec
+	}
 
 	w.WriteHeader(http.StatusOK)
 
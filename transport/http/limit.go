@@ -0,0 +1,147 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+var (
+	// ErrBodyTooLarge is returned by servers with the ServerMaxBodySize
+	// option when the request body is larger than allowed. It's reported as
+	// a 413.
+	ErrBodyTooLarge error = codecError{"request body too large", http.StatusRequestEntityTooLarge}
+
+	// ErrRequestTimeout is returned by servers with the ServerReadTimeout
+	// option when the request body isn't read in time. It's reported as a
+	// 408.
+	ErrRequestTimeout error = codecError{"request body read timed out", http.StatusRequestTimeout}
+)
+
+// ServerMaxBodySize limits the size of request bodies to n bytes. Requests
+// with a larger Content-Length are rejected before being decoded, and reading
+// past the limit fails, so that the DecodeRequestFunc returns an error, which
+// the server replaces with ErrBodyTooLarge. With ServerCompression, the limit
+// applies to the decompressed body. By default, there's no limit.
+func ServerMaxBodySize[Request, Response any](n int64) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.maxBodySize = n }
+}
+
+// ServerReadTimeout sets the time allowed to read the request body, from the
+// moment the server starts handling the request until the DecodeRequestFunc
+// returns. A read after that fails, so that the DecodeRequestFunc returns an
+// error, which the server replaces with ErrRequestTimeout. Unlike
+// http.Server's ReadTimeout, it applies per handler, and doesn't limit the
+// time spent in the endpoint. It requires a ResponseWriter that supports
+// http.ResponseController's SetReadDeadline, and has no effect otherwise. By
+// default, there's no timeout.
+func ServerReadTimeout[Request, Response any](d time.Duration) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.readTimeout = d }
+}
+
+// ServerStrictJSON makes the server reject JSON request bodies that have
+// fields Body has no field for, or anything after the top-level value, with
+// an errcode.InvalidArgument error, before the DecodeRequestFunc is called.
+// Body is the type the DecodeRequestFunc unmarshals the body into, which is
+// often Request itself, but may be a wire type that differs from it, e.g.
+// without the fields bound from the path, or with an envelope around it.
+// The body is buffered to be checked, and then handed to the
+// DecodeRequestFunc as it was, so existing decoders such as one calling
+// json.NewDecoder are unaffected. Bodies larger than the ServerMaxBodySize,
+// or than 10 MiB without it, are rejected with ErrBodyTooLarge. Bodies with
+// another Content-Type, and empty bodies, aren't checked.
+func ServerStrictJSON[Request, Response, Body any]() ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) {
+		s.strictJSON = func() interface{} { _, target := newTarget[Body](); return target }
+	}
+}
+
+// limitRequest applies the body limits of the server to r. It's given the
+// ResponseWriter of the http.Server, rather than a wrapped one, for
+// http.ResponseController and http.MaxBytesReader.
+func (s Server[Request, Response]) limitRequest(w http.ResponseWriter, r *http.Request) error {
+	if s.readTimeout > 0 {
+		http.NewResponseController(w).SetReadDeadline(time.Now().Add(s.readTimeout))
+	}
+	if s.maxBodySize > 0 {
+		if r.ContentLength > s.maxBodySize {
+			return ErrBodyTooLarge
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodySize)
+	}
+	if s.strictJSON != nil && r.Body != nil && isJSON(r.Header.Get("Content-Type")) {
+		limit := s.maxBodySize
+		if limit <= 0 {
+			limit = maxStrictJSONSize
+		}
+		if err := checkStrictJSON(r, s.strictJSON(), limit); err != nil {
+			return s.bodyError(err)
+		}
+	}
+	return nil
+}
+
+// endRead lifts the read deadline set by limitRequest once the request is
+// decoded, so that it doesn't interrupt the connection while the endpoint
+// runs. It's kept after a failed decode, so that the http.Server doesn't
+// block draining the rest of a stalled body.
+func (s Server[Request, Response]) endRead(w http.ResponseWriter) {
+	if s.readTimeout > 0 {
+		http.NewResponseController(w).SetReadDeadline(time.Time{})
+	}
+}
+
+// bodyError replaces errors caused by the body limits of the server, or by
+// the CompressionMaxInflatedSize, with ErrBodyTooLarge or ErrRequestTimeout.
+func (s Server[Request, Response]) bodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return ErrBodyTooLarge
+	case s.readTimeout > 0 && errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrRequestTimeout
+	}
+	return err
+}
+
+func isJSON(contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// maxStrictJSONSize is the size of the largest body buffered by
+// ServerStrictJSON without ServerMaxBodySize.
+const maxStrictJSONSize = 10 << 20
+
+// checkStrictJSON reads the body of r, up to limit bytes, checks that it's a
+// single JSON value with no unknown fields for target, and puts it back.
+func checkStrictJSON(r *http.Request, target interface{}, limit int64) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader(b), r.Body}
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	if int64(len(b)) > limit {
+		return ErrBodyTooLarge
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "decoding request: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errcode.New(errcode.InvalidArgument, "decoding request: unexpected data after top-level value")
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type strictRequest struct {
+	Name string `json:"name"`
+}
+
+func newLimitServer(options ...httptransport.ServerOption[strictRequest, strictRequest]) *httptest.Server {
+	return httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, r strictRequest) (strictRequest, error) { return r, nil },
+		func(_ context.Context, r *http.Request) (strictRequest, error) {
+			var request strictRequest
+			err := json.NewDecoder(r.Body).Decode(&request)
+			return request, err
+		},
+		httptransport.EncodeJSONResponse[strictRequest],
+		options...,
+	))
+}
+
+func TestServerMaxBodySize(t *testing.T) {
+	server := newLimitServer(httptransport.ServerMaxBodySize[strictRequest, strictRequest](32))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		name    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"small", `{"name":"kit"}`, false, http.StatusOK},
+		{"large", `{"name":"` + strings.Repeat("a", 64) + `"}`, false, http.StatusRequestEntityTooLarge},
+		{"large chunked", `{"name":"` + strings.Repeat("a", 64) + `"}`, true, http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(testcase.body))
+			req.Header.Set("Content-Type", "application/json")
+			if testcase.chunked {
+				req.ContentLength = -1
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if want, have := testcase.want, resp.StatusCode; want != have {
+				t.Errorf("want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestServerReadTimeout(t *testing.T) {
+	server := newLimitServer(httptransport.ServerReadTimeout[strictRequest, strictRequest](50 * time.Millisecond))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Send only part of the body, and stall.
+	fmt.Fprint(conn, "POST / HTTP/1.1\r\nHost: kit\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"name\":")
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := http.StatusRequestTimeout, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestServerReadTimeoutSlowEndpoint(t *testing.T) {
+	server := httptest.NewServer(httptransport.NewServer(
+		func(ctx context.Context, r strictRequest) (strictRequest, error) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				return r, nil
+			case <-ctx.Done():
+				return r, ctx.Err()
+			}
+		},
+		func(_ context.Context, r *http.Request) (strictRequest, error) {
+			var request strictRequest
+			err := json.NewDecoder(r.Body).Decode(&request)
+			return request, err
+		},
+		httptransport.EncodeJSONResponse[strictRequest],
+		httptransport.ServerReadTimeout[strictRequest, strictRequest](20*time.Millisecond),
+	))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"name":"kit"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestServerStrictJSON(t *testing.T) {
+	server := newLimitServer(httptransport.ServerStrictJSON[strictRequest, strictRequest, strictRequest]())
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		contentType string
+		body        string
+		want        int
+		wantName    string
+	}{
+		{"application/json", `{"name":"kit"}`, http.StatusOK, "kit"},
+		{"application/json", ` {"name":"kit"} ` + "\n", http.StatusOK, "kit"},
+		{"application/json; charset=utf-8", `{"name":"kit","admin":true}`, http.StatusBadRequest, ""},
+		{"application/merge-patch+json", `{"admin":true}`, http.StatusBadRequest, ""},
+		{"application/json", `{"name":"kit"}{"name":"go"}`, http.StatusBadRequest, ""},
+		{"application/json", `{"name":"kit"} garbage`, http.StatusBadRequest, ""},
+		{"text/plain", `{"name":"kit","admin":true}`, http.StatusOK, "kit"},
+	} {
+		resp, err := http.Post(server.URL, testcase.contentType, strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.want, resp.StatusCode; want != have {
+			t.Errorf("%s: want %d, have %d (%s)", testcase.body, want, have, body)
+			continue
+		}
+		if testcase.want != http.StatusOK {
+			continue
+		}
+		var response strictRequest
+		json.Unmarshal(body, &response)
+		if want, have := testcase.wantName, response.Name; want != have {
+			t.Errorf("%s: want %q, have %q", testcase.body, want, have)
+		}
+	}
+}
+
+func TestServerStrictJSONDefaultLimit(t *testing.T) {
+	server := newLimitServer(httptransport.ServerStrictJSON[strictRequest, strictRequest, strictRequest]())
+	defer server.Close()
+
+	body := `{"name":"` + strings.Repeat("a", 10<<20) + `"}`
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusRequestEntityTooLarge, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestServerStrictJSONWireType(t *testing.T) {
+	// The request has a field bound from the query, and the body is an
+	// envelope around the rest.
+	type renameRequest struct {
+		ID   string
+		Name string
+	}
+	type renameBody struct {
+		Data struct {
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	server := httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, r renameRequest) (renameRequest, error) { return r, nil },
+		func(_ context.Context, r *http.Request) (renameRequest, error) {
+			var body renameBody
+			err := json.NewDecoder(r.Body).Decode(&body)
+			return renameRequest{ID: r.URL.Query().Get("id"), Name: body.Data.Name}, err
+		},
+		httptransport.EncodeJSONResponse[renameRequest],
+		httptransport.ServerStrictJSON[renameRequest, renameRequest, renameBody](),
+	))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		body string
+		want int
+	}{
+		{`{"data":{"name":"kit"}}`, http.StatusOK},
+		{`{"data":{"name":"kit","admin":true}}`, http.StatusBadRequest},
+		{`{"Name":"kit"}`, http.StatusBadRequest},
+	} {
+		resp, err := http.Post(server.URL+"?id=42", "application/json", strings.NewReader(testcase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.want, resp.StatusCode; want != have {
+			t.Errorf("%s: want %d, have %d (%s)", testcase.body, want, have, body)
+		}
+	}
+}
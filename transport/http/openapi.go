@@ -0,0 +1,482 @@
+package http
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation documents a Server in the OpenAPI document of the Router it's
+// mounted on. The parameters, request body and response are documented from
+// the Request and Response types of the Server either way.
+type Operation struct {
+	ID          string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+
+	// Errors are the error responses of the operation by status code, each
+	// with a value of the type of its body, e.g. &errcode.Error{}. Bodies
+	// that are a *Problem are documented as problem details.
+	Errors map[int]interface{}
+}
+
+// ServerOperation sets the Operation documenting the server in OpenAPI
+// documents, see Router.OpenAPI.
+func ServerOperation[Request, Response any](op Operation) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.operation = op }
+}
+
+// Schemaer is checked when generating OpenAPI documents. If a type
+// implements Schemaer, with a value or a pointer receiver, the JSON Schema
+// it returns is used instead of one derived from its fields. It's meant for
+// types with a custom JSON encoding.
+type Schemaer interface {
+	OpenAPISchema() map[string]interface{}
+}
+
+// OpenAPISchema implements Schemaer.
+func (p *Problem) OpenAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"type":     map[string]interface{}{"type": "string", "format": "uri-reference"},
+			"title":    map[string]interface{}{"type": "string"},
+			"status":   map[string]interface{}{"type": "integer"},
+			"detail":   map[string]interface{}{"type": "string"},
+			"instance": map[string]interface{}{"type": "string", "format": "uri-reference"},
+		},
+	}
+}
+
+// serverDescription is what OpenAPI documents tell about a Server.
+type serverDescription struct {
+	request   reflect.Type
+	response  reflect.Type
+	operation Operation
+	codecs    *Codecs
+}
+
+// describer is implemented by Servers, for Routers to document them.
+type describer interface {
+	describe() serverDescription
+}
+
+func (s Server[Request, Response]) describe() serverDescription {
+	return serverDescription{
+		request:   reflect.TypeOf((*Request)(nil)).Elem(),
+		response:  reflect.TypeOf((*Response)(nil)).Elem(),
+		operation: s.operation,
+		codecs:    s.codecs,
+	}
+}
+
+// OpenAPI returns an OpenAPI 3.1 document describing the Servers registered
+// with the router for a method, as JSON-encodable values. Other handlers,
+// including Servers wrapped by other handlers, aren't documented.
+//
+// Each Server is an operation. The fields of its Request type with path,
+// query or header tags, as used by Bind, are its parameters, and the other
+// fields make up its request body, except for GET and HEAD requests. If
+// Request isn't a struct, it's the request body. The Response type is the
+// body of the 200 response, and the error responses are documented from the
+// Operation of the Server, if any. Bodies are documented as JSON, or in the
+// media types of the ServerCodecs of the Server. Named struct types are
+// documented as schema components.
+func (r *Router) OpenAPI(info OpenAPIInfo) map[string]interface{} {
+	r.mtx.Lock()
+	routes := append([]route(nil), r.routes...)
+	r.mtx.Unlock()
+
+	var (
+		g     = &schemaGenerator{names: map[reflect.Type]string{}, schemas: map[string]interface{}{}}
+		paths = map[string]interface{}{}
+	)
+	for _, rt := range routes {
+		path, wildcards := openAPIPath(rt.pattern)
+		ops, _ := paths[path].(map[string]interface{})
+		if ops == nil {
+			ops = map[string]interface{}{}
+			paths[path] = ops
+		}
+		ops[strings.ToLower(rt.method)] = g.operation(rt.method, wildcards, rt.desc)
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(g.schemas) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": g.schemas}
+	}
+	return doc
+}
+
+// HandleOpenAPI registers a handler serving the OpenAPI document of the
+// router, as JSON, to GET requests with a path matching pattern. The document
+// is generated for each request, so that it's always in sync with the routes
+// registered.
+func (r *Router) HandleOpenAPI(pattern string, info OpenAPIInfo) {
+	r.Handle(http.MethodGet, pattern, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		b, err := json.Marshal(r.OpenAPI(info))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(b)
+	}))
+}
+
+// openAPIPath returns the OpenAPI path of a pattern, without its host, and
+// the names of its wildcards.
+func openAPIPath(pattern string) (string, []string) {
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	pattern = strings.ReplaceAll(pattern, "{$}", "")
+	var names []string
+	path := wildcard.ReplaceAllStringFunc(pattern, func(m string) string {
+		name := wildcard.FindStringSubmatch(m)[1]
+		names = append(names, name)
+		return "{" + name + "}"
+	})
+	return path, names
+}
+
+// schemaGenerator builds the operations of an OpenAPI document, collecting
+// the schemas of named struct types as components.
+type schemaGenerator struct {
+	names   map[reflect.Type]string
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) operation(method string, wildcards []string, desc serverDescription) map[string]interface{} {
+	op := map[string]interface{}{}
+	if desc.operation.ID != "" {
+		op["operationId"] = desc.operation.ID
+	}
+	if desc.operation.Summary != "" {
+		op["summary"] = desc.operation.Summary
+	}
+	if desc.operation.Description != "" {
+		op["description"] = desc.operation.Description
+	}
+	if len(desc.operation.Tags) > 0 {
+		op["tags"] = desc.operation.Tags
+	}
+	if desc.operation.Deprecated {
+		op["deprecated"] = true
+	}
+
+	mediaTypes := []string{"application/json"}
+	if desc.codecs != nil {
+		mediaTypes = desc.codecs.mediaTypes()
+	}
+
+	params, body := g.request(desc.request)
+	bound := map[string]bool{}
+	for _, p := range params {
+		if p["in"] == "path" {
+			bound[p["name"].(string)] = true
+		}
+	}
+	for _, name := range wildcards {
+		if !bound[name] {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != nil && method != http.MethodGet && method != http.MethodHead {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  content(mediaTypes, body),
+		}
+	}
+
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": http.StatusText(http.StatusOK),
+			"content":     content(mediaTypes, g.schema(desc.response)),
+		},
+	}
+	// Iterate in order, so that colliding schema names are numbered the same
+	// way every time.
+	codes := make([]int, 0, len(desc.operation.Errors))
+	for code := range desc.operation.Errors {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		v := desc.operation.Errors[code]
+		resp := map[string]interface{}{"description": http.StatusText(code)}
+		if v != nil {
+			errorTypes := []string{"application/json"}
+			if _, ok := v.(*Problem); ok {
+				errorTypes = []string{ProblemContentType}
+			}
+			resp["content"] = content(errorTypes, g.schema(reflect.TypeOf(v)))
+		}
+		responses[strconv.Itoa(code)] = resp
+	}
+	op["responses"] = responses
+	return op
+}
+
+func content(mediaTypes []string, schema map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		c[mediaType] = map[string]interface{}{"schema": schema}
+	}
+	return c
+}
+
+// request returns the parameters and the body schema of a Request type. The
+// body schema is nil if there's no body.
+func (g *schemaGenerator) request(t reflect.Type) (params []map[string]interface{}, body map[string]interface{}) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || implementsSchemaer(t) {
+		return nil, g.schema(t)
+	}
+
+	params = g.params(t)
+	if len(params) == 0 {
+		if t.NumField() == 0 {
+			return nil, nil
+		}
+		return nil, g.schema(t)
+	}
+	body = g.structSchema(t, true)
+	if len(body["properties"].(map[string]interface{})) == 0 {
+		return params, nil
+	}
+	return params, body
+}
+
+// params returns the parameters bound from the fields of a struct type, as
+// by Bind.
+func (g *schemaGenerator) params(t reflect.Type) []map[string]interface{} {
+	var params []map[string]interface{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		source, name, required, ok := parseBindTag(field)
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				params = append(params, g.params(field.Type)...)
+			}
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       source,
+			"required": required || source == "path",
+			"schema":   g.paramSchema(field.Type),
+		})
+	}
+	return params
+}
+
+// paramSchema returns the schema of a parameter bound to a field of type t,
+// as parsed by Bind.
+func (g *schemaGenerator) paramSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case reflect.PointerTo(t).Implements(textUnmarshalerType), t == durationType:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Pointer:
+		return g.paramSchema(t.Elem())
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.paramSchema(t.Elem())}
+	}
+	return g.schema(t)
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	schemaerType      = reflect.TypeOf((*Schemaer)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func implementsSchemaer(t reflect.Type) bool {
+	return t.Implements(schemaerType) || reflect.PointerTo(t).Implements(schemaerType)
+}
+
+// schema returns the JSON Schema of the JSON encoding of values of type t.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case implementsSchemaer(t):
+		if t.Kind() == reflect.Pointer {
+			return reflect.New(t.Elem()).Interface().(Schemaer).OpenAPISchema()
+		}
+		return reflect.New(t).Interface().(Schemaer).OpenAPISchema()
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": t.Kind().String()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    g.schema(t.Elem()),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, false)
+		}
+		return g.ref(t)
+	}
+	return map[string]interface{}{}
+}
+
+// unsafeName matches what isn't allowed in the names of schema components.
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ref returns a reference to the schema component of a named struct type,
+// adding it if needed.
+func (g *schemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	name, ok := g.names[t]
+	if !ok {
+		base := strings.Trim(unsafeName.ReplaceAllString(t.Name(), "_"), "_")
+		name = base
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		g.names[t] = name
+		g.schemas[name] = map[string]interface{}{} // placeholder, for recursive types
+		g.schemas[name] = g.structSchema(t, false)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// structSchema returns the schema of a struct type, following the rules of
+// encoding/json. If params is true, fields bound to parameters are left out.
+func (g *schemaGenerator) structSchema(t reflect.Type, params bool) map[string]interface{} {
+	var (
+		properties = map[string]interface{}{}
+		required   []string
+	)
+	g.fields(t, params, properties, &required)
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, params bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if params {
+			if _, _, _, ok := parseBindTag(field); ok {
+				continue
+			}
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := field.Type
+		if field.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, params, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schema(field.Type)
+		if hasOption(opts, "string") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = schema
+		if !hasOption(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypes returns the media types of the registered codecs, in order of
+// preference.
+func (c *Codecs) mediaTypes() []string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	mediaTypes := make([]string, 0, len(c.entries))
+	for _, e := range c.entries {
+		mediaTypes = append(mediaTypes, e.mediaType)
+	}
+	return mediaTypes
+}
@@ -0,0 +1,207 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type getUserRequest struct {
+	Org    string   `path:"org"`
+	ID     int64    `path:"id"`
+	Fields []string `query:"field"`
+	Token  string   `header:"X-Token,required"`
+}
+
+type updateUserRequest struct {
+	Org  string   `path:"org"`
+	ID   int64    `path:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type user struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Manager  *user     `json:"manager,omitempty"`
+	internal string
+}
+
+func TestRouterOpenAPI(t *testing.T) {
+	router := httptransport.NewRouter()
+	router.Handle(http.MethodGet, "/orgs/{org}/users/{id}", httptransport.NewServer(
+		func(context.Context, getUserRequest) (user, error) { return user{}, nil },
+		httptransport.DecodeParams[getUserRequest](),
+		httptransport.EncodeJSONResponse[user],
+		httptransport.ServerOperation[getUserRequest, user](httptransport.Operation{
+			ID:     "getUser",
+			Tags:   []string{"users"},
+			Errors: map[int]interface{}{http.StatusNotFound: &errcode.Error{}},
+		}),
+	))
+	router.Handle(http.MethodPatch, "/orgs/{org}/users/{id}", httptransport.NewServer(
+		func(context.Context, updateUserRequest) (user, error) { return user{}, nil },
+		httptransport.DecodeParams[updateUserRequest](),
+		httptransport.EncodeJSONResponse[user],
+		httptransport.ServerOperation[updateUserRequest, user](httptransport.Operation{
+			Errors: map[int]interface{}{http.StatusConflict: &httptransport.Problem{}},
+		}),
+	))
+	router.Handle(http.MethodGet, "/healthz", http.NotFoundHandler())
+	router.HandleOpenAPI("/openapi.json", httptransport.OpenAPIInfo{Title: "users", Version: "1.0"})
+
+	// Routes registered after HandleOpenAPI are documented too.
+	router.Handle(http.MethodPost, "/echo/{path...}", httptransport.NewServer(
+		func(_ context.Context, s string) (string, error) { return s, nil },
+		httptransport.DecodeRequest[string](httptransport.DefaultCodecs),
+		httptransport.EncodeJSONResponse[string],
+		httptransport.ServerCodecs[string, string](httptransport.NewCodecs(httptransport.JSONCodec{})),
+	))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Info       map[string]string                     `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "3.1.0", doc.OpenAPI; want != have {
+		t.Errorf("want openapi %s, have %s", want, have)
+	}
+	if want, have := "users", doc.Info["title"]; want != have {
+		t.Errorf("want title %s, have %s", want, have)
+	}
+	if want, have := 2, len(doc.Paths); want != have {
+		t.Errorf("want %d paths, have %d", want, have)
+	}
+
+	for _, testcase := range []struct {
+		path, method, want string
+	}{
+		{
+			"/orgs/{org}/users/{id}", "get",
+			`{"operationId":"getUser","parameters":[` +
+				`{"in":"path","name":"org","required":true,"schema":{"type":"string"}},` +
+				`{"in":"path","name":"id","required":true,"schema":{"format":"int64","type":"integer"}},` +
+				`{"in":"query","name":"field","required":false,"schema":{"items":{"type":"string"},"type":"array"}},` +
+				`{"in":"header","name":"X-Token","required":true,"schema":{"type":"string"}}],` +
+				`"responses":{` +
+				`"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/user"}}},"description":"OK"},` +
+				`"404":{"content":{"application/json":{"schema":` + string(mustMarshal(t, (&errcode.Error{}).OpenAPISchema())) + `}},"description":"Not Found"}},` +
+				`"tags":["users"]}`,
+		},
+		{
+			"/orgs/{org}/users/{id}", "patch",
+			`{"parameters":[` +
+				`{"in":"path","name":"org","required":true,"schema":{"type":"string"}},` +
+				`{"in":"path","name":"id","required":true,"schema":{"format":"int64","type":"integer"}}],` +
+				`"requestBody":{"content":{"application/json":{"schema":` +
+				`{"properties":{"name":{"type":"string"},"tags":{"items":{"type":"string"},"type":"array"}},"required":["name"],"type":"object"}}},"required":true},` +
+				`"responses":{` +
+				`"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/user"}}},"description":"OK"},` +
+				`"409":{"content":{"application/problem+json":{"schema":` + string(mustMarshal(t, (&httptransport.Problem{}).OpenAPISchema())) + `}},"description":"Conflict"}}}`,
+		},
+		{
+			"/echo/{path}", "post",
+			`{"parameters":[{"in":"path","name":"path","required":true,"schema":{"type":"string"}}],` +
+				`"requestBody":{"content":{"application/json":{"schema":{"type":"string"}}},"required":true},` +
+				`"responses":{"200":{"content":{"application/json":{"schema":{"type":"string"}}},"description":"OK"}}}`,
+		},
+	} {
+		have, err := json.Marshal(doc.Paths[testcase.path][testcase.method])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := testcase.want; want != string(have) {
+			t.Errorf("%s %s:\nwant %s\nhave %s", testcase.method, testcase.path, want, have)
+		}
+	}
+
+	want := `{"properties":{` +
+		`"created":{"format":"date-time","type":"string"},` +
+		`"id":{"format":"int64","type":"integer"},` +
+		`"manager":{"$ref":"#/components/schemas/user"},` +
+		`"name":{"type":"string"}},` +
+		`"required":["created","id","name"],"type":"object"}`
+	if have := string(doc.Components.Schemas["user"]); want != have {
+		t.Errorf("user schema:\nwant %s\nhave %s", want, have)
+	}
+}
+
+func TestRouterOpenAPIErrorNames(t *testing.T) {
+	// Local types in different functions share a name, so their schema
+	// components are numbered in the order of their status codes.
+	badRequest := func() interface{} {
+		type apiError struct {
+			Reason string `json:"reason"`
+		}
+		return apiError{}
+	}()
+	unavailable := func() interface{} {
+		type apiError struct {
+			RetryAfter int `json:"retryAfter"`
+		}
+		return apiError{}
+	}()
+
+	router := httptransport.NewRouter()
+	router.Handle(http.MethodGet, "/", httptransport.NewServer(
+		func(context.Context, string) (string, error) { return "", nil },
+		httptransport.DecodeParams[string](),
+		httptransport.EncodeJSONResponse[string],
+		httptransport.ServerOperation[string, string](httptransport.Operation{
+			Errors: map[int]interface{}{
+				http.StatusServiceUnavailable: unavailable,
+				http.StatusBadRequest:         badRequest,
+			},
+		}),
+	))
+	router.HandleOpenAPI("/openapi.json", httptransport.OpenAPIInfo{Title: "errors", Version: "1.0"})
+
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		var doc struct {
+			Components struct {
+				Schemas map[string]json.RawMessage `json:"schemas"`
+			} `json:"components"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]string{
+			"apiError":  `{"properties":{"reason":{"type":"string"}},"required":["reason"],"type":"object"}`,
+			"apiError2": `{"properties":{"retryAfter":{"type":"integer"}},"required":["retryAfter"],"type":"object"}`,
+		} {
+			if have := string(doc.Components.Schemas[name]); want != have {
+				t.Fatalf("%s schema:\nwant %s\nhave %s", name, want, have)
+			}
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+// ProblemContentType is the media type of problem details, as defined by
+// RFC 9457.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. It's also an error, so that
+// problems decoded by clients can be returned as such.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. If it's empty,
+	// it's "about:blank", meaning that the problem has no semantics beyond
+	// its status code.
+	Type string
+
+	// Title is a short summary of the problem type.
+	Title string
+
+	// Status is the HTTP status code of the problem.
+	Status int
+
+	// Detail explains this occurrence of the problem.
+	Detail string
+
+	// Instance is a URI reference identifying this occurrence of the
+	// problem.
+	Instance string
+
+	// Extensions are additional members of the problem object.
+	Extensions map[string]interface{}
+}
+
+// Problemer is checked by ProblemErrorEncoder. If an error value implements
+// Problemer, its Problem is encoded. Members left empty are filled in as for
+// other errors.
+type Problemer interface {
+	Problem() *Problem
+}
+
+// Error implements the error interface. It returns the detail, or the title
+// if there's no detail.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.Status)
+}
+
+// StatusCode implements StatusCoder.
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// MarshalJSON implements json.Marshaler. Extensions are encoded as members of
+// the problem object, alongside the standard ones.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for k, v := range map[string]string{
+		"type":     p.Type,
+		"title":    p.Title,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	} {
+		delete(m, k)
+		if v != "" {
+			m[k] = v
+		}
+	}
+	delete(m, "status")
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Members other than the standard
+// ones are decoded into Extensions. Standard members of the wrong type are
+// ignored, as RFC 9457 requires.
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for k, raw := range m {
+		switch k {
+		case "type":
+			json.Unmarshal(raw, &p.Type)
+		case "title":
+			json.Unmarshal(raw, &p.Title)
+		case "status":
+			json.Unmarshal(raw, &p.Status)
+		case "detail":
+			json.Unmarshal(raw, &p.Detail)
+		case "instance":
+			json.Unmarshal(raw, &p.Instance)
+		default:
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			if p.Extensions == nil {
+				p.Extensions = map[string]interface{}{}
+			}
+			p.Extensions[k] = v
+		}
+	}
+	return nil
+}
+
+// NewProblem returns the Problem describing err. If err is, or wraps, a
+// Problemer or a *Problem, its Problem is used. An *errcode.Error becomes a
+// Problem with its message as detail, and its code, details and violations as
+// the "code", "details" and "violations" extensions. Other errors get their
+// message as detail. The status is taken from the StatusCoder interface,
+// 500 by default, and the title is the status text.
+func NewProblem(err error) *Problem {
+	var (
+		p  Problem
+		pr Problemer
+		pp *Problem
+		e  *errcode.Error
+	)
+	switch {
+	case errors.As(err, &pr):
+		p = *pr.Problem()
+	case errors.As(err, &pp):
+		p = *pp
+	case errors.As(err, &e):
+		p = Problem{Status: e.StatusCode(), Detail: e.Message, Extensions: map[string]interface{}{"code": e.Code.String()}}
+		if len(e.Details) > 0 {
+			p.Extensions["details"] = e.Details
+		}
+		if len(e.Violations) > 0 {
+			p.Extensions["violations"] = e.Violations
+		}
+	default:
+		p = Problem{Detail: err.Error()}
+	}
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+		if sc, ok := err.(StatusCoder); ok {
+			p.Status = sc.StatusCode()
+		}
+	}
+	if p.Title == "" && (p.Type == "" || p.Type == "about:blank") {
+		p.Title = http.StatusText(p.Status)
+	}
+	return &p
+}
+
+// ProblemErrorEncoder is an ErrorEncoder that writes the error as RFC 9457
+// problem details, built by NewProblem, with a content type of
+// application/problem+json. If the error, or the *errcode.Error it wraps,
+// implements Headerer, the provided headers will be applied to the response.
+func ProblemErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	p := NewProblem(err)
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		err = e
+	}
+	if headerer, ok := err.(Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// DecodeProblem decodes the problem details in the body of resp into an
+// error. Problems with an errcode.Header header, as written by
+// ProblemErrorEncoder for an *errcode.Error, are decoded into that
+// *errcode.Error. Others are returned as a *Problem. If the body can't be
+// decoded, the *Problem is built from the status code.
+func DecodeProblem(resp *http.Response) error {
+	p := &Problem{}
+	if err := json.NewDecoder(resp.Body).Decode(p); err != nil {
+		p = &Problem{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	code, ok := errcode.ParseCode(resp.Header.Get(errcode.Header))
+	if !ok {
+		return p
+	}
+	e := &errcode.Error{Code: code, Message: p.Detail}
+	if b, err := json.Marshal(p.Extensions); err == nil {
+		var ext struct {
+			Details    map[string]string   `json:"details"`
+			Violations []errcode.Violation `json:"violations"`
+		}
+		if json.Unmarshal(b, &ext) == nil {
+			e.Details, e.Violations = ext.Details, ext.Violations
+		}
+	}
+	return e
+}
+
+func isProblem(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mediaType == ProblemContentType
+}
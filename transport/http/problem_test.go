@@ -0,0 +1,146 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "teapot" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+type outOfCredit struct{ balance int }
+
+func (e outOfCredit) Error() string { return "not enough credit" }
+
+func (e outOfCredit) Problem() *httptransport.Problem {
+	return &httptransport.Problem{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     http.StatusForbidden,
+		Detail:     "Your current balance is 30, but that costs 50.",
+		Instance:   "/account/12345/msgs/abc",
+		Extensions: map[string]interface{}{"balance": e.balance},
+	}
+}
+
+func TestProblemErrorEncoder(t *testing.T) {
+	for _, testcase := range []struct {
+		name   string
+		err    error
+		status int
+		body   map[string]interface{}
+	}{
+		{
+			"plain error", errors.New("dang"), http.StatusInternalServerError,
+			map[string]interface{}{"title": "Internal Server Error", "status": 500.0, "detail": "dang"},
+		},
+		{
+			"status coder", teapotError{}, http.StatusTeapot,
+			map[string]interface{}{"title": "I'm a teapot", "status": 418.0, "detail": "teapot"},
+		},
+		{
+			"problemer", outOfCredit{30}, http.StatusForbidden,
+			map[string]interface{}{
+				"type":     "https://example.com/probs/out-of-credit",
+				"title":    "You do not have enough credit.",
+				"status":   403.0,
+				"detail":   "Your current balance is 30, but that costs 50.",
+				"instance": "/account/12345/msgs/abc",
+				"balance":  30.0,
+			},
+		},
+		{
+			"errcode", errcode.New(errcode.NotFound, "no such user").WithDetail("id", "42"), http.StatusNotFound,
+			map[string]interface{}{
+				"title":   "Not Found",
+				"status":  404.0,
+				"detail":  "no such user",
+				"code":    "NOT_FOUND",
+				"details": map[string]interface{}{"id": "42"},
+			},
+		},
+	} {
+		rec := httptest.NewRecorder()
+		httptransport.ProblemErrorEncoder(context.Background(), testcase.err, rec)
+		if want, have := testcase.status, rec.Code; want != have {
+			t.Errorf("%s: want status %d, have %d", testcase.name, want, have)
+		}
+		if want, have := httptransport.ProblemContentType, rec.Header().Get("Content-Type"); want != have {
+			t.Errorf("%s: want %q, have %q", testcase.name, want, have)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", testcase.name, err)
+		}
+		if !reflect.DeepEqual(testcase.body, body) {
+			t.Errorf("%s: want %v, have %v", testcase.name, testcase.body, body)
+		}
+	}
+}
+
+func TestClientDecodesProblem(t *testing.T) {
+	for _, testcase := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			"problem", outOfCredit{30}, outOfCredit{30}.Problem(),
+		},
+		{
+			"errcode",
+			errcode.New(errcode.InvalidArgument, "bad signup").WithViolation("name", "must not be empty"),
+			errcode.New(errcode.InvalidArgument, "bad signup").WithViolation("name", "must not be empty"),
+		},
+	} {
+		err := testcase.err
+		server := httptest.NewServer(httptransport.NewServer(
+			func(context.Context, interface{}) (interface{}, error) { return nil, err },
+			func(context.Context, *http.Request) (interface{}, error) { return struct{}{}, nil },
+			func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+			httptransport.ServerErrorEncoder[interface{}, interface{}](httptransport.ProblemErrorEncoder),
+		))
+		client := httptransport.NewClient(
+			http.MethodGet,
+			mustParse(server.URL),
+			func(context.Context, *http.Request, interface{}) error { return nil },
+			func(context.Context, *http.Response) (interface{}, error) {
+				t.Fatal("decode should not be called for a problem")
+				return nil, nil
+			},
+			httptransport.ClientDecodeErrors[interface{}, interface{}](),
+		)
+		_, have := client.Endpoint()(context.Background(), struct{}{})
+		server.Close()
+
+		if want := testcase.want; reflect.TypeOf(want) != reflect.TypeOf(have) {
+			t.Errorf("%s: want %T, have %T %v", testcase.name, want, have, have)
+			continue
+		}
+		want, _ := json.Marshal(testcase.want)
+		got, _ := json.Marshal(have)
+		if string(want) != string(got) {
+			t.Errorf("%s: want %s, have %s", testcase.name, want, got)
+		}
+	}
+}
+
+func TestProblemUnmarshalIgnoresInvalidMembers(t *testing.T) {
+	var p httptransport.Problem
+	if err := json.Unmarshal([]byte(`{"type":42,"title":"Oops","status":"500"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "" || p.Title != "Oops" || p.Status != 0 {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
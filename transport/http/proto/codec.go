@@ -0,0 +1,35 @@
+package proto
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec is an httptransport.Codec for Protobuf, as application/x-protobuf.
+// Register it in an httptransport.Codecs to negotiate Protobuf alongside
+// other encodings, e.g.
+//
+//	codecs.Register(proto.Codec{}, "application/protobuf")
+type Codec struct{}
+
+// ContentType implements httptransport.Codec.
+func (Codec) ContentType() string { return "application/x-protobuf" }
+
+// Marshal implements httptransport.Codec.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.New("value does not implement proto.Message")
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal implements httptransport.Codec.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("value does not implement proto.Message")
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -1,6 +1,7 @@
 package proto
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"net/http"
@@ -8,6 +9,8 @@ import (
 	"testing"
 
 	"google.golang.org/protobuf/proto"
+
+	httptransport "github.com/openmesh/kit/transport/http"
 )
 
 func TestEncodeProtoRequest(t *testing.T) {
@@ -94,3 +97,40 @@ func TestEncodeProtoResponse(t *testing.T) {
 func (c *Cat) StatusCode() int {
 	return http.StatusTeapot
 }
+
+func TestCodecNegotiation(t *testing.T) {
+	codecs := httptransport.NewCodecs(httptransport.JSONCodec{}, Codec{})
+	codecs.Register(Codec{}, "application/protobuf")
+
+	server := httptest.NewServer(httptransport.NewServer(
+		func(_ context.Context, cat *Cat) (*Cat, error) {
+			cat.Age++
+			return cat, nil
+		},
+		httptransport.DecodeRequest[*Cat](codecs),
+		httptransport.EncodeResponse[*Cat](codecs),
+		httptransport.ServerCodecs[*Cat, *Cat](codecs),
+	))
+	defer server.Close()
+
+	b, _ := proto.Marshal(&Cat{Name: "Ziggy", Age: 13, Breed: "Lumpy"})
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/protobuf")
+	req.Header.Set("Accept", "application/json;q=0.5, application/x-protobuf")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if want, have := "application/x-protobuf", resp.Header.Get("Content-Type"); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	cat, err := httptransport.DecodeResponse[*Cat](codecs)(context.Background(), resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(14), cat.Age; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
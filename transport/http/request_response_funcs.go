@@ -130,4 +130,13 @@ const (
 	// ContextKeyResponseSize is populated in the context whenever a
 	// ServerFinalizerFunc is specified. Its value is of type int64.
 	ContextKeyResponseSize
+
+	// ContextKeyResponseCodec is populated in the context by servers with the
+	// ServerCodecs option. Its value is the Codec negotiated from the Accept
+	// header.
+	ContextKeyResponseCodec
+
+	// ContextKeyPathParams is populated in the context by Router. Its value
+	// is of type PathParams.
+	ContextKeyPathParams
 )
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// PathParams are the values of the wildcards in the pattern of a route, by
+// name.
+type PathParams map[string]string
+
+// PathParamsFromContext returns the path params put in the context by a
+// Router, if any.
+func PathParamsFromContext(ctx context.Context) PathParams {
+	params, _ := ctx.Value(ContextKeyPathParams).(PathParams)
+	return params
+}
+
+// PathParam returns the value of the named wildcard in the route of the
+// request, as put in the context by a Router. It's empty if there's no such
+// wildcard.
+func PathParam(ctx context.Context, name string) string {
+	return PathParamsFromContext(ctx)[name]
+}
+
+// Router dispatches requests to handlers, typically Servers, by method and
+// path pattern. Patterns follow the syntax of http.ServeMux, and may contain
+// wildcards like "/users/{id}" or "/files/{path...}". The values of the
+// wildcards are put in the request context as PathParams, for decode funcs
+// and endpoints.
+//
+// A request whose path matches a pattern registered for other methods only
+// gets a 405 Method Not Allowed reply.
+//
+// The Servers registered with a Router for a method are documented by its
+// OpenAPI document.
+type Router struct {
+	mux *http.ServeMux
+
+	mtx    sync.Mutex
+	routes []route // of Servers, for OpenAPI
+}
+
+type route struct {
+	method  string
+	pattern string
+	desc    serverDescription
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// wildcard matches the wildcards of a pattern, but not the {$} anchor.
+var wildcard = regexp.MustCompile(`\{([^{}.$]+)(\.\.\.)?\}`)
+
+// Handle registers h for requests with the given method and a path matching
+// pattern. An empty method matches all methods. Like http.ServeMux.Handle, it
+// panics if the pattern is invalid or conflicts with a registered one.
+func (r *Router) Handle(method, pattern string, h http.Handler) {
+	var names []string
+	for _, m := range wildcard.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, m[1])
+	}
+	muxPattern := pattern
+	if method != "" {
+		muxPattern = method + " " + pattern
+	}
+	r.mux.Handle(muxPattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(names) > 0 {
+			params := make(PathParams, len(names))
+			for _, name := range names {
+				params[name] = req.PathValue(name)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), ContextKeyPathParams, params))
+		}
+		h.ServeHTTP(w, req)
+	}))
+
+	if d, ok := h.(describer); ok && method != "" {
+		r.mtx.Lock()
+		r.routes = append(r.routes, route{method, pattern, d.describe()})
+		r.mtx.Unlock()
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
@@ -0,0 +1,59 @@
+package http_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+func TestRouter(t *testing.T) {
+	echo := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return httptransport.PathParamsFromContext(ctx), nil
+	}
+	newServer := func() http.Handler {
+		return httptransport.NewServer(
+			echo,
+			httptransport.NopRequestDecoder,
+			httptransport.EncodeJSONResponse[interface{}],
+		)
+	}
+
+	router := httptransport.NewRouter()
+	router.Handle(http.MethodGet, "/orgs/{org}/users/{id}", newServer())
+	router.Handle(http.MethodGet, "/files/{path...}", newServer())
+	router.Handle("", "/{$}", newServer())
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/orgs/acme/users/42", http.StatusOK, `{"id":"42","org":"acme"}`},
+		{http.MethodGet, "/files/a/b/c.txt", http.StatusOK, `{"path":"a/b/c.txt"}`},
+		{http.MethodDelete, "/", http.StatusOK, `null`},
+		{http.MethodPost, "/orgs/acme/users/42", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/nope", http.StatusNotFound, ""},
+	} {
+		req, _ := http.NewRequest(testcase.method, server.URL+testcase.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := testcase.code, resp.StatusCode; want != have {
+			t.Errorf("%s %s: want %d, have %d", testcase.method, testcase.path, want, have)
+			continue
+		}
+		if testcase.body != "" && testcase.body+"\n" != string(b) {
+			t.Errorf("%s %s: want %s, have %s", testcase.method, testcase.path, testcase.body, b)
+		}
+	}
+}
@@ -3,23 +3,34 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 	"github.com/openmesh/kit/transport"
 )
 
 // Server wraps an endpoint and implements http.Handler.
 type Server[Request, Response any] struct {
-	e            endpoint.Endpoint[Request, Response]
-	dec          DecodeRequestFunc[Request]
-	enc          EncodeResponseFunc[Response]
-	before       []RequestFunc
-	after        []ServerResponseFunc
-	errorEncoder ErrorEncoder
-	finalizer    []ServerFinalizerFunc
-	errorHandler transport.ErrorHandler
+	e             endpoint.Endpoint[Request, Response]
+	dec           DecodeRequestFunc[Request]
+	enc           EncodeResponseFunc[Response]
+	before        []RequestFunc
+	after         []ServerResponseFunc
+	errorEncoder  ErrorEncoder
+	finalizer     []ServerFinalizerFunc
+	errorHandler  transport.ErrorHandler
+	codecs        *Codecs
+	compression   *compression
+	maxBodySize   int64
+	readTimeout   time.Duration
+	strictJSON    func() interface{}
+	preconditions ValidatorsFunc[Request]
+	operation     Operation
+	budget        bool
 }
 
 // NewServer constructs a new server, which implements http.Handler and wraps
@@ -91,10 +102,26 @@ func ServerFinalizer[Request, Response any](f ...ServerFinalizerFunc) ServerOpti
 	return func(s *Server[Request, Response]) { s.finalizer = append(s.finalizer, f...) }
 }
 
-// ServeHTTP implements http.Handler.
+// ServerBudget makes the server restore the transport.BudgetHeader header of
+// requests as the deadline of the request context. Only use it for servers
+// whose clients are trusted, since a client can then shorten the time given
+// to the endpoints. By default, the header is ignored.
+func ServerBudget[Request, Response any]() ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.budget = true }
+}
+
+// ServeHTTP implements http.Handler. Conditional GET and HEAD requests are
+// answered with a 304 instead of encoding the response, see
+// ServerPreconditions.
 func (s Server[Request, Response]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	if s.budget {
+		var cancel context.CancelFunc
+		ctx, cancel = transport.WithBudget(ctx, r.Header.Get(transport.BudgetHeader))
+		defer cancel()
+	}
 
+	rw := w // unwrapped, for decompressRequest and limitRequest
 	if len(s.finalizer) > 0 {
 		iw := &interceptingWriter{w, http.StatusOK, 0}
 		defer func() {
@@ -107,16 +134,50 @@ func (s Server[Request, Response]) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		w = iw.reimplementInterfaces()
 	}
 
+	if s.compression != nil {
+		cw := s.compression.newWriter(w, r)
+		defer cw.Close()
+		w = cw.reimplementInterfaces()
+
+		if err := s.compression.decompressRequest(rw, r); err != nil {
+			s.errorHandler.Handle(ctx, err)
+			s.errorEncoder(ctx, err, w)
+			return
+		}
+	}
+
+	if err := s.limitRequest(rw, r); err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.errorEncoder(ctx, err, w)
+		return
+	}
+
 	for _, f := range s.before {
 		ctx = f(ctx, r)
 	}
 
+	if s.codecs != nil {
+		codec, ok := s.codecs.Negotiate(r.Header.Get("Accept"))
+		if !ok {
+			s.errorHandler.Handle(ctx, ErrNotAcceptable)
+			s.errorEncoder(ctx, ErrNotAcceptable, w)
+			return
+		}
+		ctx = context.WithValue(ctx, ContextKeyResponseCodec, codec)
+	}
+
 	request, err := s.dec(ctx, r)
 	if err != nil {
+		err = s.bodyError(err)
 		s.errorHandler.Handle(ctx, err)
 		s.errorEncoder(ctx, err, w)
 		return
 	}
+	s.endRead(rw)
+
+	if s.checkPreconditions(ctx, w, r, request) {
+		return
+	}
 
 	response, err := s.e(ctx, request)
 	if err != nil {
@@ -125,10 +186,17 @@ func (s Server[Request, Response]) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	etag, lastModified := responseValidators(response)
+	setValidators(w.Header(), etag, lastModified)
+
 	for _, f := range s.after {
 		ctx = f(ctx, w)
 	}
 
+	if s.checkValidators(ctx, w, r, response) {
+		return
+	}
+
 	if err := s.enc(ctx, w, response); err != nil {
 		s.errorHandler.Handle(ctx, err)
 		s.errorEncoder(ctx, err, w)
@@ -186,8 +254,13 @@ func EncodeJSONResponse[Response any](_ context.Context, w http.ResponseWriter,
 // will be applied to the response. If the error implements json.Marshaler, and
 // the marshaling succeeds, a content type of application/json and the JSON
 // encoded form of the error will be used. If the error implements StatusCoder,
-// the provided StatusCode will be used instead of 500.
+// the provided StatusCode will be used instead of 500. If the error wraps an
+// *errcode.Error, that Error is encoded instead of the wrapper.
 func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		err = e
+	}
 	contentType, body := "text/plain; charset=utf-8", []byte(err.Error())
 	if marshaler, ok := err.(json.Marshaler); ok {
 		if jsonBody, marshalErr := marshaler.MarshalJSON(); marshalErr == nil {
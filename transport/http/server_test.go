@@ -6,11 +6,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/transport"
 	httptransport "github.com/openmesh/kit/transport/http"
 )
 
@@ -440,3 +442,65 @@ func testServer(t *testing.T) (step func(), resp <-chan *http.Response) {
 	}()
 	return func() { stepch <- true }, response
 }
+
+func TestServerRestoresClientBudget(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	handler := httptransport.NewServer(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return nil, errors.New("no deadline")
+			}
+			remaining <- time.Until(deadline)
+			return struct{}{}, nil
+		},
+		func(context.Context, *http.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+		httptransport.ServerBudget[interface{}, interface{}](),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	client := httptransport.NewClient[interface{}, interface{}](
+		"GET",
+		target,
+		func(context.Context, *http.Request, interface{}) error { return nil },
+		func(context.Context, *http.Response) (interface{}, error) { return struct{}{}, nil },
+		httptransport.ClientBudget[interface{}, interface{}](),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Endpoint()(ctx, struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case d := <-remaining:
+		if d <= 4*time.Second || d > 5*time.Second {
+			t.Errorf("want about 5s, have %v", d)
+		}
+	default:
+		t.Fatal("endpoint wasn't called with a deadline")
+	}
+}
+
+func TestServerIgnoresBudgetByDefault(t *testing.T) {
+	handler := httptransport.NewServer(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			if _, ok := ctx.Deadline(); ok {
+				return nil, errors.New("unexpected deadline")
+			}
+			return struct{}{}, nil
+		},
+		func(context.Context, *http.Request) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+	)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(transport.BudgetHeader, "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Errorf("want %d, have %d: %s", want, have, rec.Body)
+	}
+}
@@ -0,0 +1,169 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/openmesh/kit/endpoint"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+// Client wraps a URL serving an event stream and provides a method that
+// implements endpoint.Endpoint.
+type Client[Request any] struct {
+	client httptransport.HTTPClient
+	method string
+	tgt    *url.URL
+	enc    httptransport.EncodeRequestFunc[Request]
+	before []httptransport.RequestFunc
+	after  []httptransport.ClientResponseFunc
+	buffer int
+}
+
+// NewClient constructs a usable Client for a single remote event stream.
+func NewClient[Request any](
+	method string,
+	tgt *url.URL,
+	enc httptransport.EncodeRequestFunc[Request],
+	options ...ClientOption[Request],
+) *Client[Request] {
+	c := &Client[Request]{
+		client: http.DefaultClient,
+		method: method,
+		tgt:    tgt,
+		enc:    enc,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// ClientOption sets an optional parameter for clients.
+type ClientOption[Request any] func(*Client[Request])
+
+// SetClient sets the underlying HTTP client used for requests. By default,
+// http.DefaultClient is used. Its timeout, if any, bounds the whole stream.
+func SetClient[Request any](client httptransport.HTTPClient) ClientOption[Request] {
+	return func(c *Client[Request]) { c.client = client }
+}
+
+// ClientBefore adds one or more RequestFuncs to be applied to the outgoing
+// HTTP request before it's invoked.
+func ClientBefore[Request any](before ...httptransport.RequestFunc) ClientOption[Request] {
+	return func(c *Client[Request]) { c.before = append(c.before, before...) }
+}
+
+// ClientAfter adds one or more ClientResponseFuncs, which are applied to the
+// incoming HTTP response before the stream is read.
+func ClientAfter[Request any](after ...httptransport.ClientResponseFunc) ClientOption[Request] {
+	return func(c *Client[Request]) { c.after = append(c.after, after...) }
+}
+
+// ClientBuffer sets the capacity of the channel of events returned by the
+// endpoint. By default, it's unbuffered.
+func ClientBuffer[Request any](n int) ClientOption[Request] {
+	return func(c *Client[Request]) { c.buffer = n }
+}
+
+// Stream is an event stream opened by a Client.
+type Stream struct {
+	events <-chan Event
+	err    error // set before events is closed
+}
+
+// Events returns the channel on which the events are sent as they're
+// decoded. It's closed when the stream ends, fails, or the context of the
+// request is done.
+func (s *Stream) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the error that ended the stream, once the channel returned by
+// Events is closed. It's nil if the server ended the stream, and the error of
+// the context if it was done.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Endpoint returns a usable endpoint that opens the event stream. It returns
+// once the response headers are received, with a Stream whose events are
+// sent as they're decoded. The channel of events is closed when the stream
+// ends, fails, or ctx is done; callers must either drain it or cancel ctx,
+// and may then check why it ended with Err. The last event ID in ctx, see
+// WithLastEventID, is sent to resume a stream.
+//
+// Responses with an error status are returned as errors, decoded by
+// httptransport.DecodeError.
+func (c Client[Request]) Endpoint() endpoint.Endpoint[Request, *Stream] {
+	return func(ctx context.Context, request Request) (*Stream, error) {
+		ctx, cancel := context.WithCancel(ctx)
+
+		req, err := http.NewRequest(c.method, c.tgt.String(), nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := c.enc(ctx, req, request); err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		if id, ok := LastEventID(ctx); ok {
+			req.Header.Set(LastEventIDHeader, id)
+		}
+		for _, f := range c.before {
+			ctx = f(ctx, req)
+		}
+
+		resp, err := c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		for _, f := range c.after {
+			ctx = f(ctx, resp)
+		}
+		if resp.StatusCode >= 300 {
+			defer cancel()
+			defer resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				return nil, httptransport.DecodeError(resp)
+			}
+			return nil, fmt.Errorf("sse: unexpected status %s", resp.Status)
+		}
+
+		var (
+			events = make(chan Event, c.buffer)
+			stream = &Stream{events: events}
+		)
+		go func() {
+			defer cancel()
+			defer resp.Body.Close()
+			defer close(events)
+			d := NewDecoder(resp.Body)
+			for {
+				e, err := d.Decode()
+				if err != nil {
+					switch {
+					case ctx.Err() != nil:
+						stream.err = ctx.Err()
+					case err != io.EOF:
+						stream.err = err
+					}
+					return
+				}
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					stream.err = ctx.Err()
+					return
+				}
+			}
+		}()
+		return stream, nil
+	}
+}
@@ -0,0 +1,8 @@
+// Package sse provides a Server-Sent Events binding for endpoints.
+// See https://html.spec.whatwg.org/multipage/server-sent-events.html
+//
+// A Server wraps an endpoint that returns a channel of Events, and streams
+// them to the client as they're sent, until the channel is closed or the
+// client goes away. A Client calls a remote SSE server, and returns a Stream
+// of its events, which also tells why the stream ended.
+package sse
@@ -0,0 +1,110 @@
+package sse
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID sets the last event ID of the client, which it sends back when it
+	// reconnects. See LastEventID.
+	ID string
+
+	// Event is the type of the event. Clients treat events without a type
+	// as "message" events.
+	Event string
+
+	// Data is the payload of the event. It may span several lines.
+	Data string
+
+	// Retry, if positive, sets the time the client waits before
+	// reconnecting.
+	Retry time.Duration
+}
+
+// WriteTo writes the event in the event stream format. It implements
+// io.WriterTo.
+func (e Event) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + singleLine(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + singleLine(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// Decoder reads events from an event stream.
+type Decoder struct {
+	r      *bufio.Reader
+	lastID string
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next event of the stream. Comments, such as heartbeats,
+// are skipped. As in browsers, an event without an id field gets the ID of
+// the last event that had one. At the end of the stream, it returns io.EOF.
+func (d *Decoder) Decode() (Event, error) {
+	var (
+		e       Event
+		data    []string
+		hasData bool
+	)
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return Event{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if !hasData {
+				e = Event{} // nothing to dispatch
+				continue
+			}
+			e.ID, e.Data = d.lastID, strings.Join(data, "\n")
+			return e, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				d.lastID = value
+			}
+		case "event":
+			e.Event = value
+		case "data":
+			data, hasData = append(data, value), true
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/transport"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+// ErrStreamingUnsupported is returned when the http.ResponseWriter can't be
+// flushed, so events can't be streamed.
+var ErrStreamingUnsupported = errors.New("sse: response writer does not support flushing")
+
+// LastEventIDHeader is the header in which a reconnecting client sends the
+// ID of the last event it received.
+const LastEventIDHeader = "Last-Event-ID"
+
+type contextKey int
+
+const lastEventIDKey contextKey = 0
+
+// WithLastEventID returns a copy of ctx carrying the ID of the last event
+// received. A Client sends it to resume a stream.
+func WithLastEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, lastEventIDKey, id)
+}
+
+// LastEventID returns the ID of the last event received by the client, as
+// sent in the Last-Event-ID header and put in the context by the Server.
+// Endpoints use it to resume the stream after that event.
+func LastEventID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(lastEventIDKey).(string)
+	return id, ok && id != ""
+}
+
+// Server wraps an endpoint that returns a channel of events, and implements
+// http.Handler. The events are streamed to the client as they're sent on the
+// channel, until the channel is closed or the client goes away. In the
+// latter case the context passed to the endpoint is canceled: whatever sends
+// on the channel must stop when it's done.
+type Server[Request any] struct {
+	e            endpoint.Endpoint[Request, <-chan Event]
+	dec          httptransport.DecodeRequestFunc[Request]
+	before       []httptransport.RequestFunc
+	after        []httptransport.ServerResponseFunc
+	errorEncoder httptransport.ErrorEncoder
+	errorHandler transport.ErrorHandler
+	heartbeat    time.Duration
+}
+
+// NewServer constructs a new server, which implements http.Handler and wraps
+// the provided endpoint.
+func NewServer[Request any](
+	e endpoint.Endpoint[Request, <-chan Event],
+	dec httptransport.DecodeRequestFunc[Request],
+	options ...ServerOption[Request],
+) *Server[Request] {
+	s := &Server[Request]{
+		e:            e,
+		dec:          dec,
+		errorEncoder: httptransport.DefaultErrorEncoder,
+		errorHandler: transport.NewLogErrorHandler(log.NewNopLogger()),
+		heartbeat:    15 * time.Second,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// ServerOption sets an optional parameter for servers.
+type ServerOption[Request any] func(*Server[Request])
+
+// ServerBefore functions are executed on the HTTP request object before the
+// request is decoded.
+func ServerBefore[Request any](before ...httptransport.RequestFunc) ServerOption[Request] {
+	return func(s *Server[Request]) { s.before = append(s.before, before...) }
+}
+
+// ServerAfter functions are executed on the HTTP response writer after the
+// endpoint is invoked, but before the stream is started.
+func ServerAfter[Request any](after ...httptransport.ServerResponseFunc) ServerOption[Request] {
+	return func(s *Server[Request]) { s.after = append(s.after, after...) }
+}
+
+// ServerErrorEncoder is used to encode errors returned by the decoder or the
+// endpoint, before the stream is started. By default, errors will be written
+// with the httptransport.DefaultErrorEncoder.
+func ServerErrorEncoder[Request any](ee httptransport.ErrorEncoder) ServerOption[Request] {
+	return func(s *Server[Request]) { s.errorEncoder = ee }
+}
+
+// ServerErrorHandler is used to handle non-terminal errors. By default,
+// non-terminal errors are ignored.
+func ServerErrorHandler[Request any](errorHandler transport.ErrorHandler) ServerOption[Request] {
+	return func(s *Server[Request]) { s.errorHandler = errorHandler }
+}
+
+// ServerHeartbeat sets the interval at which a comment is sent while no
+// events are, to keep proxies from closing the connection. By default, it's
+// 15 seconds. Zero disables heartbeats.
+func ServerHeartbeat[Request any](d time.Duration) ServerOption[Request] {
+	return func(s *Server[Request]) { s.heartbeat = d }
+}
+
+// ServeHTTP implements http.Handler.
+func (s Server[Request]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if id := r.Header.Get(LastEventIDHeader); id != "" {
+		ctx = WithLastEventID(ctx, id)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.errorHandler.Handle(ctx, ErrStreamingUnsupported)
+		s.errorEncoder(ctx, ErrStreamingUnsupported, w)
+		return
+	}
+
+	for _, f := range s.before {
+		ctx = f(ctx, r)
+	}
+
+	request, err := s.dec(ctx, r)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.errorEncoder(ctx, err, w)
+		return
+	}
+
+	events, err := s.e(ctx, request)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.errorEncoder(ctx, err, w)
+		return
+	}
+
+	for _, f := range s.after {
+		ctx = f(ctx, w)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+				s.errorHandler.Handle(ctx, err)
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := e.WriteTo(w); err != nil {
+				s.errorHandler.Handle(ctx, err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// FromFunc adapts an iterator of events, in the shape of iter.Seq[Event], to
+// the channel returned by the endpoint of a Server. The iterator is run in a
+// goroutine, and stopped when ctx is done.
+func FromFunc(ctx context.Context, seq func(yield func(Event) bool)) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		seq(func(e Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return events
+}
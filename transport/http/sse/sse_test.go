@@ -0,0 +1,289 @@
+package sse_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+	"github.com/openmesh/kit/transport/http/sse"
+)
+
+func decodeNothing(context.Context, *http.Request) (struct{}, error) { return struct{}{}, nil }
+
+func encodeNothing(context.Context, *http.Request, struct{}) error { return nil }
+
+func newClient(t *testing.T, h http.Handler) *sse.Client[struct{}] {
+	t.Helper()
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return sse.NewClient("GET", u, encodeNothing)
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := []sse.Event{
+		{ID: "1", Event: "greeting", Data: "hello"},
+		{ID: "2", Data: "multi\nline\ndata", Retry: 3 * time.Second},
+		{Data: ""},
+	}
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		events := make(chan sse.Event, len(want))
+		for _, e := range want {
+			events <- e
+		}
+		close(events)
+		return events, nil
+	}, decodeNothing)
+
+	stream, err := newClient(t, server).Endpoint()(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []sse.Event
+	for e := range stream.Events() {
+		have = append(have, e)
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("want no error at the end of the stream, have %v", err)
+	}
+	want[2].ID = "2" // the last event ID carries over
+	if len(want) != len(have) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	for i := range want {
+		if want[i] != have[i] {
+			t.Errorf("event %d: want %+v, have %+v", i, want[i], have[i])
+		}
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		events := make(chan sse.Event)
+		close(events)
+		return events, nil
+	}, decodeNothing)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	for header, want := range map[string]string{
+		"Content-Type":  "text/event-stream",
+		"Cache-Control": "no-cache",
+	} {
+		if have := rec.Header().Get(header); want != have {
+			t.Errorf("%s: want %q, have %q", header, want, have)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		events := make(chan sse.Event)
+		go func() {
+			defer close(events)
+			time.Sleep(50 * time.Millisecond)
+		}()
+		return events, nil
+	}, decodeNothing, sse.ServerHeartbeat[struct{}](5*time.Millisecond))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("want heartbeats, have %q", rec.Body.String())
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		id, _ := sse.LastEventID(ctx)
+		events := make(chan sse.Event, 1)
+		events <- sse.Event{Data: "after " + id}
+		close(events)
+		return events, nil
+	}, decodeNothing)
+
+	ctx := sse.WithLastEventID(context.Background(), "41")
+	stream, err := newClient(t, server).Endpoint()(ctx, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "after 41", (<-stream.Events()).Data; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	done := make(chan struct{})
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		return sse.FromFunc(ctx, func(yield func(sse.Event) bool) {
+			defer close(done)
+			for yield(sse.Event{Data: "tick"}) {
+			}
+		}), nil
+	}, decodeNothing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := newClient(t, server).Endpoint()(ctx, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-stream.Events()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("endpoint was not stopped when the client went away")
+	}
+	for range stream.Events() {
+	}
+	if want, have := context.Canceled, stream.Err(); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestStreamBroken(t *testing.T) {
+	client := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		sse.Event{Data: "hello"}.WriteTo(w)
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler) // drops the connection mid-stream
+	}))
+
+	stream, err := client.Endpoint()(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for range stream.Events() {
+		n++
+	}
+	if want, have := 1, n; want != have {
+		t.Errorf("want %d events, have %d", want, have)
+	}
+	if err := stream.Err(); err == nil {
+		t.Error("want the error that broke the stream, have nil")
+	}
+}
+
+func TestEndpointError(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		return nil, errcode.New(errcode.NotFound, "no such stream")
+	}, decodeNothing)
+
+	_, err := newClient(t, server).Endpoint()(context.Background(), struct{}{})
+	var e *errcode.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("want *errcode.Error, have %T: %v", err, err)
+	}
+	if want, have := errcode.NotFound, e.Code; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		return nil, errcode.New(errcode.PermissionDenied, "not yours")
+	}, decodeNothing, sse.ServerErrorEncoder[struct{}](httptransport.ProblemErrorEncoder))
+
+	_, err := newClient(t, server).Endpoint()(context.Background(), struct{}{})
+	var e *errcode.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("want *errcode.Error, have %T: %v", err, err)
+	}
+	if want, have := errcode.PermissionDenied, e.Code; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestStreamingUnsupported(t *testing.T) {
+	server := sse.NewServer(func(ctx context.Context, _ struct{}) (<-chan sse.Event, error) {
+		t.Fatal("endpoint should not be called")
+		return nil, nil
+	}, decodeNothing)
+
+	w := &unflushable{header: http.Header{}}
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if want, have := http.StatusInternalServerError, w.code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+type unflushable struct {
+	header http.Header
+	code   int
+	bytes.Buffer
+}
+
+func (w *unflushable) Header() http.Header { return w.header }
+
+func (w *unflushable) WriteHeader(code int) { w.code = code }
+
+func TestDecoder(t *testing.T) {
+	stream := ": comment\n" +
+		"retry: 1500\n" +
+		"data:no space\r\n" +
+		"data:  two spaces\n" +
+		"\n" +
+		"\n" + // empty lines between events are ignored
+		"event: only\n" +
+		"\n" + // no data, so nothing is dispatched
+		"id: 7\n" +
+		"unknown: field\n" +
+		"data\n" +
+		"\n" +
+		"data: incomplete" // not terminated, so discarded
+
+	d := sse.NewDecoder(strings.NewReader(stream))
+	for _, want := range []sse.Event{
+		{Data: "no space\n two spaces", Retry: 1500 * time.Millisecond},
+		{ID: "7", Data: ""},
+	} {
+		have, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != have {
+			t.Errorf("want %+v, have %+v", want, have)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("want %v, have %v", io.EOF, err)
+	}
+}
+
+func TestWriteToStripsNewlines(t *testing.T) {
+	var b bytes.Buffer
+	sse.Event{ID: "a\nb", Event: "x\r\ny", Data: "1\r\n2"}.WriteTo(&b)
+	if want, have := "id: ab\nevent: xy\ndata: 1\ndata: 2\n\n", b.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestFromFuncStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	events := sse.FromFunc(ctx, func(yield func(sse.Event) bool) {
+		defer close(stopped)
+		for i := 0; yield(sse.Event{Data: "x"}); i++ {
+		}
+	})
+	<-events
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("iterator was not stopped")
+	}
+	for range events {
+	}
+}
@@ -1,4 +1,10 @@
 // Package httprp provides an HTTP reverse-proxy transport. HTTP handlers that
 // need to proxy requests to another HTTP service can do so with this package by
 // specifying the URL to forward the request to.
+//
+// Requests may also be load balanced across upstreams, with
+// NewInstancerServer for the instances of an sd.Instancer, or with
+// NewBalancedServer for any lb.Balancer of Upstreams, e.g. over an
+// sd.Endpointer built with Factory and per-instance circuit breakers.
+// Idempotent requests may be retried on other upstreams, see ServerRetry.
 package httprp
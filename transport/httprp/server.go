@@ -1,10 +1,23 @@
 package httprp
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/metrics"
+	"github.com/openmesh/kit/sd"
+	"github.com/openmesh/kit/sd/lb"
 )
 
 // RequestFunc may take information from an HTTP request and put it into a
@@ -12,11 +25,52 @@ import (
 // endpoint.
 type RequestFunc func(context.Context, *http.Request) context.Context
 
+// ResponseFunc may take information from the response of an upstream, and
+// modify it, e.g. to rewrite its headers, before it's copied to the client.
+type ResponseFunc func(context.Context, *http.Response) context.Context
+
+// ErrorEncoder is responsible for encoding an error to the ResponseWriter,
+// when the request can't be forwarded to any upstream.
+type ErrorEncoder func(ctx context.Context, err error, w http.ResponseWriter)
+
+// Upstream forwards a request to one upstream, and returns its response. The
+// request is the one received by the Server, and mustn't be modified. If the
+// Request field of the response is nil, it's set to that request, and the
+// response is reported with an "unknown" upstream by ServerMetrics.
+type Upstream = endpoint.Endpoint[*http.Request, *http.Response]
+
+// ErrNoResponse is the error of a request whose Upstream returned neither a
+// response nor an error.
+var ErrNoResponse = errors.New("upstream returned no response")
+
+// UpstreamError is returned by an Upstream when the request couldn't be
+// forwarded to it, or it didn't respond.
+type UpstreamError struct {
+	Upstream string // the host of the upstream
+	Err      error
+}
+
+func (e *UpstreamError) Error() string {
+	return "upstream " + e.Upstream + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
 // Server is a proxying request handler.
 type Server struct {
 	proxy        http.Handler
+	balancer     lb.Balancer[*http.Request, *http.Response]
+	transport    http.RoundTripper
 	before       []RequestFunc
-	errorEncoder func(w http.ResponseWriter, err error)
+	after        []ResponseFunc
+	errorEncoder ErrorEncoder
+	retries      int
+	retryKeyed   bool
+	requests     metrics.Counter
+	latency      metrics.Histogram
 }
 
 // NewServer constructs a new server that implements http.Server and will proxy
@@ -27,12 +81,58 @@ func NewServer(
 	baseURL *url.URL,
 	options ...ServerOption,
 ) *Server {
+	s := newServer(options)
+	s.balancer = lb.NewRoundRobin[*http.Request, *http.Response](
+		sd.FixedEndpointer[*http.Request, *http.Response]{NewUpstream(baseURL, s.transport)},
+	)
+	return s
+}
+
+// NewBalancedServer constructs a new server that proxies each request to an
+// upstream picked by the balancer, e.g. lb.NewRoundRobin over an
+// sd.Endpointer built with Factory. The ServerTransport option is ignored,
+// since the upstreams forward requests themselves.
+func NewBalancedServer(
+	balancer lb.Balancer[*http.Request, *http.Response],
+	options ...ServerOption,
+) *Server {
+	s := newServer(options)
+	s.balancer = balancer
+	return s
+}
+
+// NewInstancerServer constructs a new server that proxies requests to the
+// instances yielded by the instancer, in turn. Instances are URLs, or
+// host:port pairs for the http scheme, see Factory. The logger is used by
+// the underlying sd.Endpointer.
+func NewInstancerServer(
+	instancer sd.Instancer,
+	logger log.Logger,
+	options ...ServerOption,
+) *Server {
+	s := newServer(options)
+	s.balancer = lb.NewRoundRobin[*http.Request, *http.Response](
+		sd.NewEndpointer(instancer, Factory(s.transport), logger),
+	)
+	return s
+}
+
+func newServer(options []ServerOption) *Server {
 	s := &Server{
-		proxy: httputil.NewSingleHostReverseProxy(baseURL),
+		transport:    http.DefaultTransport,
+		errorEncoder: DefaultErrorEncoder,
 	}
 	for _, option := range options {
 		option(s)
 	}
+	s.proxy = &httputil.ReverseProxy{
+		Director:       func(*http.Request) {}, // the Upstream rewrites the URL
+		Transport:      roundTripper{s},
+		ModifyResponse: s.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.errorEncoder(r.Context(), err, w)
+		},
+	}
 	return s
 }
 
@@ -45,6 +145,53 @@ func ServerBefore(before ...RequestFunc) ServerOption {
 	return func(s *Server) { s.before = append(s.before, before...) }
 }
 
+// ServerAfter functions are executed on the response of the upstream before
+// it's copied to the client. They may rewrite its headers.
+func ServerAfter(after ...ResponseFunc) ServerOption {
+	return func(s *Server) { s.after = append(s.after, after...) }
+}
+
+// ServerErrorEncoder is used to encode errors to the http.ResponseWriter when
+// the request can't be forwarded to any upstream. By default, errors are
+// written with the DefaultErrorEncoder.
+func ServerErrorEncoder(ee ErrorEncoder) ServerOption {
+	return func(s *Server) { s.errorEncoder = ee }
+}
+
+// ServerTransport sets the http.RoundTripper used to forward requests to the
+// upstreams. By default, http.DefaultTransport is used.
+func ServerTransport(transport http.RoundTripper) ServerOption {
+	return func(s *Server) { s.transport = transport }
+}
+
+// ServerRetry makes the server retry idempotent requests up to max times on
+// upstreams picked again from the balancer, when the upstream can't be
+// reached or replies with a 502, 503 or 504. Requests are idempotent if their
+// method is, see also ServerRetryIdempotencyKey. Their bodies are buffered
+// for retries, up to 1 MiB; requests with larger bodies, or bodies of unknown
+// length, aren't retried. By default, requests aren't retried.
+func ServerRetry(max int) ServerOption {
+	return func(s *Server) { s.retries = max }
+}
+
+// ServerRetryIdempotencyKey makes the server with ServerRetry also retry
+// requests with an Idempotency-Key header, whatever their method. Only use it
+// if the upstreams honor the header, since otherwise clients could have
+// requests with side effects, such as POSTs, run several times. By default,
+// the header is ignored.
+func ServerRetryIdempotencyKey() ServerOption {
+	return func(s *Server) { s.retryKeyed = true }
+}
+
+// ServerMetrics makes the server record every request forwarded to an
+// upstream, retries included. The requests counter is called with an
+// "upstream" label set to the host of the upstream, and a "code" label set
+// to the status code of its response, or "error". The latency histogram is
+// called with the "upstream" label, and observes seconds. Either may be nil.
+func ServerMetrics(requests metrics.Counter, latency metrics.Histogram) ServerOption {
+	return func(s *Server) { s.requests, s.latency = requests, latency }
+}
+
 // ServeHTTP implements http.Handler.
 func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -53,5 +200,162 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx = f(ctx, r)
 	}
 
-	s.proxy.ServeHTTP(w, r)
+	s.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// DefaultErrorEncoder writes a 503 if there's no upstream to forward the
+// request to, a 504 if the request timed out, and a 502 otherwise.
+func DefaultErrorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	code := http.StatusBadGateway
+	switch {
+	case errors.Is(err, lb.ErrNoEndpoints):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+	}
+	w.WriteHeader(code)
+}
+
+func (s *Server) modifyResponse(resp *http.Response) error {
+	ctx := resp.Request.Context()
+	for _, f := range s.after {
+		ctx = f(ctx, resp)
+	}
+	return nil
+}
+
+// maxRetryBody is the size of the largest request body buffered for retries.
+const maxRetryBody = 1 << 20
+
+// roundTripper forwards requests to the upstreams picked by the balancer of
+// the server, retrying them if they're idempotent.
+type roundTripper struct{ s *Server }
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	s := t.s
+	attempts := 1
+	if s.retries > 0 && (idempotent(req) || s.retryKeyed && req.Header.Get("Idempotency-Key") != "") {
+		attempts += s.retries
+	}
+
+	var body []byte
+	if attempts > 1 && req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength < 0 || req.ContentLength > maxRetryBody {
+			attempts = 1
+		} else {
+			b, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			body = b
+		}
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var upstream Upstream
+		if upstream, err = s.balancer.Endpoint(); err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		var (
+			begin = time.Now()
+			resp  *http.Response
+		)
+		resp, err = upstream(req.Context(), req)
+		if err == nil && resp == nil {
+			err = ErrNoResponse
+		}
+		if err == nil && resp.Request == nil {
+			resp.Request = req // needed by the ReverseProxy and ServerAfter
+		}
+		s.record(resp, err, time.Since(begin))
+
+		last := i == attempts-1 || req.Context().Err() != nil
+		switch {
+		case err != nil && !last:
+			continue
+		case err != nil:
+			return nil, err
+		case !last && retryable(resp.StatusCode):
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+	return nil, err
+}
+
+func (s *Server) record(resp *http.Response, err error, d time.Duration) {
+	if s.requests == nil && s.latency == nil {
+		return
+	}
+	var upstream, code string
+	var ue *UpstreamError
+	switch {
+	case err == nil:
+		upstream, code = resp.Request.URL.Host, strconv.Itoa(resp.StatusCode)
+		if upstream == "" {
+			upstream = "unknown"
+		}
+	case errors.As(err, &ue):
+		upstream, code = ue.Upstream, "error"
+	default:
+		upstream, code = "unknown", "error"
+	}
+	if s.requests != nil {
+		s.requests.With("upstream", upstream, "code", code).Add(1)
+	}
+	if s.latency != nil {
+		s.latency.With("upstream", upstream).Observe(d.Seconds())
+	}
+}
+
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// NewUpstream returns an Upstream forwarding requests to target with
+// transport, using its scheme, host, and base path, as NewServer does. The
+// Host header of the request is kept.
+func NewUpstream(target *url.URL, transport http.RoundTripper) Upstream {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		out := req.Clone(ctx)
+		(&httputil.ProxyRequest{In: req, Out: out}).SetURL(target)
+		out.Host = req.Host
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			return nil, &UpstreamError{Upstream: target.Host, Err: err}
+		}
+		return resp, nil
+	}
+}
+
+// Factory returns an sd.Factory of Upstreams forwarding requests with
+// transport, for NewBalancedServer. Instances are URLs, whose base path is
+// prefixed to the path of requests, or host:port pairs for the http scheme.
+func Factory(transport http.RoundTripper) sd.Factory[*http.Request, *http.Response] {
+	return func(instance string) (Upstream, io.Closer, error) {
+		if !strings.Contains(instance, "://") {
+			instance = "http://" + instance
+		}
+		target, err := url.Parse(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewUpstream(target, transport), nil, nil
+	}
 }
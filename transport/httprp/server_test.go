@@ -2,12 +2,20 @@ package httprp_test
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/go-kit/log"
+
+	"github.com/openmesh/kit/metrics"
+	"github.com/openmesh/kit/sd"
+	"github.com/openmesh/kit/sd/lb"
 	httptransport "github.com/openmesh/kit/transport/httprp"
 )
 
@@ -156,3 +164,378 @@ func TestMultipleServerBefore(t *testing.T) {
 		t.Errorf("want %q, have %q", want, have)
 	}
 }
+
+// counter records the label values it's called with.
+type counter struct {
+	mtx    *sync.Mutex
+	counts map[string]float64
+	labels string
+}
+
+func (c *counter) With(labelValues ...string) metrics.Counter {
+	return &counter{mtx: c.mtx, counts: c.counts, labels: strings.Join(labelValues, "=")}
+}
+
+func (c *counter) Add(delta float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.counts[c.labels] += delta
+}
+
+func TestInstancerServer(t *testing.T) {
+	var (
+		hits  = map[string]int{}
+		mtx   sync.Mutex
+		hosts []string
+	)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			hits[name+" "+r.URL.Path]++
+			mtx.Unlock()
+			w.Write([]byte(name))
+		}))
+		defer upstream.Close()
+		hosts = append(hosts, strings.TrimPrefix(upstream.URL, "http://"))
+	}
+	hosts[1] = "http://" + hosts[1] + "/base"
+
+	handler := httptransport.NewInstancerServer(sd.FixedInstancer(hosts), log.NewNopLogger())
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(proxyServer.URL + "/dir")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if want, have := map[string]int{"a /dir": 2, "b /base/dir": 2}, hits; len(want) != len(have) || want["a /dir"] != have["a /dir"] || want["b /base/dir"] != have["b /base/dir"] {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestServerRetry(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		calls = map[string]int{}
+	)
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		calls["unavailable "+r.Method]++
+		mtx.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		calls["healthy "+r.Method]++
+		mtx.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer healthy.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var (
+		c        = &counter{mtx: &sync.Mutex{}, counts: map[string]float64{}}
+		upstream = func(s *httptest.Server) httptransport.Upstream {
+			u, _ := url.Parse(s.URL)
+			return httptransport.NewUpstream(u, http.DefaultTransport)
+		}
+		handler = httptransport.NewBalancedServer(
+			lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{
+				upstream(closed), upstream(unavailable), upstream(healthy),
+			}),
+			httptransport.ServerRetry(2),
+			httptransport.ServerMetrics(c, nil),
+		)
+	)
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, proxyServer.URL, strings.NewReader("hello"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := "hello", string(body); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// POST isn't idempotent: the next upstream, closed, is tried only.
+	resp, err = http.Post(proxyServer.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusBadGateway, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+
+	if want, have := (map[string]int{"unavailable PUT": 1, "healthy PUT": 1}), calls; len(want) != len(have) || want["unavailable PUT"] != have["unavailable PUT"] || want["healthy PUT"] != have["healthy PUT"] {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	for _, testcase := range []struct {
+		upstream *httptest.Server
+		code     string
+		want     float64
+	}{
+		{closed, "error", 2},
+		{unavailable, "503", 1},
+		{healthy, "200", 1},
+	} {
+		labels := "upstream=" + strings.TrimPrefix(testcase.upstream.URL, "http://") + "=code=" + testcase.code
+		if have := c.counts[labels]; testcase.want != have {
+			t.Errorf("%s: want %v, have %v", labels, testcase.want, have)
+		}
+	}
+}
+
+func TestServerRetryIdempotencyKey(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	upstream := func(s *httptest.Server) httptransport.Upstream {
+		u, _ := url.Parse(s.URL)
+		return httptransport.NewUpstream(u, http.DefaultTransport)
+	}
+	for _, testcase := range []struct {
+		options []httptransport.ServerOption
+		want    int
+	}{
+		{[]httptransport.ServerOption{httptransport.ServerRetry(1)}, http.StatusBadGateway},
+		{[]httptransport.ServerOption{httptransport.ServerRetry(1), httptransport.ServerRetryIdempotencyKey()}, http.StatusOK},
+	} {
+		proxyServer := httptest.NewServer(httptransport.NewBalancedServer(
+			lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{
+				upstream(closed), upstream(healthy),
+			}),
+			testcase.options...,
+		))
+		req, _ := http.NewRequest(http.MethodPost, proxyServer.URL, strings.NewReader("hello"))
+		req.Header.Set("Idempotency-Key", "abc")
+		resp, err := http.DefaultClient.Do(req)
+		proxyServer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := testcase.want, resp.StatusCode; want != have {
+			t.Errorf("%d options: want %d, have %d", len(testcase.options), want, have)
+		}
+	}
+}
+
+func TestServerAfter(t *testing.T) {
+	originServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "origin")
+		w.Write([]byte("hey"))
+	}))
+	defer originServer.Close()
+	originURL, _ := url.Parse(originServer.URL)
+
+	handler := httptransport.NewServer(
+		originURL,
+		httptransport.ServerAfter(func(ctx context.Context, resp *http.Response) context.Context {
+			resp.Header.Del("Server")
+			resp.Header.Set("X-Proxied-By", "openmesh")
+			return ctx
+		}),
+	)
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := "", resp.Header.Get("Server"); want != have {
+		t.Errorf("want Server %q, have %q", want, have)
+	}
+	if want, have := "openmesh", resp.Header.Get("X-Proxied-By"); want != have {
+		t.Errorf("want X-Proxied-By %q, have %q", want, have)
+	}
+}
+
+func TestServerErrorEncoder(t *testing.T) {
+	var encoded error
+	handler := httptransport.NewBalancedServer(
+		lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{}),
+		httptransport.ServerErrorEncoder(func(ctx context.Context, err error, w http.ResponseWriter) {
+			encoded = err
+			httptransport.DefaultErrorEncoder(ctx, err, w)
+		}),
+	)
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want, have := http.StatusServiceUnavailable, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := lb.ErrNoEndpoints, encoded; want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+type histogram struct {
+	mtx          *sync.Mutex
+	observations map[string]int
+	labels       string
+}
+
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	return &histogram{mtx: h.mtx, observations: h.observations, labels: strings.Join(labelValues, "=")}
+}
+
+func (h *histogram) Observe(float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.observations[h.labels]++
+}
+
+func TestBalancedServerCustomUpstream(t *testing.T) {
+	var (
+		c        = &counter{mtx: &sync.Mutex{}, counts: map[string]float64{}}
+		upstream = func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			// A hand-written Upstream, that doesn't set the Request of its
+			// response.
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Upstream": {"fake"}},
+				Body:       ioutil.NopCloser(strings.NewReader("fake " + req.URL.Path)),
+			}, nil
+		}
+		handler = httptransport.NewBalancedServer(
+			lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{upstream}),
+			httptransport.ServerAfter(func(ctx context.Context, resp *http.Response) context.Context {
+				resp.Header.Set("X-Proxied-By", "openmesh")
+				return ctx
+			}),
+			httptransport.ServerMetrics(c, nil),
+		)
+	)
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL + "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if want, have := "fake /dir", string(body); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "openmesh", resp.Header.Get("X-Proxied-By"); want != have {
+		t.Errorf("want X-Proxied-By %q, have %q", want, have)
+	}
+	if want, have := 1.0, c.counts["upstream=unknown=code=200"]; want != have {
+		t.Errorf("want %v, have %v (%v)", want, have, c.counts)
+	}
+}
+
+func TestBalancedServerNilResponse(t *testing.T) {
+	var (
+		c       = &counter{mtx: &sync.Mutex{}, counts: map[string]float64{}}
+		handled error
+		handler = httptransport.NewBalancedServer(
+			lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{
+				func(context.Context, *http.Request) (*http.Response, error) { return nil, nil },
+			}),
+			httptransport.ServerErrorEncoder(func(ctx context.Context, err error, w http.ResponseWriter) {
+				handled = err
+				httptransport.DefaultErrorEncoder(ctx, err, w)
+			}),
+			httptransport.ServerMetrics(c, nil),
+		)
+	)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want, have := http.StatusBadGateway, rec.Code; want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if !errors.Is(handled, httptransport.ErrNoResponse) {
+		t.Errorf("want %v, have %v", httptransport.ErrNoResponse, handled)
+	}
+	if want, have := 1.0, c.counts["upstream=unknown=code=error"]; want != have {
+		t.Errorf("want %v, have %v (%v)", want, have, c.counts)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hey"))
+	}))
+	defer ok.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var (
+		mtx      = &sync.Mutex{}
+		c        = &counter{mtx: mtx, counts: map[string]float64{}}
+		h        = &histogram{mtx: mtx, observations: map[string]int{}}
+		upstream = func(s *httptest.Server) httptransport.Upstream {
+			u, _ := url.Parse(s.URL)
+			return httptransport.NewUpstream(u, http.DefaultTransport)
+		}
+		handler = httptransport.NewBalancedServer(
+			lb.NewRoundRobin[*http.Request, *http.Response](sd.FixedEndpointer[*http.Request, *http.Response]{
+				upstream(ok), upstream(notFound), upstream(closed),
+			}),
+			httptransport.ServerMetrics(c, h),
+		)
+	)
+	proxyServer := httptest.NewServer(handler)
+	defer proxyServer.Close()
+
+	for i := 0; i < 6; i++ {
+		resp, err := http.Get(proxyServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	host := func(s *httptest.Server) string { return strings.TrimPrefix(s.URL, "http://") }
+	wantCounts := map[string]float64{
+		"upstream=" + host(ok) + "=code=200":       2,
+		"upstream=" + host(notFound) + "=code=404": 2,
+		"upstream=" + host(closed) + "=code=error": 2,
+	}
+	if want, have := len(wantCounts), len(c.counts); want != have {
+		t.Errorf("want %d counters, have %v", want, c.counts)
+	}
+	for labels, want := range wantCounts {
+		if have := c.counts[labels]; want != have {
+			t.Errorf("%s: want %v, have %v", labels, want, have)
+		}
+	}
+	for _, s := range []*httptest.Server{ok, notFound, closed} {
+		labels := "upstream=" + host(s)
+		if want, have := 2, h.observations[labels]; want != have {
+			t.Errorf("%s: want %d observations, have %d", labels, want, have)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/nats-io/nats.go"
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 )
 
 // Publisher wraps a URL and provides a method that implements endpoint.Endpoint.
@@ -62,7 +63,10 @@ func PublisherTimeout[Request, Response any](timeout time.Duration) PublisherOpt
 	return func(p *Publisher[Request, Response]) { p.timeout = timeout }
 }
 
-// Endpoint returns a usable endpoint that invokes the remote endpoint.
+// Endpoint returns a usable endpoint that invokes the remote endpoint. If the
+// reply carries an *errcode.Error, as written by DefaultErrorEncoder, that
+// Error is returned instead of calling the DecodeResponseFunc, once the
+// PublisherResponseFuncs have been applied.
 func (p Publisher[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
 	return func(ctx context.Context, request Request) (Response, error) {
 		ctx, cancel := context.WithTimeout(ctx, p.timeout)
@@ -87,6 +91,10 @@ func (p Publisher[Request, Response]) Endpoint() endpoint.Endpoint[Request, Resp
 			ctx = f(ctx, resp)
 		}
 
+		if resp.Header.Get(errcode.Header) != "" {
+			return *new(Response), decodeError(resp)
+		}
+
 		response, err := p.dec(ctx, resp)
 		if err != nil {
 			return *new(Response), err
@@ -96,6 +104,22 @@ func (p Publisher[Request, Response]) Endpoint() endpoint.Endpoint[Request, Resp
 	}
 }
 
+// decodeError decodes the *errcode.Error in a reply written by
+// DefaultErrorEncoder.
+func decodeError(msg *nats.Msg) error {
+	code, _ := errcode.ParseCode(msg.Header.Get(errcode.Header))
+	var response errorResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return errcode.New(code, "")
+	}
+	return &errcode.Error{
+		Code:       code,
+		Message:    response.Error,
+		Details:    response.Details,
+		Violations: response.Violations,
+	}
+}
+
 // EncodeJSONRequest is an EncodeRequestFunc that serializes the request as a
 // JSON object to the Data of the Msg. Many JSON-over-NATS services can use it as
 // a sensible default.
@@ -2,11 +2,13 @@ package nats_test
 
 import (
 	"context"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/openmesh/kit/errcode"
 	natstransport "github.com/openmesh/kit/transport/nats"
 )
 
@@ -279,3 +281,46 @@ func TestEncodeJSONRequest(t *testing.T) {
 	}
 
 }
+
+func TestPublisherDecodesErrcodeError(t *testing.T) {
+	want := errcode.New(errcode.NotFound, "no such squadron").WithDetail("squadron", "437")
+
+	s, c := newNATSConn(t)
+	defer func() { s.Shutdown(); s.WaitForShutdown() }()
+	defer c.Close()
+
+	handler := natstransport.NewSubscriber(
+		func(context.Context, interface{}) (interface{}, error) { return nil, want },
+		func(context.Context, *nats.Msg) (interface{}, error) { return struct{}{}, nil },
+		func(context.Context, string, *nats.Conn, interface{}) error { return nil },
+	)
+	sub, err := c.QueueSubscribe("natstransport.test", "natstransport", handler.ServeMsg(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	var after bool
+	publisher := natstransport.NewPublisher(
+		c,
+		"natstransport.test",
+		func(context.Context, *nats.Msg, interface{}) error { return nil },
+		func(context.Context, *nats.Msg) (interface{}, error) { return struct{}{}, nil },
+		natstransport.PublisherAfter[interface{}, interface{}](func(ctx context.Context, _ *nats.Msg) context.Context {
+			after = true
+			return ctx
+		}),
+	)
+	_, err = publisher.Endpoint()(context.Background(), struct{}{})
+	if !after {
+		t.Error("want PublisherAfter applied to error replies")
+	}
+
+	have, ok := err.(*errcode.Error)
+	if !ok {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
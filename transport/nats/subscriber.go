@@ -3,9 +3,11 @@ package nats
 import (
 	"context"
 	"encoding/json"
+	"errors"
 
 	"github.com/go-kit/log"
 	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
 	"github.com/openmesh/kit/transport"
 
 	"github.com/nats-io/nats.go"
@@ -21,6 +23,7 @@ type Subscriber[Request, Response any] struct {
 	errorEncoder ErrorEncoder
 	finalizer    []SubscriberFinalizerFunc
 	errorHandler transport.ErrorHandler
+	budget       bool
 }
 
 // NewSubscriber constructs a new subscriber, which provides nats.MsgHandler and wraps
@@ -90,12 +93,26 @@ func SubscriberFinalizer[Request, Response any](f ...SubscriberFinalizerFunc) Su
 	return func(s *Subscriber[Request, Response]) { s.finalizer = f }
 }
 
+// SubscriberBudget makes the subscriber restore the transport.BudgetHeader
+// header of messages as the deadline of the request context. Only use it if
+// publishers are trusted, since a publisher can then shorten the time given
+// to the endpoint. By default, the header is ignored.
+func SubscriberBudget[Request, Response any]() SubscriberOption[Request, Response] {
+	return func(s *Subscriber[Request, Response]) { s.budget = true }
+}
+
 // ServeMsg provides nats.MsgHandler.
 func (s Subscriber[Request, Response]) ServeMsg(nc *nats.Conn) func(msg *nats.Msg) {
 	return func(msg *nats.Msg) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		if s.budget {
+			var cancelBudget context.CancelFunc
+			ctx, cancelBudget = transport.WithBudget(ctx, msg.Header.Get(transport.BudgetHeader))
+			defer cancelBudget()
+		}
+
 		if len(s.finalizer) > 0 {
 			defer func() {
 				for _, f := range s.finalizer {
@@ -173,25 +190,43 @@ func EncodeJSONResponse(_ context.Context, reply string, nc *nats.Conn, response
 	return nc.Publish(reply, b)
 }
 
-// DefaultErrorEncoder writes the error to the subscriber reply.
+// DefaultErrorEncoder writes the error to the subscriber reply, as a JSON
+// object with the error message in its "err" field. If the error is, or
+// wraps, an *errcode.Error, its code, details and violations are added to
+// the object, and the code is also set in the errcode.Header header of the
+// reply.
 func DefaultErrorEncoder(_ context.Context, err error, reply string, nc *nats.Conn) {
 	logger := log.NewNopLogger()
 
-	type Response struct {
-		Error string `json:"err"`
+	msg := nats.NewMsg(reply)
+	response := errorResponse{Error: err.Error()}
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		response = errorResponse{
+			Error:      e.Error(),
+			Code:       e.Code.String(),
+			Details:    e.Details,
+			Violations: e.Violations,
+		}
+		msg.Header.Set(errcode.Header, e.Code.String())
 	}
 
-	var response Response
-
-	response.Error = err.Error()
-
 	b, err := json.Marshal(response)
 	if err != nil {
 		logger.Log("err", err)
 		return
 	}
+	msg.Data = b
 
-	if err := nc.Publish(reply, b); err != nil {
+	if err := nc.PublishMsg(msg); err != nil {
 		logger.Log("err", err)
 	}
 }
+
+// errorResponse is the reply written by DefaultErrorEncoder.
+type errorResponse struct {
+	Error      string              `json:"err"`
+	Code       string              `json:"code,omitempty"`
+	Details    map[string]string   `json:"details,omitempty"`
+	Violations []errcode.Violation `json:"violations,omitempty"`
+}
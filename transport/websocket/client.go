@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/transport"
+)
+
+// Client wraps a Conn and provides a method that implements
+// endpoint.Endpoint.
+type Client[Request, Response any] struct {
+	conn   *Conn
+	enc    EncodeRequestFunc[Request]
+	dec    DecodeResponseFunc[Response]
+	before []RequestFunc
+	after  []ClientResponseFunc
+	budget bool
+}
+
+// NewClient constructs a usable Client for a single remote endpoint, served
+// over conn. Any number of Clients may share a Conn.
+func NewClient[Request, Response any](
+	conn *Conn,
+	enc EncodeRequestFunc[Request],
+	dec DecodeResponseFunc[Response],
+	options ...ClientOption[Request, Response],
+) *Client[Request, Response] {
+	c := &Client[Request, Response]{
+		conn: conn,
+		enc:  enc,
+		dec:  dec,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// ClientOption sets an optional parameter for clients.
+type ClientOption[Request, Response any] func(*Client[Request, Response])
+
+// ClientBefore sets the RequestFuncs that are applied to the outgoing request
+// Message before it's sent.
+func ClientBefore[Request, Response any](before ...RequestFunc) ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.before = append(c.before, before...) }
+}
+
+// ClientAfter sets the ClientResponseFuncs applied to the incoming response
+// Message prior to it being decoded. This is useful for obtaining anything
+// off of the response and adding onto the context prior to decoding.
+func ClientAfter[Request, Response any](after ...ClientResponseFunc) ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.after = append(c.after, after...) }
+}
+
+// ClientBudget makes the client send the time remaining until the deadline of
+// the context, if any, in the transport.BudgetHeader header. By default, it
+// isn't sent.
+func ClientBudget[Request, Response any]() ClientOption[Request, Response] {
+	return func(c *Client[Request, Response]) { c.budget = true }
+}
+
+// Endpoint returns a usable endpoint that invokes the remote endpoint. If the
+// response carries an error, as written by DefaultErrorEncoder, it's returned
+// as an *errcode.Error instead of calling the DecodeResponseFunc, once the
+// ClientResponseFuncs have been applied.
+func (c Client[Request, Response]) Endpoint() endpoint.Endpoint[Request, Response] {
+	return func(ctx context.Context, request Request) (Response, error) {
+		msg := &Message{}
+		if err := c.enc(ctx, msg, request); err != nil {
+			return *new(Response), err
+		}
+
+		if budget, ok := transport.Budget(ctx); ok && c.budget {
+			msg.setHeader(transport.BudgetHeader, budget)
+		}
+
+		for _, f := range c.before {
+			ctx = f(ctx, msg)
+		}
+
+		reply, err := c.conn.Call(ctx, msg)
+		if err != nil {
+			return *new(Response), err
+		}
+
+		for _, f := range c.after {
+			ctx = f(ctx, reply)
+		}
+
+		if reply.Error != nil {
+			return *new(Response), decodeError(reply.Error)
+		}
+
+		response, err := c.dec(ctx, reply)
+		if err != nil {
+			return *new(Response), err
+		}
+
+		return response, nil
+	}
+}
+
+// decodeError returns the error in a response written by
+// DefaultErrorEncoder as an *errcode.Error. Errors without a code get the
+// Unknown code.
+func decodeError(response *ErrorResponse) error {
+	code, _ := errcode.ParseCode(response.Code)
+	return &errcode.Error{
+		Code:       code,
+		Message:    response.Error,
+		Details:    response.Details,
+		Violations: response.Violations,
+	}
+}
+
+// EncodeJSONRequest is an EncodeRequestFunc that serializes the request as a
+// JSON object to the Data of the Message. Many JSON-over-WebSocket services
+// can use it as a sensible default.
+func EncodeJSONRequest(_ context.Context, msg *Message, request interface{}) error {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	msg.Data = b
+	return nil
+}
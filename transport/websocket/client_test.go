@@ -0,0 +1,205 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/transport"
+	wstransport "github.com/openmesh/kit/transport/websocket"
+)
+
+func encodeEchoRequest(ctx context.Context, msg *wstransport.Message, request echoRequest) error {
+	return wstransport.EncodeJSONRequest(ctx, msg, request)
+}
+
+func decodeEchoResponse(_ context.Context, reply *wstransport.Message) (echoResponse, error) {
+	var response echoResponse
+	err := json.Unmarshal(reply.Data, &response)
+	return response, err
+}
+
+func newEchoClient(t *testing.T, url string, options ...wstransport.ConnOption) (*wstransport.Conn, func(context.Context, echoRequest) (echoResponse, error)) {
+	t.Helper()
+	conn := wstransport.NewConn(url, options...)
+	t.Cleanup(func() { conn.Close() })
+	return conn, wstransport.NewClient(conn, encodeEchoRequest, decodeEchoResponse).Endpoint()
+}
+
+func TestClientCorrelatesResponses(t *testing.T) {
+	_, e := newEchoClient(t, newEchoServer(t))
+
+	// Later requests are answered first.
+	var wg sync.WaitGroup
+	for i, text := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delay := time.Duration(4-i) * 10 * time.Millisecond
+			response, err := e(context.Background(), echoRequest{Text: text, Delay: delay})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want, have := text, response.Text; want != have {
+				t.Errorf("want %q, have %q", want, have)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientDecodesErrcodeError(t *testing.T) {
+	var (
+		after bool
+		conn  = wstransport.NewConn(newEchoServer(t))
+		e     = wstransport.NewClient(conn, encodeEchoRequest, decodeEchoResponse,
+			wstransport.ClientAfter[echoRequest, echoResponse](func(ctx context.Context, _ *wstransport.Message) context.Context {
+				after = true
+				return ctx
+			}),
+		).Endpoint()
+	)
+	defer conn.Close()
+	_, err := e(context.Background(), echoRequest{})
+	if !after {
+		t.Error("want ClientAfter applied to error responses")
+	}
+
+	var ee *errcode.Error
+	if !errors.As(err, &ee) {
+		t.Fatalf("want *errcode.Error, have %T: %v", err, err)
+	}
+	if want, have := errcode.InvalidArgument, ee.Code; want != have {
+		t.Errorf("code: want %v, have %v", want, have)
+	}
+	if want, have := "nothing to echo", ee.Message; want != have {
+		t.Errorf("message: want %q, have %q", want, have)
+	}
+	if want, have := "text", ee.Details["field"]; want != have {
+		t.Errorf("detail: want %q, have %q", want, have)
+	}
+}
+
+func TestClientServerHooks(t *testing.T) {
+	var (
+		seen   = make(chan string, 1)
+		budget = make(chan bool, 1)
+		url    = newEchoServer(t,
+			wstransport.ServerBefore[echoRequest, echoResponse](func(ctx context.Context, msg *wstransport.Message) context.Context {
+				seen <- msg.Header["X-Trace"]
+				_, ok := ctx.Deadline()
+				budget <- ok && msg.Header[transport.BudgetHeader] != ""
+				return ctx
+			}),
+			wstransport.ServerAfter[echoRequest, echoResponse](wstransport.SetResponseHeader("X-Served-By", "test")),
+			wstransport.ServerBudget[echoRequest, echoResponse](),
+		)
+		servedBy string
+		conn     = wstransport.NewConn(url)
+		e        = wstransport.NewClient(conn, encodeEchoRequest, decodeEchoResponse,
+			wstransport.ClientBefore[echoRequest, echoResponse](wstransport.SetRequestHeader("X-Trace", "abc")),
+			wstransport.ClientBudget[echoRequest, echoResponse](),
+			wstransport.ClientAfter[echoRequest, echoResponse](func(ctx context.Context, reply *wstransport.Message) context.Context {
+				servedBy = reply.Header["X-Served-By"]
+				return ctx
+			}),
+		).Endpoint()
+	)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := e(ctx, echoRequest{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "abc", <-seen; want != have {
+		t.Errorf("request header: want %q, have %q", want, have)
+	}
+	if !<-budget {
+		t.Error("budget was not restored as the deadline")
+	}
+	if want, have := "test", servedBy; want != have {
+		t.Errorf("response header: want %q, have %q", want, have)
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	url := newEchoServer(t, wstransport.ServerReadLimit[echoRequest, echoResponse](256))
+	_, e := newEchoClient(t, url)
+
+	// A message over the read limit makes the server drop the connection.
+	_, err := e(context.Background(), echoRequest{Text: strings.Repeat("x", 512)})
+	if want, have := wstransport.ErrConnectionLost, err; want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	response, err := e(context.Background(), echoRequest{Text: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "again", response.Text; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestClientKeepalive(t *testing.T) {
+	// The server never reads, so it never answers pings.
+	var (
+		upgrader = websocket.Upgrader{}
+		release  = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, e := newEchoClient(t, "ws"+strings.TrimPrefix(server.URL, "http"), wstransport.ConnPingInterval(10*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := e(ctx, echoRequest{Text: "anyone?"}); err != wstransport.ErrConnectionLost {
+		t.Fatalf("want %v, have %v", wstransport.ErrConnectionLost, err)
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	conn, e := newEchoClient(t, newEchoServer(t))
+	if _, err := e(context.Background(), echoRequest{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if _, err := e(context.Background(), echoRequest{Text: "hi"}); err != wstransport.ErrClosed {
+		t.Fatalf("want %v, have %v", wstransport.ErrClosed, err)
+	}
+}
+
+func TestClientDialBackoff(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	server.Close()
+
+	_, e := newEchoClient(t, url, wstransport.ConnBackoff(time.Millisecond, 5*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if _, err := e(ctx, echoRequest{Text: "hi"}); err == nil {
+		t.Fatal("want dial error, have none")
+	}
+	if elapsed := time.Since(begin); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("want retries until the deadline, gave up after %v", elapsed)
+	}
+}
@@ -0,0 +1,284 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/openmesh/kit/errcode"
+)
+
+var (
+	// ErrConnectionLost is returned for requests in flight when the
+	// connection fails. They may or may not have been served.
+	ErrConnectionLost = errcode.New(errcode.Unavailable, "websocket: connection lost")
+
+	// ErrClosed is returned for requests made after the Conn is closed.
+	ErrClosed = errors.New("websocket: use of closed connection")
+)
+
+// Conn is a persistent connection to a WebSocket server, shared by any
+// number of Clients. It dials the server on the first request, and again
+// whenever the connection has been lost, backing off between failed
+// attempts. Requests are multiplexed over the connection, and their
+// responses correlated by Message ID.
+//
+// A Conn is safe for concurrent use.
+type Conn struct {
+	url          string
+	dialer       *websocket.Dialer
+	header       http.Header
+	pingInterval time.Duration
+	readLimit    int64
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+
+	nextID  atomic.Uint64
+	dialing chan struct{} // held while dialing, so only one dial is made
+	mtx     sync.Mutex
+	current *session
+	closed  bool
+}
+
+// ConnOption sets an optional parameter for a Conn.
+type ConnOption func(*Conn)
+
+// ConnDialer sets the websocket.Dialer used to connect to the server. By
+// default, websocket.DefaultDialer is used.
+func ConnDialer(d *websocket.Dialer) ConnOption {
+	return func(c *Conn) { c.dialer = d }
+}
+
+// ConnHeader sets the HTTP headers sent in the handshake, such as
+// Authorization.
+func ConnHeader(header http.Header) ConnOption {
+	return func(c *Conn) { c.header = header }
+}
+
+// ConnPingInterval sets how often the server is pinged. A connection on
+// which neither a message nor a pong is received for two intervals is
+// considered lost. Zero disables pings. By default, it's 30 seconds.
+func ConnPingInterval(d time.Duration) ConnOption {
+	return func(c *Conn) { c.pingInterval = d }
+}
+
+// ConnReadLimit sets the maximum size, in bytes, of a message read from the
+// server. The connection is considered lost if a larger message is
+// received. By default, it's 1 MiB.
+func ConnReadLimit(n int64) ConnOption {
+	return func(c *Conn) { c.readLimit = n }
+}
+
+// ConnBackoff sets the time waited after a failed dial before dialing
+// again. It starts at initial, and doubles after every further failure up to
+// maximum. By default, it starts at 100 milliseconds and goes up to 5
+// seconds.
+func ConnBackoff(initial, maximum time.Duration) ConnOption {
+	return func(c *Conn) { c.minBackoff, c.maxBackoff = initial, maximum }
+}
+
+// NewConn returns a Conn to the WebSocket server at url, such as
+// "wss://example.com/ws". The server isn't dialed until the first request.
+func NewConn(url string, options ...ConnOption) *Conn {
+	c := &Conn{
+		url:          url,
+		dialer:       websocket.DefaultDialer,
+		pingInterval: 30 * time.Second,
+		readLimit:    1 << 20,
+		minBackoff:   100 * time.Millisecond,
+		maxBackoff:   5 * time.Second,
+		dialing:      make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Call sends request, with a newly assigned ID, and waits for its response
+// or for ctx to be done. If there's no connection, one is dialed, retrying
+// until ctx is done. Requests in flight when the connection fails return
+// ErrConnectionLost; they aren't retried, since they may have been served.
+func (c *Conn) Call(ctx context.Context, request *Message) (*Message, error) {
+	s, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request.ID = strconv.FormatUint(c.nextID.Add(1), 10)
+	reply := s.register(request.ID)
+	defer s.unregister(request.ID)
+
+	if err := s.write(request); err != nil {
+		c.drop(s)
+		return nil, ErrConnectionLost
+	}
+
+	select {
+	case m := <-reply:
+		return m, nil
+	case <-s.done:
+		select {
+		case m := <-reply:
+			return m, nil
+		default:
+			return nil, ErrConnectionLost
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the connection, if any. Requests in flight return
+// ErrConnectionLost, and later requests ErrClosed.
+func (c *Conn) Close() error {
+	c.mtx.Lock()
+	s := c.current
+	c.current, c.closed = nil, true
+	c.mtx.Unlock()
+
+	if s == nil {
+		return nil
+	}
+	defer s.fail()
+	return s.close()
+}
+
+// session returns the current session, dialing a new one if there's none.
+func (c *Conn) session(ctx context.Context) (*session, error) {
+	backoff := c.minBackoff
+	for {
+		if s, err := c.load(); s != nil || err != nil {
+			return s, err
+		}
+
+		select {
+		case c.dialing <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s, err := c.load()
+		if s == nil && err == nil {
+			s, err = c.dial(ctx)
+		}
+		<-c.dialing
+		if s != nil || errors.Is(err, ErrClosed) {
+			return s, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff = min(2*backoff, c.maxBackoff)
+	}
+}
+
+// load returns the current session, if any.
+func (c *Conn) load() (*session, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	return c.current, nil
+}
+
+func (c *Conn) dial(ctx context.Context) (*session, error) {
+	ws, _, err := c.dialer.DialContext(ctx, c.url, c.header)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		socket:  newSocket(ws, c.pingInterval, c.readLimit),
+		pending: map[string]chan *Message{},
+		done:    make(chan struct{}),
+	}
+
+	c.mtx.Lock()
+	if c.closed {
+		c.mtx.Unlock()
+		ws.Close()
+		return nil, ErrClosed
+	}
+	c.current = s
+	c.mtx.Unlock()
+
+	go c.read(s)
+	go s.keepalive(s.done)
+	return s, nil
+}
+
+// read delivers the responses received in s until the connection fails.
+func (c *Conn) read(s *session) {
+	defer c.drop(s)
+	for {
+		data, err := s.read()
+		if err != nil {
+			return
+		}
+		m := &Message{}
+		if err := json.Unmarshal(data, m); err != nil {
+			continue
+		}
+		s.deliver(m)
+	}
+}
+
+// drop discards s, which has failed, so that the next request dials again.
+func (c *Conn) drop(s *session) {
+	c.mtx.Lock()
+	if c.current == s {
+		c.current = nil
+	}
+	c.mtx.Unlock()
+	s.ws.Close()
+	s.fail()
+}
+
+// session is a single connection to the server, and the requests in flight
+// on it.
+type session struct {
+	*socket
+	pendingMtx sync.Mutex
+	pending    map[string]chan *Message
+	done       chan struct{} // closed when the connection fails
+	once       sync.Once
+}
+
+func (s *session) register(id string) <-chan *Message {
+	reply := make(chan *Message, 1)
+	s.pendingMtx.Lock()
+	s.pending[id] = reply
+	s.pendingMtx.Unlock()
+	return reply
+}
+
+func (s *session) unregister(id string) {
+	s.pendingMtx.Lock()
+	delete(s.pending, id)
+	s.pendingMtx.Unlock()
+}
+
+// deliver passes m to the request with its ID. Responses to requests that
+// are no longer waiting are dropped.
+func (s *session) deliver(m *Message) {
+	s.pendingMtx.Lock()
+	reply, ok := s.pending[m.ID]
+	delete(s.pending, m.ID)
+	s.pendingMtx.Unlock()
+	if ok {
+		reply <- m
+	}
+}
+
+func (s *session) fail() {
+	s.once.Do(func() { close(s.done) })
+}
@@ -0,0 +1,4 @@
+// Package websocket provides a WebSocket transport. Requests and responses
+// are exchanged as JSON-encoded Messages over a persistent connection, and
+// correlated by their IDs, so that many requests may be in flight at once.
+package websocket
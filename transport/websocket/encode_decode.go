@@ -0,0 +1,27 @@
+package websocket
+
+import "context"
+
+// DecodeRequestFunc extracts a user-domain request object from a request
+// Message. It's designed to be used in WebSocket servers, for server-side
+// endpoints. One straightforward DecodeRequestFunc could be something that
+// JSON decodes from the Data of the Message to the concrete request type.
+type DecodeRequestFunc[Request any] func(context.Context, *Message) (request Request, err error)
+
+// EncodeRequestFunc encodes the passed request object into the request
+// Message. It's designed to be used in WebSocket clients, for client-side
+// endpoints. One straightforward EncodeRequestFunc could be something that
+// JSON encodes the object directly to the Data of the Message.
+type EncodeRequestFunc[Request any] func(context.Context, *Message, Request) error
+
+// EncodeResponseFunc encodes the passed response object into the response
+// Message. It's designed to be used in WebSocket servers, for server-side
+// endpoints. One straightforward EncodeResponseFunc could be something that
+// JSON encodes the object directly to the Data of the Message.
+type EncodeResponseFunc[Response any] func(context.Context, *Message, Response) error
+
+// DecodeResponseFunc extracts a user-domain response object from a response
+// Message. It's designed to be used in WebSocket clients, for client-side
+// endpoints. One straightforward DecodeResponseFunc could be something that
+// JSON decodes from the Data of the Message to the concrete response type.
+type DecodeResponseFunc[Response any] func(context.Context, *Message) (response Response, err error)
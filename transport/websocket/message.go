@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"github.com/openmesh/kit/errcode"
+)
+
+// Message is the envelope of every request and response sent over the
+// connection. Each is sent as a JSON-encoded text message.
+type Message struct {
+	// ID correlates a response with its request. The Conn sets it on every
+	// request. Requests without an ID get no response.
+	ID string `json:"id,omitempty"`
+
+	// Header carries metadata, such as transport.BudgetHeader.
+	Header map[string]string `json:"header,omitempty"`
+
+	// Data is the JSON-encoded request or response.
+	Data json.RawMessage `json:"data,omitempty"`
+
+	// Error is set, instead of Data, in the response to a failed request.
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+// ErrorResponse is the error in a response, as written by
+// DefaultErrorEncoder.
+type ErrorResponse struct {
+	Error      string              `json:"err"`
+	Code       string              `json:"code,omitempty"`
+	Details    map[string]string   `json:"details,omitempty"`
+	Violations []errcode.Violation `json:"violations,omitempty"`
+}
+
+// header returns the value of key in the header of m.
+func (m *Message) header(key string) string {
+	if m.Header == nil {
+		return ""
+	}
+	return m.Header[key]
+}
+
+// setHeader sets key to value in the header of m.
+func (m *Message) setHeader(key, value string) {
+	if m.Header == nil {
+		m.Header = map[string]string{}
+	}
+	m.Header[key] = value
+}
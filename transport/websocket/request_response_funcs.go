@@ -0,0 +1,37 @@
+package websocket
+
+import "context"
+
+// RequestFunc may take information from a request Message and put it into a
+// request context. In Servers, RequestFuncs are executed prior to decoding
+// the request. In Clients, they're executed after the request is encoded,
+// but before it's sent.
+type RequestFunc func(context.Context, *Message) context.Context
+
+// ServerResponseFunc may take information from a request context and use it
+// to manipulate the response Message. ServerResponseFuncs are only executed
+// in servers, after invoking the endpoint but prior to encoding the
+// response.
+type ServerResponseFunc func(context.Context, *Message) context.Context
+
+// ClientResponseFunc may take information from a response Message and make
+// it available for consumption. ClientResponseFuncs are only executed in
+// clients, after a response has been received, but prior to it being
+// decoded.
+type ClientResponseFunc func(context.Context, *Message) context.Context
+
+// SetResponseHeader returns a ServerResponseFunc that sets the given header.
+func SetResponseHeader(key, val string) ServerResponseFunc {
+	return func(ctx context.Context, m *Message) context.Context {
+		m.setHeader(key, val)
+		return ctx
+	}
+}
+
+// SetRequestHeader returns a RequestFunc that sets the given header.
+func SetRequestHeader(key, val string) RequestFunc {
+	return func(ctx context.Context, m *Message) context.Context {
+		m.setHeader(key, val)
+		return ctx
+	}
+}
@@ -0,0 +1,331 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/websocket"
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+	"github.com/openmesh/kit/transport"
+	httptransport "github.com/openmesh/kit/transport/http"
+)
+
+// Server wraps an endpoint and implements http.Handler. It upgrades every
+// request to a WebSocket connection, and serves each request Message
+// received on that connection, concurrently, up to a limit, until the
+// connection is closed.
+type Server[Request, Response any] struct {
+	e             endpoint.Endpoint[Request, Response]
+	dec           DecodeRequestFunc[Request]
+	enc           EncodeResponseFunc[Response]
+	upgrader      *websocket.Upgrader
+	upgradeBefore []httptransport.RequestFunc
+	before        []RequestFunc
+	after         []ServerResponseFunc
+	errorEncoder  ErrorEncoder
+	finalizer     []ServerFinalizerFunc
+	errorHandler  transport.ErrorHandler
+	pingInterval  time.Duration
+	readLimit     int64
+	maxConcurrent int
+	budget        bool
+}
+
+// NewServer constructs a new server, which implements http.Handler and wraps
+// the provided endpoint.
+func NewServer[Request, Response any](
+	e endpoint.Endpoint[Request, Response],
+	dec DecodeRequestFunc[Request],
+	enc EncodeResponseFunc[Response],
+	options ...ServerOption[Request, Response],
+) *Server[Request, Response] {
+	s := &Server[Request, Response]{
+		e:             e,
+		dec:           dec,
+		enc:           enc,
+		upgrader:      &websocket.Upgrader{},
+		errorEncoder:  DefaultErrorEncoder,
+		errorHandler:  transport.NewLogErrorHandler(log.NewNopLogger()),
+		pingInterval:  30 * time.Second,
+		readLimit:     1 << 20,
+		maxConcurrent: 64,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// ServerOption sets an optional parameter for servers.
+type ServerOption[Request, Response any] func(*Server[Request, Response])
+
+// ServerUpgrader sets the websocket.Upgrader used to upgrade requests. It
+// may be used to set buffer sizes, subprotocols, or an origin check. By
+// default, a zero Upgrader is used, which rejects cross-origin requests.
+func ServerUpgrader[Request, Response any](u *websocket.Upgrader) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.upgrader = u }
+}
+
+// ServerUpgradeBefore functions are executed on the HTTP request object
+// before it's upgraded. The context they return is the parent of the context
+// of every request served on the connection.
+func ServerUpgradeBefore[Request, Response any](before ...httptransport.RequestFunc) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.upgradeBefore = append(s.upgradeBefore, before...) }
+}
+
+// ServerBefore functions are executed on each request Message before it's
+// decoded.
+func ServerBefore[Request, Response any](before ...RequestFunc) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.before = append(s.before, before...) }
+}
+
+// ServerAfter functions are executed on each response Message after the
+// endpoint is invoked, but before the response is encoded.
+func ServerAfter[Request, Response any](after ...ServerResponseFunc) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.after = append(s.after, after...) }
+}
+
+// ServerErrorEncoder is used to encode errors to the response Message
+// whenever they're encountered in the processing of a request. Clients can
+// use this to provide custom error formatting. By default, errors will be
+// written with the DefaultErrorEncoder.
+func ServerErrorEncoder[Request, Response any](ee ErrorEncoder) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.errorEncoder = ee }
+}
+
+// ServerErrorHandler is used to handle non-terminal errors. By default, non-terminal errors
+// are ignored. This is intended as a diagnostic measure. Finer-grained control
+// of error handling, including logging in more detail, should be performed in a
+// custom ServerErrorEncoder or ServerFinalizer, both of which have access to
+// the context.
+func ServerErrorHandler[Request, Response any](errorHandler transport.ErrorHandler) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.errorHandler = errorHandler }
+}
+
+// ServerFinalizer is executed at the end of every request Message.
+// By default, no finalizer is registered.
+func ServerFinalizer[Request, Response any](f ...ServerFinalizerFunc) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.finalizer = append(s.finalizer, f...) }
+}
+
+// ServerPingInterval sets how often the client is pinged. A connection on
+// which neither a message nor a pong is received for two intervals is
+// closed. Zero disables pings. By default, it's 30 seconds.
+func ServerPingInterval[Request, Response any](d time.Duration) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.pingInterval = d }
+}
+
+// ServerReadLimit sets the maximum size, in bytes, of a message read from
+// the client. The connection is closed if a larger message is received.
+// By default, it's 1 MiB.
+func ServerReadLimit[Request, Response any](n int64) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.readLimit = n }
+}
+
+// ServerMaxConcurrent sets the maximum number of request Messages served
+// concurrently on a connection. Requests received while that many are being
+// served are rejected with an errcode.ResourceExhausted error, without being
+// decoded. Zero or less means no limit. By default, it's 64.
+func ServerMaxConcurrent[Request, Response any](n int) ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.maxConcurrent = n }
+}
+
+// ServerBudget makes the server restore the transport.BudgetHeader header of
+// request Messages as the deadline of the request context. Only use it for
+// servers whose clients are trusted, since a client can then shorten the time
+// given to the endpoint. By default, the header is ignored.
+func ServerBudget[Request, Response any]() ServerOption[Request, Response] {
+	return func(s *Server[Request, Response]) { s.budget = true }
+}
+
+// ServeHTTP implements http.Handler. When the connection is closed, the
+// context of the requests still being served is canceled.
+func (s Server[Request, Response]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	for _, f := range s.upgradeBefore {
+		ctx = f(ctx, r)
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err) // the upgrader has replied
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		sock    = newSocket(ws, s.pingInterval, s.readLimit)
+		wg      sync.WaitGroup
+		serving chan struct{} // a semaphore, if maxConcurrent is set
+	)
+	if s.maxConcurrent > 0 {
+		serving = make(chan struct{}, s.maxConcurrent)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		ws.Close()
+	}()
+	go sock.keepalive(ctx.Done())
+
+	for {
+		data, err := sock.read()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.errorHandler.Handle(ctx, err)
+			}
+			return
+		}
+
+		msg := &Message{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			s.errorHandler.Handle(ctx, err)
+			reply := &Message{}
+			s.errorEncoder(ctx, errcode.New(errcode.InvalidArgument, "malformed message"), reply)
+			if err := sock.write(reply); err != nil {
+				s.errorHandler.Handle(ctx, err)
+			}
+			continue
+		}
+
+		if serving != nil {
+			select {
+			case serving <- struct{}{}:
+			default:
+				err := errcode.New(errcode.ResourceExhausted, "too many concurrent requests")
+				s.errorHandler.Handle(ctx, err)
+				s.reply(ctx, sock, msg, &Message{ID: msg.ID}, err)
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if serving != nil {
+				defer func() { <-serving }()
+			}
+			s.serve(ctx, sock, msg)
+		}()
+	}
+}
+
+// serve serves a single request Message.
+func (s Server[Request, Response]) serve(ctx context.Context, sock *socket, msg *Message) {
+	if s.budget {
+		var cancel context.CancelFunc
+		ctx, cancel = transport.WithBudget(ctx, msg.header(transport.BudgetHeader))
+		defer cancel()
+	}
+
+	if len(s.finalizer) > 0 {
+		defer func() {
+			for _, f := range s.finalizer {
+				f(ctx, msg)
+			}
+		}()
+	}
+
+	for _, f := range s.before {
+		ctx = f(ctx, msg)
+	}
+
+	reply := &Message{ID: msg.ID}
+
+	request, err := s.dec(ctx, msg)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.reply(ctx, sock, msg, reply, err)
+		return
+	}
+
+	response, err := s.e(ctx, request)
+	if err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.reply(ctx, sock, msg, reply, err)
+		return
+	}
+
+	for _, f := range s.after {
+		ctx = f(ctx, reply)
+	}
+
+	if msg.ID == "" {
+		return
+	}
+
+	if err := s.enc(ctx, reply, response); err != nil {
+		s.errorHandler.Handle(ctx, err)
+		s.reply(ctx, sock, msg, reply, err)
+		return
+	}
+
+	s.reply(ctx, sock, msg, reply, nil)
+}
+
+// reply writes the response to msg, with err encoded in it if non-nil.
+// Requests without an ID get no response.
+func (s Server[Request, Response]) reply(ctx context.Context, sock *socket, msg, reply *Message, err error) {
+	if msg.ID == "" {
+		return
+	}
+	if err != nil {
+		s.errorEncoder(ctx, err, reply)
+	}
+	if err := sock.write(reply); err != nil {
+		s.errorHandler.Handle(ctx, err)
+	}
+}
+
+// ErrorEncoder is responsible for encoding an error to the response Message.
+// Users are encouraged to use custom ErrorEncoders to encode errors to
+// their responses, and will likely want to pass and check for their own
+// error types.
+type ErrorEncoder func(ctx context.Context, err error, reply *Message)
+
+// ServerFinalizerFunc can be used to perform work at the end of a request
+// Message, after the response has been written to the client. The principal
+// intended use is for request logging.
+type ServerFinalizerFunc func(ctx context.Context, msg *Message)
+
+// NopRequestDecoder is a DecodeRequestFunc that can be used for requests that do not
+// need to be decoded, and simply returns nil, nil.
+func NopRequestDecoder(_ context.Context, _ *Message) (interface{}, error) {
+	return nil, nil
+}
+
+// EncodeJSONResponse is a EncodeResponseFunc that serializes the response as
+// a JSON object to the Data of the Message. Many JSON-over-WebSocket
+// services can use it as a sensible default.
+func EncodeJSONResponse(_ context.Context, reply *Message, response interface{}) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	reply.Data = b
+	return nil
+}
+
+// DefaultErrorEncoder sets the Error of the response Message, with the error
+// message in its "err" field. If the error is, or wraps, an *errcode.Error,
+// its code, details and violations are set too. Any Data already encoded in
+// the response is dropped.
+func DefaultErrorEncoder(_ context.Context, err error, reply *Message) {
+	response := &ErrorResponse{Error: err.Error()}
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		response = &ErrorResponse{
+			Error:      e.Error(),
+			Code:       e.Code.String(),
+			Details:    e.Details,
+			Violations: e.Violations,
+		}
+	}
+	reply.Data, reply.Error = nil, response
+}
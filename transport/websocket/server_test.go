@@ -0,0 +1,212 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/openmesh/kit/errcode"
+	wstransport "github.com/openmesh/kit/transport/websocket"
+)
+
+type echoRequest struct {
+	Text  string        `json:"text"`
+	Delay time.Duration `json:"delay"`
+}
+
+type echoResponse struct {
+	Text string `json:"text"`
+}
+
+func echo(ctx context.Context, request echoRequest) (echoResponse, error) {
+	select {
+	case <-time.After(request.Delay):
+	case <-ctx.Done():
+		return echoResponse{}, ctx.Err()
+	}
+	if request.Text == "" {
+		return echoResponse{}, errcode.New(errcode.InvalidArgument, "nothing to echo").WithDetail("field", "text")
+	}
+	return echoResponse{Text: request.Text}, nil
+}
+
+func decodeEchoRequest(_ context.Context, msg *wstransport.Message) (echoRequest, error) {
+	var request echoRequest
+	err := json.Unmarshal(msg.Data, &request)
+	return request, err
+}
+
+func encodeEchoResponse(ctx context.Context, reply *wstransport.Message, response echoResponse) error {
+	return wstransport.EncodeJSONResponse(ctx, reply, response)
+}
+
+func newEchoServer(t *testing.T, options ...wstransport.ServerOption[echoRequest, echoResponse]) string {
+	t.Helper()
+	handler := wstransport.NewServer(echo, decodeEchoRequest, encodeEchoResponse, options...)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func TestServerMalformedMessage(t *testing.T) {
+	ws := dial(t, newEchoServer(t))
+	if err := ws.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	var reply wstransport.Message
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == nil || reply.Error.Code != errcode.InvalidArgument.String() {
+		t.Fatalf("want %v error, have %+v", errcode.InvalidArgument, reply.Error)
+	}
+}
+
+func TestServerErrorResponse(t *testing.T) {
+	ws := dial(t, newEchoServer(t))
+	ws.WriteJSON(wstransport.Message{ID: "a", Data: json.RawMessage(`{}`)})
+	var reply wstransport.Message
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "a", reply.ID; want != have {
+		t.Errorf("ID: want %q, have %q", want, have)
+	}
+	if reply.Data != nil {
+		t.Errorf("want no data, have %s", reply.Data)
+	}
+	if reply.Error == nil {
+		t.Fatal("want error, have none")
+	}
+	if want, have := "nothing to echo", reply.Error.Error; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "text", reply.Error.Details["field"]; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestServerMaxConcurrent(t *testing.T) {
+	ws := dial(t, newEchoServer(t, wstransport.ServerMaxConcurrent[echoRequest, echoResponse](2)))
+	for _, id := range []string{"a", "b", "c"} {
+		ws.WriteJSON(wstransport.Message{ID: id, Data: json.RawMessage(`{"text":"` + id + `","delay":200000000}`)})
+	}
+
+	replies := map[string]*wstransport.ErrorResponse{}
+	for i := 0; i < 3; i++ {
+		var reply wstransport.Message
+		if err := ws.ReadJSON(&reply); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && reply.ID != "c" {
+			t.Errorf("want the rejection of %q first, have a reply to %q", "c", reply.ID)
+		}
+		replies[reply.ID] = reply.Error
+	}
+	for _, id := range []string{"a", "b"} {
+		if replies[id] != nil {
+			t.Errorf("%s: want no error, have %+v", id, replies[id])
+		}
+	}
+	if reply := replies["c"]; reply == nil || reply.Code != errcode.ResourceExhausted.String() {
+		t.Errorf("c: want %v error, have %+v", errcode.ResourceExhausted, reply)
+	}
+
+	// Once requests are served, new ones are accepted again.
+	ws.WriteJSON(wstransport.Message{ID: "d", Data: json.RawMessage(`{"text":"d"}`)})
+	var reply wstransport.Message
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.ID != "d" || reply.Error != nil {
+		t.Errorf("want a reply to %q, have %+v", "d", reply)
+	}
+}
+
+func TestServerNoReplyWithoutID(t *testing.T) {
+	served := make(chan *wstransport.Message, 2)
+	ws := dial(t, newEchoServer(t, wstransport.ServerFinalizer[echoRequest, echoResponse](
+		func(_ context.Context, msg *wstransport.Message) { served <- msg },
+	)))
+	ws.WriteJSON(wstransport.Message{Data: json.RawMessage(`{"text":"one-way"}`)})
+	ws.WriteJSON(wstransport.Message{ID: "b", Data: json.RawMessage(`{"text":"two-way"}`)})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-served:
+		case <-time.After(time.Second):
+			t.Fatal("request was not served")
+		}
+	}
+	var reply wstransport.Message
+	if err := ws.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "b", reply.ID; want != have {
+		t.Errorf("want only a reply to %q, have a reply to %q", want, have)
+	}
+}
+
+func TestServerCancelsOnClose(t *testing.T) {
+	canceled := make(chan struct{})
+	handler := wstransport.NewServer(
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		},
+		wstransport.NopRequestDecoder,
+		wstransport.EncodeJSONResponse,
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ws := dial(t, "ws"+strings.TrimPrefix(server.URL, "http"))
+	ws.WriteJSON(wstransport.Message{ID: "c"})
+	time.Sleep(10 * time.Millisecond)
+	ws.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not canceled when the connection closed")
+	}
+}
+
+func TestServerPingsClient(t *testing.T) {
+	ws := dial(t, newEchoServer(t, wstransport.ServerPingInterval[echoRequest, echoResponse](5*time.Millisecond)))
+	pinged := make(chan struct{}, 1)
+	ws.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil // no pong, so the server gives up
+	})
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("server did not ping")
+	}
+}
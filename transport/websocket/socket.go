@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeWait is the time allowed to write a message to the peer.
+const writeWait = 10 * time.Second
+
+// socket wraps a WebSocket connection, on either end. It serializes writes,
+// and keeps the connection alive by pinging the peer: if neither a message
+// nor a pong is received for two ping intervals, reads fail and the
+// connection is considered lost.
+type socket struct {
+	ws           *websocket.Conn
+	pingInterval time.Duration
+	mtx          sync.Mutex // serializes writes
+}
+
+func newSocket(ws *websocket.Conn, pingInterval time.Duration, readLimit int64) *socket {
+	s := &socket{ws: ws, pingInterval: pingInterval}
+	if readLimit > 0 {
+		ws.SetReadLimit(readLimit)
+	}
+	if pingInterval > 0 {
+		s.extendDeadline()
+		ws.SetPongHandler(func(string) error { s.extendDeadline(); return nil })
+	}
+	return s
+}
+
+func (s *socket) extendDeadline() {
+	if s.pingInterval > 0 {
+		s.ws.SetReadDeadline(time.Now().Add(2 * s.pingInterval))
+	}
+}
+
+// read returns the next data message.
+func (s *socket) read() ([]byte, error) {
+	_, data, err := s.ws.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	s.extendDeadline()
+	return data, nil
+}
+
+func (s *socket) write(m *Message) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.ws.WriteJSON(m)
+}
+
+// keepalive pings the peer every ping interval, until done is closed or a
+// ping can't be sent. In the latter case, the connection is closed.
+func (s *socket) keepalive(done <-chan struct{}) {
+	if s.pingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				s.ws.Close()
+				return
+			}
+		}
+	}
+}
+
+// close sends a close message to the peer, and closes the connection.
+func (s *socket) close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	s.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+	return s.ws.Close()
+}
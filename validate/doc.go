@@ -0,0 +1,10 @@
+// Package validate checks decoded requests before they reach an endpoint.
+//
+// Middleware calls the Validate method of requests that implement Validator,
+// and any validation funcs it's given. Validators report invalid fields with
+// FieldError, or several of them at once with Errors. All the field errors
+// of a request are gathered into a single *errcode.Error with the
+// InvalidArgument code and a Violation per field, which the transports encode
+// as a 400 with the violations in the body, a gRPC status with a BadRequest
+// detail, or a JSON-RPC "Invalid params" error.
+package validate
@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/openmesh/kit/endpoint"
+	"github.com/openmesh/kit/errcode"
+)
+
+// Validator may be implemented by request types that can check themselves.
+type Validator interface {
+	Validate() error
+}
+
+// Func validates a request. It returns nil if the request is valid.
+type Func[Request any] func(ctx context.Context, request Request) error
+
+// FieldError reports why a field of a request is invalid. Nested fields are
+// named with dotted paths, like "address.city".
+type FieldError struct {
+	Field       string
+	Description string
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Description
+}
+
+// Errors is a list of field errors, for validators that check several fields
+// at once.
+type Errors []FieldError
+
+// Add appends a FieldError to es.
+func (es *Errors) Add(field, description string) {
+	*es = append(*es, FieldError{Field: field, Description: description})
+}
+
+// Err returns es as an error, or nil if it's empty. It's meant to be the
+// return value of a validator.
+func (es Errors) Err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+// Error implements the error interface.
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Middleware returns an endpoint.Middleware that validates each request before
+// calling the next endpoint. A request that implements Validator is validated
+// with its Validate method first, and then with each of funcs in turn.
+//
+// All validators run, and their errors are gathered into a single
+// *errcode.Error with the InvalidArgument code. FieldErrors, including those
+// in Errors or joined with errors.Join, become its violations, and the
+// messages of other errors are included in its message. If a validator
+// returns an *errcode.Error with a code other than InvalidArgument, such as
+// PermissionDenied, it's returned as is instead, and the errors of the other
+// validators, which have run too, are dropped. If several do, the first one
+// is returned.
+func Middleware[Request, Response any](funcs ...Func[Request]) endpoint.Middleware[Request, Response] {
+	return func(next endpoint.Endpoint[Request, Response]) endpoint.Endpoint[Request, Response] {
+		return func(ctx context.Context, request Request) (Response, error) {
+			var errs []error
+			if v, ok := interface{}(request).(Validator); ok {
+				errs = append(errs, v.Validate())
+			}
+			for _, f := range funcs {
+				errs = append(errs, f(ctx, request))
+			}
+			if err := aggregate(errs); err != nil {
+				return *new(Response), err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// aggregate gathers the non-nil errors in errs into an InvalidArgument Error.
+func aggregate(errs []error) error {
+	var (
+		msgs []string
+		e    = errcode.New(errcode.InvalidArgument, "")
+	)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var ec *errcode.Error
+		if errors.As(err, &ec) && ec.Code != errcode.InvalidArgument {
+			return err
+		}
+		msgs = append(msgs, err.Error())
+		collect(e, err)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	e.Message = "invalid request: " + strings.Join(msgs, "; ")
+	return e
+}
+
+// collect adds the field errors in err's tree to e as violations.
+func collect(e *errcode.Error, err error) {
+	switch err := err.(type) {
+	case FieldError:
+		e.WithViolation(err.Field, err.Description)
+	case Errors:
+		for _, fe := range err {
+			e.WithViolation(fe.Field, fe.Description)
+		}
+	case *errcode.Error:
+		e.Violations = append(e.Violations, err.Violations...)
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			collect(e, err)
+		}
+	case interface{ Unwrap() error }:
+		collect(e, err.Unwrap())
+	}
+}
@@ -0,0 +1,139 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/openmesh/kit/errcode"
+	httptransport "github.com/openmesh/kit/transport/http"
+	"github.com/openmesh/kit/validate"
+)
+
+type signup struct {
+	Name string
+	Age  int
+}
+
+func (s signup) Validate() error {
+	var errs validate.Errors
+	if s.Name == "" {
+		errs.Add("name", "must not be empty")
+	}
+	if s.Age < 0 {
+		errs.Add("age", "must not be negative")
+	}
+	return errs.Err()
+}
+
+var ok = func(context.Context, signup) (interface{}, error) { return "ok", nil }
+
+func TestValidRequest(t *testing.T) {
+	e := validate.Middleware[signup, interface{}]()(ok)
+	if response, err := e(context.Background(), signup{Name: "Ada", Age: 36}); err != nil || response != "ok" {
+		t.Fatalf("want ok, nil, have %v, %v", response, err)
+	}
+}
+
+func TestAggregatesFieldErrors(t *testing.T) {
+	adult := func(_ context.Context, s signup) error {
+		if s.Age < 18 {
+			return errors.Join(validate.FieldError{Field: "age", Description: "must be at least 18"})
+		}
+		return nil
+	}
+	var called bool
+	e := validate.Middleware[signup, interface{}](adult)(func(context.Context, signup) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+
+	_, err := e(context.Background(), signup{Age: -1})
+	if called {
+		t.Fatal("endpoint should not be called with an invalid request")
+	}
+	var have *errcode.Error
+	if !errors.As(err, &have) {
+		t.Fatalf("want *errcode.Error, have %T %v", err, err)
+	}
+	if want := errcode.InvalidArgument; want != have.Code {
+		t.Errorf("want %s, have %s", want, have.Code)
+	}
+	want := []errcode.Violation{
+		{Field: "name", Description: "must not be empty"},
+		{Field: "age", Description: "must not be negative"},
+		{Field: "age", Description: "must be at least 18"},
+	}
+	if !reflect.DeepEqual(want, have.Violations) {
+		t.Errorf("want %v, have %v", want, have.Violations)
+	}
+}
+
+func TestPlainErrors(t *testing.T) {
+	e := validate.Middleware[signup, interface{}](func(context.Context, signup) error {
+		return errors.New("signups are closed on Sundays")
+	})(ok)
+	_, err := e(context.Background(), signup{Name: "Ada"})
+	if want, have := "invalid request: signups are closed on Sundays", err.Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := errcode.InvalidArgument, errcode.CodeOf(err); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
+
+func TestOtherCodesPassThrough(t *testing.T) {
+	denied := errcode.New(errcode.PermissionDenied, "not yours")
+	e := validate.Middleware[signup, interface{}](func(context.Context, signup) error {
+		return fmt.Errorf("checking owner: %w", denied)
+	})(ok)
+	if _, err := e(context.Background(), signup{}); !errors.Is(err, denied) {
+		t.Errorf("want %v, have %v", denied, err)
+	}
+}
+
+func TestTransportEncodings(t *testing.T) {
+	_, err := validate.Middleware[signup, interface{}]()(ok)(context.Background(), signup{Age: -1})
+
+	rec := httptest.NewRecorder()
+	httptransport.DefaultErrorEncoder(context.Background(), err, rec)
+	if want, have := http.StatusBadRequest, rec.Code; want != have {
+		t.Errorf("HTTP: want %d, have %d", want, have)
+	}
+	var body struct {
+		Violations []errcode.Violation `json:"violations"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(body.Violations); want != have {
+		t.Errorf("HTTP: want %d violations, have %d", want, have)
+	}
+
+	s := status.Convert(err)
+	if want, have := codes.InvalidArgument, s.Code(); want != have {
+		t.Errorf("gRPC: want %v, have %v", want, have)
+	}
+	var violations int
+	for _, detail := range s.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			violations += len(br.FieldViolations)
+		}
+	}
+	if want, have := 2, violations; want != have {
+		t.Errorf("gRPC: want %d violations, have %d", want, have)
+	}
+
+	if want, have := -32602, err.(*errcode.Error).ErrorCode(); want != have {
+		t.Errorf("JSON-RPC: want %d, have %d", want, have)
+	}
+}